@@ -6,6 +6,7 @@ require (
 	github.com/bwmarrin/discordgo v0.29.0
 	github.com/joho/godotenv v1.5.1
 	github.com/oklog/run v1.2.0
+	golang.org/x/image v0.25.0
 	zombiezen.com/go/sqlite v1.4.2
 )
 
@@ -0,0 +1,121 @@
+// Reports problems that need the operator's attention to a Discord channel without flooding it
+package alert
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// How long an alert is held back after one with the same key was posted
+	cooldown = time.Hour
+	// Most alerts posted within a window, across every key
+	rateLimit  = 10
+	rateWindow = 10 * time.Minute
+
+	embedColor = 0xE74C3C
+)
+
+type Alerter struct {
+	nodeID    string
+	connected bool
+	queue     chan *discordgo.MessageEmbed // Alerts waiting to be posted, including any raised before Connect
+	seen      map[string]*record           // map[key]history
+	sent      []time.Time                  // When each alert in the current window was posted, oldest first
+	dropped   int                          // Alerts over the rate limit since the last one posted
+	mu        sync.Mutex
+}
+
+type record struct {
+	posted     time.Time
+	suppressed int // Times the alert was raised again during its cooldown
+}
+
+func New(nodeID string) *Alerter {
+	return &Alerter{
+		nodeID: nodeID,
+		queue:  make(chan *discordgo.MessageEmbed, 2*rateLimit),
+		seen:   make(map[string]*record),
+	}
+}
+
+// Logs a problem and posts it to the operator channel, unless an alert with the same key was posted within the
+// cooldown or too many alerts have been posted lately. Safe to call on a nil Alerter, which only logs.
+func (a *Alerter) Raise(key string, title string, format string, args ...any) {
+	description := fmt.Sprintf(format, args...)
+	log.Printf("alert: %s: %s", title, description)
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	history, exists := a.seen[key]
+	if exists && now.Sub(history.posted) < cooldown {
+		history.suppressed++
+		return
+	}
+
+	recent := a.sent[:0]
+	for _, sent := range a.sent {
+		if now.Sub(sent) < rateWindow {
+			recent = append(recent, sent)
+		}
+	}
+	a.sent = recent
+	if len(a.sent) >= rateLimit {
+		a.dropped++
+		return
+	}
+	a.sent = append(a.sent, now)
+
+	if exists && history.suppressed > 0 {
+		description += fmt.Sprintf("\n\nThis happened %d more times since the last alert.", history.suppressed)
+	}
+	if a.dropped > 0 {
+		description += fmt.Sprintf("\n\n%d other alerts were skipped to avoid flooding this channel.", a.dropped)
+		a.dropped = 0
+	}
+	a.seen[key] = &record{posted: now}
+
+	embed := &discordgo.MessageEmbed{
+		Title:       title,
+		Description: description,
+		Color:       embedColor,
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Node " + a.nodeID},
+		Timestamp:   now.UTC().Format(time.RFC3339),
+	}
+	select {
+	case a.queue <- embed:
+	default:
+		log.Println("alert: too many alerts waiting to be posted, skipping this one")
+	}
+}
+
+// Starts posting alerts, beginning with any raised before now
+func (a *Alerter) Connect(post func(*discordgo.MessageEmbed) error) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.connected {
+		return
+	}
+	a.connected = true
+	go func() {
+		for embed := range a.queue {
+			if err := post(embed); err != nil {
+				log.Printf("could not post alert to operator channel: %s", err)
+			}
+		}
+	}()
+}
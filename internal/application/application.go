@@ -9,11 +9,18 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
+	"github.com/angelajfisher/meeting-mate/internal/alert"
 	"github.com/angelajfisher/meeting-mate/internal/bot"
+	"github.com/angelajfisher/meeting-mate/internal/cluster"
 	"github.com/angelajfisher/meeting-mate/internal/db"
 	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/peer"
+	"github.com/angelajfisher/meeting-mate/internal/replication"
+	"github.com/angelajfisher/meeting-mate/internal/scheduler"
 	"github.com/angelajfisher/meeting-mate/internal/server"
 	"github.com/joho/godotenv"
 	"github.com/oklog/run"
@@ -62,6 +69,30 @@ func Initialize(devMode bool) {
 		}
 	})
 
+	for _, replicator := range botConfig.Orchestrator.Replicators {
+		g.Add(replicator.Run, func(error) { replicator.Stop() })
+	}
+
+	// Settle on a leader and catch up on the cluster's state before connecting to Discord
+	nodes := botConfig.Orchestrator.Cluster
+	nodes.Elect()
+	g.Add(nodes.Run, func(error) { nodes.Stop() })
+	if nodes.Clustered() {
+		if err = botConfig.Orchestrator.CatchUp(); err != nil {
+			log.Printf("could not catch up from the cluster, starting from saved state: %s", err)
+		}
+	}
+
+	// Weekly digests are built from stored meeting history, so there's nothing to schedule without a database
+	if botConfig.Orchestrator.Database.Persistent() {
+		jobs := scheduler.New(time.Minute)
+		jobs.Add(scheduler.Job{
+			Name: "weekly digest",
+			Run:  func(now time.Time) { bot.SendDueDigests(botConfig, now) },
+		})
+		g.Add(jobs.Run, func(error) { jobs.Stop() })
+	}
+
 	err = bot.Run(botConfig)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, fatalErrorMsg, err)
@@ -96,10 +127,26 @@ func validateEnv(dev bool) (*bot.Config, *server.Config, error) {
 		"./.meetingmate-db.sqlite3",
 		"preferred location of the database file",
 	)
-	sisterAddress := flag.String(
+	peerAddresses := flag.String(
 		"haURL",
 		"",
-		"URL of the other server in the high-availability pair",
+		"comma-separated URLs of the other servers in the high-availability cluster",
+	)
+	peerTimeout := flag.Duration(
+		"haTimeout",
+		2*time.Second,
+		"how long a single request to another server in the high-availability cluster may take",
+	)
+	pollInterval := flag.Duration(
+		"haInterval",
+		5*time.Second,
+		"how often to check on the other servers in the high-availability cluster",
+	)
+	nodeID := flag.String(
+		"nodeID",
+		"",
+		"unique name of this server in the high-availability cluster; the lowest sorts first when electing a leader"+
+			" (default: hostname and webhook port)",
 	)
 	flag.Parse()
 
@@ -132,27 +179,73 @@ func validateEnv(dev bool) (*bot.Config, *server.Config, error) {
 		return nil, nil, errors.New("required SSL_CERT and/or SSL_KEY filepaths missing from environment")
 	}
 
-	var dbPool db.DatabasePool
+	if *nodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "meeting-mate"
+		}
+		*nodeID = hostname + *webhookPort
+	}
+
+	// Problems are reported to the operator channel once the bot connects, including any from before then
+	alerts := alert.New(*nodeID)
+
+	var store db.Store
 	if *dbDisabled {
-		fmt.Println("Database disabled — skipping initialization")
-		dbPool = db.DatabasePool{Enabled: false}
+		fmt.Println("Database disabled — data will be kept in memory until shutdown")
+		store = db.NewMemoryStore()
 	} else {
 		var dbErr error
-		dbPool, dbErr = setupDatabase(*dbPathFlag)
+		store, dbErr = setupDatabase(*dbPathFlag, alerts)
 		if dbErr != nil {
 			return nil, nil, fmt.Errorf("could not initialize database: %w", dbErr)
 		}
 	}
 
-	if *sisterAddress == "" {
-		fmt.Println("\nNo secondary address provided for high-availability — synchronization disabled")
+	peerAuth := peer.Config{
+		Secret:   os.Getenv("PEER_SECRET"),
+		CABundle: os.Getenv("PEER_CA_BUNDLE"),
+		CertFile: os.Getenv("PEER_CERT"),
+		KeyFile:  os.Getenv("PEER_KEY"),
+		Timeout:  *peerTimeout,
 	}
 
-	o := orchestrator.NewOrchestrator(*sisterAddress, dbPool)
+	var (
+		peers       []*peer.Client
+		replicators []*replication.Replicator
+	)
+	for _, address := range strings.Split(*peerAddresses, ",") {
+		if address = strings.TrimSpace(address); address == "" {
+			continue
+		}
+		peerClient, peerErr := peer.NewClient(address, peerAuth)
+		if peerErr != nil {
+			return nil, nil, fmt.Errorf(
+				"high-availability requires PEER_SECRET and/or PEER_CA_BUNDLE, PEER_CERT & PEER_KEY: %w",
+				peerErr,
+			)
+		}
+		peers = append(peers, peerClient)
+		replicators = append(
+			replicators,
+			replication.NewReplicator(*nodeID, peerClient, replication.NewQueue(store, address)),
+		)
+	}
+	if len(peers) == 0 {
+		fmt.Println("\nNo other server addresses provided for high-availability — synchronization disabled")
+	} else {
+		fmt.Printf("\nJoining a high-availability cluster of %d servers as node %s\n", len(peers)+1, *nodeID)
+	}
+
+	o := orchestrator.NewOrchestrator(cluster.New(*nodeID, peers, *pollInterval, alerts), replicators, store, alerts)
+
 	botConf := bot.Config{
-		BotToken:     os.Getenv("BOT_TOKEN"),
-		AppID:        os.Getenv("APP_ID"),
-		Orchestrator: o,
+		BotToken:        os.Getenv("BOT_TOKEN"),
+		AppID:           os.Getenv("APP_ID"),
+		OperatorGuild:   os.Getenv("OPERATOR_GUILD"),
+		OperatorChannel: os.Getenv("OPERATOR_CHANNEL"),
+		Version:         appVersion,
+		Orchestrator:    o,
 	}
 	serverConf := server.Config{
 		DevMode:      *devMode,
@@ -161,6 +254,9 @@ func validateEnv(dev bool) (*bot.Config, *server.Config, error) {
 		StaticDir:    *staticDir,
 		Port:         *webhookPort,
 		Secret:       os.Getenv("ZOOM_TOKEN"),
+		ExportToken:  os.Getenv("EXPORT_TOKEN"),
+		MetricsToken: os.Getenv("METRICS_TOKEN"),
+		PeerAuth:     peerAuth,
 	}
 
 	if botConf.BotToken == "" || botConf.AppID == "" || serverConf.Secret == "" {
@@ -169,22 +265,31 @@ func validateEnv(dev bool) (*bot.Config, *server.Config, error) {
 		)
 	}
 
+	if serverConf.ExportToken == "" {
+		fmt.Println("\nNo EXPORT_TOKEN provided — attendance export endpoint disabled")
+	}
+	if serverConf.MetricsToken == "" {
+		fmt.Println("\nNo METRICS_TOKEN provided — metrics endpoint disabled")
+	}
+
 	fmt.Println("\nSetup complete! Time to get the party started!")
 
 	return &botConf, &serverConf, nil
 }
 
-func setupDatabase(dbPath string) (db.DatabasePool, error) {
+func setupDatabase(dbPath string, alerts *alert.Alerter) (db.Store, error) {
 	cleanedDbPath := filepath.Clean(dbPath)
 	fmt.Println("\nInitializing database at", cleanedDbPath)
 
 	err := db.InitializeDatabase(cleanedDbPath)
 	if err != nil {
-		return db.DatabasePool{}, fmt.Errorf("could not create database: %w", err)
+		return nil, fmt.Errorf("could not create database: %w", err)
 	}
-	err = db.MakeMigrations(cleanedDbPath)
+	err = db.MakeMigrations(cleanedDbPath, func(migrationErr error) {
+		alerts.Raise("migrations", "Database Migration Failed", "could not make database migrations: %s", migrationErr)
+	})
 	if err != nil {
-		return db.DatabasePool{}, fmt.Errorf("could not make database migrations: %w", err)
+		return nil, fmt.Errorf("could not make database migrations: %w", err)
 	}
 
 	dbPool, err := db.NewDatabasePool(cleanedDbPath)
@@ -3,31 +3,69 @@ package bot
 import (
 	"fmt"
 	"log"
+	"runtime/debug"
 	"strings"
 	"time"
 
 	"github.com/angelajfisher/meeting-mate/internal/bot/interactions"
+	"github.com/angelajfisher/meeting-mate/internal/db"
 	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
-	"github.com/angelajfisher/meeting-mate/internal/types"
+	"github.com/angelajfisher/meeting-mate/internal/outbox"
 	"github.com/bwmarrin/discordgo"
 )
 
 type Config struct {
-	BotToken     string
-	AppID        string
-	Orchestrator orchestrator.Orchestrator
-	session      *discordgo.Session
-	userID       string
+	BotToken        string
+	AppID           string
+	OperatorGuild   string // The server the bot's operator runs it from, if any
+	OperatorChannel string // Where the bot reports problems that need the operator's attention, if anywhere
+	Version         string
+	Orchestrator    *orchestrator.Orchestrator
+	session         *discordgo.Session
+	outbox          *outbox.Outbox
+	supervisor      *interactions.Supervisor
+	admin           *interactions.AdminInfo
 }
 
+const (
+	// How long a status message edit waits for newer changes before it's sent
+	editDebounce = time.Second
+	// How long shutdown waits for watches to post their final updates
+	watchStopTimeout = 5 * time.Second
+)
+
 func Run(bc *Config) error {
 	var err error
 	bc.session, err = discordgo.New("Bot " + bc.BotToken)
 	if err != nil {
 		return fmt.Errorf("invalid bot parameters: %w", err)
 	}
+	bc.outbox = outbox.New(bc.session, editDebounce)
+	bc.supervisor = interactions.NewSupervisor(bc.session, bc.Orchestrator, bc.outbox)
+	bc.admin = &interactions.AdminInfo{
+		Owners:    make(map[string]bool),
+		Version:   bc.Version,
+		StartedAt: time.Now(),
+		Outbox:    bc.outbox,
+	}
 
 	bc.session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		// A bug in one command shouldn't take the whole bot down with it
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("panic while handling interaction ID %s: %v\n%s", i.ID, r, debug.Stack())
+			}
+		}()
+
+		// Every node in a cluster receives each interaction, but only the leader answers
+		if !bc.Orchestrator.IsLeader() {
+			return
+		}
+		if i.GuildID == "" {
+			interactions.RefuseDM(s, i)
+			return
+		}
+
 		if i.Type != discordgo.InteractionApplicationCommand {
 			if i.Type == discordgo.InteractionMessageComponent {
 				interactions.HandleCancelSelection(s, i, bc.Orchestrator)
@@ -38,13 +76,30 @@ func Run(bc *Config) error {
 		data := i.ApplicationCommandData()
 		switch data.Name {
 		case interactions.WATCH_COMMAND:
-			interactions.HandleWatch(s, i, bc.Orchestrator, interactions.ParseOptions(data.Options))
+			interactions.HandleWatch(s, i, bc.Orchestrator, bc.supervisor, interactions.ParseOptions(data.Options))
 		case interactions.CANCEL_COMMAND:
 			interactions.HandleCancel(s, i, bc.Orchestrator, interactions.ParseOptions(data.Options))
 		case interactions.STATUS_COMMAND:
 			interactions.HandleStatus(s, i, bc.Orchestrator)
 		case interactions.UPDATE_COMMAND:
 			interactions.HandleUpdate(s, i, bc.Orchestrator, interactions.ParseOptions(data.Options))
+		case interactions.EXPORT_COMMAND:
+			interactions.HandleExport(s, i, bc.Orchestrator, interactions.ParseOptions(data.Options))
+		case interactions.ROSTER_COMMAND:
+			interactions.HandleRoster(s, i, bc.Orchestrator, data.Options[0])
+		case interactions.DIGEST_COMMAND:
+			interactions.HandleDigest(s, i, bc.Orchestrator, data.Options[0])
+		case interactions.CONFIG_COMMAND:
+			interactions.HandleConfig(s, i, bc.Orchestrator, data.Options[0])
+		case interactions.PERMS_COMMAND:
+			interactions.HandlePermissions(s, i, bc.Orchestrator, data.Options[0])
+		case interactions.ADMIN_COMMAND:
+			// Only ever registered in the operator server, but a stale registration elsewhere mustn't work either
+			if i.GuildID != bc.OperatorGuild {
+				log.Println("Refused /admin outside the operator server:", i.GuildID)
+				return
+			}
+			interactions.HandleAdmin(s, i, bc.Orchestrator, bc.admin, data.Options[0])
 		default:
 			log.Println("Invalid interaction received:", data.Name)
 		}
@@ -52,49 +107,72 @@ func Run(bc *Config) error {
 
 	bc.session.AddHandler(func(_ *discordgo.Session, r *discordgo.Ready) {
 		log.Println("Logged in as", r.User.String())
-		bc.userID = r.User.ID
+	})
+
+	// Watches in guilds or channels that are gone would never be able to post again
+	bc.session.AddHandler(func(_ *discordgo.Session, g *discordgo.GuildDelete) {
+		handleGuildDelete(bc, g)
+	})
+	bc.session.AddHandler(func(_ *discordgo.Session, c *discordgo.ChannelDelete) {
+		handleChannelDelete(bc, c.Channel)
+	})
+	bc.session.AddHandler(func(_ *discordgo.Session, t *discordgo.ThreadDelete) {
+		handleChannelDelete(bc, t.Channel)
 	})
 
 	_, err = bc.session.ApplicationCommandBulkOverwrite(bc.AppID, "", interactions.InteractionList())
 	if err != nil {
 		return fmt.Errorf("could not register bot commands: %w", err)
 	}
+	registerAdmin(bc)
 
 	err = bc.session.Open()
 	if err != nil {
 		return fmt.Errorf("could not open bot session: %w", err)
 	}
 
+	connectAlerts(bc)
+
 	if err = bc.session.UpdateCustomStatus("Check the status of your watches with /status"); err != nil {
 		log.Printf("could not set custom status: %s", err)
 	}
 
+	// Start watches created on other nodes in the cluster as they come in
+	bc.Orchestrator.OnRemoteWatch(func(watch db.WatchData) {
+		bc.supervisor.LoadSavedWatch(watch)
+	})
+
 	//
-	// Restart previously ongoing watches from last run
+	// Restart previously ongoing watches from last run or from the cluster
 
-	if !bc.Orchestrator.Database.Enabled {
+	pruneWatches(bc)
+	loadedWatches := bc.Orchestrator.GetAllWatches()
+	if len(loadedWatches) == 0 {
 		return nil
 	}
-	loadedWatches := bc.Orchestrator.Database.GetAllWatches()
 
-	// Keep track of the watches happening in each channel so a restart announcement can be sent
+	// Keep track of the watches in each channel without a status message to resume so a restart announcement can be
+	// sent in its place. The rest announce the restart by editing their own message.
 	channelWatches := make(map[string][]string) // channelID: []meetingIDs
-	keepingHistory := make(map[string]bool)     // channelID: FULL_HISTORY?
 
 	// Start the watch process for each watch loaded from the database
 	for _, watch := range loadedWatches {
-		go interactions.LoadSavedWatch(bc.session, bc.Orchestrator, watch)
-		channelWatches[watch.ChannelID] = append(channelWatches[watch.ChannelID], watch.MeetingID)
-		if watch.Options.HistoryLevel == types.FULL_HISTORY {
-			// If even one watch in a channel is keeping full history, we don't want to delete the previous bot message
-			keepingHistory[watch.ChannelID] = true
+		bc.supervisor.LoadSavedWatch(watch)
+		if watch.StatusMessageID == "" {
+			channelWatches[watch.ChannelID] = append(channelWatches[watch.ChannelID], watch.MeetingID)
 		}
 	}
-	log.Println("Loaded", len(loadedWatches), "watches from database")
+	log.Println("Loaded", len(loadedWatches), "saved watches")
+
+	// Followers keep their watches warm without announcing them; the leader speaks for the cluster
+	if !bc.Orchestrator.IsLeader() {
+		log.Println("Following cluster leader", bc.Orchestrator.Cluster.Leader(), "— skipping restart notices")
+		return nil
+	}
 
 	// Notify every channel of the restarted watches
 	for channelID, meetingIDs := range channelWatches {
-		notifyOfRestart(bc.session, meetingIDs, channelID, bc.userID, keepingHistory[channelID])
+		notifyOfRestart(bc.outbox, meetingIDs, channelID)
 	}
 
 	return nil
@@ -107,11 +185,12 @@ func Stop(bc *Config) error {
 
 	fmt.Print("Bot shutting down...")
 
-	// Notify all active watchers of shutdown
-	bc.Orchestrator.Shutdown()
-
-	// Give watches time to stop
-	time.Sleep(time.Second)
+	// Notify all active watchers of shutdown, and once they've stopped, send whatever they left queued. Watches
+	// that weren't told to stop have nothing left to post.
+	if bc.Orchestrator.Shutdown() && !bc.supervisor.Stop(watchStopTimeout) {
+		log.Println("not every watch stopped in time")
+	}
+	bc.outbox.Close(5 * time.Second)
 
 	err := bc.session.Close()
 	if err != nil {
@@ -122,8 +201,56 @@ func Stop(bc *Config) error {
 	return nil
 }
 
+// Starts posting alerts to the operator channel, if one is configured
+func connectAlerts(bc *Config) {
+	if bc.OperatorChannel == "" {
+		log.Println("No OPERATOR_CHANNEL provided — alerts will only be logged")
+		return
+	}
+
+	channel, err := bc.session.Channel(bc.OperatorChannel)
+	if err != nil {
+		log.Printf("could not find operator channel: %s", err)
+	} else if bc.OperatorGuild != "" && channel.GuildID != bc.OperatorGuild {
+		log.Printf("operator channel ID %s is not in the operator server ID %s", bc.OperatorChannel, bc.OperatorGuild)
+	}
+
+	bc.Orchestrator.Alerts.Connect(func(embed *discordgo.MessageEmbed) error {
+		_, sendErr := bc.outbox.Send(bc.OperatorChannel, &discordgo.MessageSend{
+			Embeds: []*discordgo.MessageEmbed{embed},
+		})
+		return sendErr
+	})
+}
+
+// Registers the owner-only admin commands in the operator server, if one is configured
+func registerAdmin(bc *Config) {
+	if bc.OperatorGuild == "" {
+		log.Println("No OPERATOR_GUILD provided — admin commands disabled")
+		return
+	}
+
+	app, err := bc.session.Application("@me")
+	if err != nil {
+		log.Printf("could not look up the bot's owners, admin commands disabled: %s", err)
+		return
+	}
+	if app.Team != nil {
+		for _, member := range app.Team.Members {
+			bc.admin.Owners[member.User.ID] = true
+		}
+	} else if app.Owner != nil {
+		bc.admin.Owners[app.Owner.ID] = true
+	}
+
+	_, err = bc.session.ApplicationCommandBulkOverwrite(bc.AppID, bc.OperatorGuild, interactions.AdminCommands())
+	if err != nil {
+		log.Printf("could not register admin commands in operator server ID %s: %s", bc.OperatorGuild, err)
+	}
+}
+
 // Sends a message to the given channel notifying of the program's (& their watches') restart
-func notifyOfRestart(s *discordgo.Session, meetingIDs []string, channelID string, userID string, keepingHistory bool) {
+func notifyOfRestart(out *outbox.Outbox, meetingIDs []string, channelID string) {
 	if len(meetingIDs) == 0 {
 		return
 	}
@@ -142,26 +269,7 @@ func notifyOfRestart(s *discordgo.Session, meetingIDs []string, channelID string
 		}
 	}
 
-	// Remove the previous bot message if the user isn't keeping history
-	if !keepingHistory {
-		channel, err := s.Channel(channelID)
-		if err != nil {
-			log.Printf("could not locate provided channel: %s", err)
-		}
-		lastMessage, err := s.ChannelMessage(channelID, channel.LastMessageID)
-		if err != nil {
-			log.Printf("could not grab previous channel message: %s", err)
-		}
-
-		if lastMessage.Author.ID == userID {
-			err = s.ChannelMessageDelete(channelID, lastMessage.ID)
-			if err != nil {
-				log.Printf("could not delete previous meeting message: %s", err)
-			}
-		}
-	}
-
-	_, err := s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+	_, err := out.Send(channelID, &discordgo.MessageSend{
 		Embeds: []*discordgo.MessageEmbed{{
 			Title:       "Meeting Mate Restarted!",
 			Description: meetingList.String(),
@@ -169,6 +277,6 @@ func notifyOfRestart(s *discordgo.Session, meetingIDs []string, channelID string
 		Flags: discordgo.MessageFlagsSuppressNotifications,
 	})
 	if err != nil {
-		log.Printf("could not send restart message to channel ID %s: %s", channelID, err)
+		log.Printf("could not send restart message: %s", err)
 	}
 }
@@ -0,0 +1,96 @@
+package bot
+
+import (
+	"log"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/outbox"
+	"github.com/bwmarrin/discordgo"
+)
+
+// How many guilds Discord lists per request
+const guildPageSize = 200
+
+// Cancels every watch in a guild the bot was removed from. Guilds that are only unavailable during an outage keep
+// their watches.
+func handleGuildDelete(bc *Config, g *discordgo.GuildDelete) {
+	if g.Unavailable {
+		return
+	}
+	cancelWatches(bc, "the bot was removed from the server", func(watch db.WatchData) bool {
+		return watch.GuildID == g.ID
+	})
+}
+
+// Cancels every watch posting to a deleted channel or thread
+func handleChannelDelete(bc *Config, channel *discordgo.Channel) {
+	cancelWatches(bc, "its channel was deleted", func(watch db.WatchData) bool {
+		return watch.ChannelID == channel.ID
+	})
+}
+
+// Cancels saved watches in guilds the bot has since left and in channels that have since been deleted, which would
+// otherwise be resumed on every start. Must be called before saved watches are started.
+func pruneWatches(bc *Config) {
+	if !bc.Orchestrator.IsLeader() {
+		return
+	}
+
+	guilds, err := visibleGuilds(bc.session)
+	if err != nil {
+		log.Printf("could not check saved watches against the bot's servers: %s", err)
+		return
+	}
+	cancelWatches(bc, "the bot is no longer in the server", func(watch db.WatchData) bool {
+		return !guilds[watch.GuildID]
+	})
+
+	deleted := make(map[string]bool) // channelID: no longer exists?
+	for _, watch := range bc.Orchestrator.GetAllWatches() {
+		if _, checked := deleted[watch.ChannelID]; checked {
+			continue
+		}
+		_, err = bc.session.Channel(watch.ChannelID)
+		// Channels the bot merely can't see anymore are left to pause their watches
+		deleted[watch.ChannelID] = outbox.ErrorCode(err) == discordgo.ErrCodeUnknownChannel
+	}
+	cancelWatches(bc, "its channel no longer exists", func(watch db.WatchData) bool {
+		return deleted[watch.ChannelID]
+	})
+}
+
+// Lists the IDs of every guild the bot is in
+func visibleGuilds(s *discordgo.Session) (map[string]bool, error) {
+	guilds := make(map[string]bool)
+	after := ""
+	for {
+		page, err := s.UserGuilds(guildPageSize, "", after, false)
+		if err != nil {
+			return nil, err
+		}
+		for _, guild := range page {
+			guilds[guild.ID] = true
+		}
+		if len(page) < guildPageSize {
+			return guilds, nil
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+func cancelWatches(bc *Config, reason string, affected func(db.WatchData) bool) {
+	// Every node receives the same events, but only the leader changes the cluster's watches
+	if !bc.Orchestrator.IsLeader() {
+		return
+	}
+
+	for _, watch := range bc.Orchestrator.GetAllWatches() {
+		if !affected(watch) {
+			continue
+		}
+		log.Printf("Canceling watch on meeting ID %s in %s because %s", watch.MeetingID, watch.GuildID, reason)
+		if err := bc.Orchestrator.CancelWatch(watch.GuildID, watch.MeetingID, watch.ChannelID); err != nil {
+			log.Printf("could not cancel watch on meeting ID %s: %s", watch.MeetingID, err)
+		}
+	}
+}
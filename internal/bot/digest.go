@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	digestPeriod       = 7 * 24 * time.Hour
+	digestTopAttendees = 3
+)
+
+// Posts the weekly digest for every guild whose scheduled time has come. Meant to be run by the scheduler.
+func SendDueDigests(bc *Config, now time.Time) {
+	if bc.session == nil || !bc.Orchestrator.IsLeader() {
+		return
+	}
+
+	allSettings, err := bc.Orchestrator.Database.GetAllDigestSettings()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, settings := range allSettings {
+		loc := time.UTC
+		if guild, guildErr := bc.Orchestrator.Database.GetGuildSettings(settings.GuildID); guildErr != nil {
+			log.Println(guildErr)
+		} else {
+			loc = guild.Location()
+		}
+		scheduled := lastScheduled(now, settings.Weekday, settings.Hour, loc)
+		// Catch up on a digest missed during downtime, but not one that's more than a day stale
+		if !settings.LastSent.Before(scheduled) || now.Sub(scheduled) > 24*time.Hour {
+			continue
+		}
+
+		// A digest that couldn't be sent is retried on the next run
+		if err = sendDigest(bc, settings, scheduled); err != nil {
+			log.Printf("could not send weekly digest to channel ID %s: %s", settings.ChannelID, err)
+			continue
+		}
+		if err = bc.Orchestrator.MarkDigestSent(settings.GuildID, now); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// Finds the most recent time at or before now that falls on the given weekday & hour in the given timezone
+func lastScheduled(now time.Time, weekday time.Weekday, hour int, loc *time.Location) time.Time {
+	now = now.In(loc)
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, loc)
+	scheduled = scheduled.AddDate(0, 0, -int((now.Weekday()-weekday+7)%7))
+	if scheduled.After(now) {
+		scheduled = scheduled.AddDate(0, 0, -7)
+	}
+	return scheduled
+}
+
+func sendDigest(bc *Config, settings db.DigestSettings, periodEnd time.Time) error {
+	periodStart := periodEnd.Add(-digestPeriod)
+
+	var (
+		fields     []*discordgo.MessageEmbedField
+		noShows    []string
+		totalTimes time.Duration
+		meetings   = bc.Orchestrator.GetGuildMeetings(settings.GuildID)
+	)
+	for _, meetingID := range meetings {
+		stats, err := bc.Orchestrator.Database.GetMeetingStats(meetingID, periodStart, periodEnd, digestTopAttendees)
+		if err != nil {
+			return err
+		}
+		label := "`" + meetingID + "`"
+		if stats.MeetingTopic != "" {
+			label = stats.MeetingTopic + " (" + label + ")"
+		}
+		if stats.Occurrences == 0 {
+			noShows = append(noShows, label)
+			continue
+		}
+
+		totalTimes += stats.TotalTime
+		value := new(strings.Builder)
+		fmt.Fprintf(value, "Occurrences: %d\nTotal Time: %s", stats.Occurrences, stats.TotalTime)
+		if len(stats.TopAttendees) > 0 {
+			value.WriteString("\nTop Attendees:")
+			for _, attendee := range stats.TopAttendees {
+				fmt.Fprintf(value, "\n- %s (%s)", attendee.Name, attendee.TotalTime)
+			}
+		}
+		if len(fields) < 24 { // Discord allows 25 fields per embed, and one is saved for meetings that never happened
+			fields = append(fields, &discordgo.MessageEmbedField{Name: label, Value: value.String()})
+		}
+	}
+
+	if len(meetings) == 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "No Watched Meetings",
+			Value: "There are no ongoing watches in this server. Get one started with `/watch`!",
+		})
+	}
+	if len(noShows) > 0 {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Never Happened",
+			Value: "- " + strings.Join(noShows, "\n- "),
+		})
+	}
+
+	_, err := bc.outbox.Send(settings.ChannelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title: "Weekly Meeting Digest",
+			Description: fmt.Sprintf(
+				"<t:%d:D> – <t:%d:D>\nTotal meeting time: %s",
+				periodStart.Unix(),
+				periodEnd.Unix(),
+				totalTimes,
+			),
+			Fields: fields,
+		}},
+		Flags: discordgo.MessageFlagsSuppressNotifications,
+	})
+	return err
+}
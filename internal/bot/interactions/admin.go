@@ -0,0 +1,305 @@
+package interactions
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/outbox"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// Longest message Discord accepts
+	maxMessageLength = 2000
+	// How the start of a maintenance window is entered, in UTC
+	maintenanceLayout = "2006-01-02 15:04"
+)
+
+// What the `/admin` command needs to know about the running bot
+type AdminInfo struct {
+	Owners    map[string]bool // IDs of the users who own the bot's Discord application
+	Version   string
+	StartedAt time.Time
+	Outbox    *outbox.Outbox
+}
+
+// The operator's commands, registered only in the operator server and hidden from everyone but its admins
+func AdminCommands() []*discordgo.ApplicationCommand {
+	var noPermissions int64
+	guildOnly := []discordgo.InteractionContextType{discordgo.InteractionContextGuild}
+
+	return []*discordgo.ApplicationCommand{
+		{
+			Name:                     ADMIN_COMMAND,
+			Description:              "Manage Meeting Mate across every server (bot owners only)",
+			DefaultMemberPermissions: &noPermissions,
+			Contexts:                 &guildOnly,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        ADMIN_STATS,
+					Description: "Show how the bot is doing",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+				{
+					Name:        ADMIN_WATCHES,
+					Description: "List the watches in every server",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+				},
+				{
+					Name:        ADMIN_CANCEL,
+					Description: "Cancel a meeting's watches in any server",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        GUILD_OPT,
+							Description: "ID of the server the watch is in",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+						{
+							Name:        MEETING_OPT,
+							Description: "ID of the Zoom meeting",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+					},
+				},
+				{
+					Name:        ADMIN_BROADCAST,
+					Description: "Post a maintenance notice in every channel with a watch",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Name:        MESSAGE_OPT,
+							Description: "What's happening and how it affects watches",
+							Type:        discordgo.ApplicationCommandOptionString,
+							Required:    true,
+						},
+						{
+							Name:        START_OPT,
+							Description: "When the maintenance starts, as YYYY-MM-DD HH:MM in UTC",
+							Type:        discordgo.ApplicationCommandOptionString,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// Handles the `/admin` command and its subcommands, refusing anyone who doesn't own the bot
+func HandleAdmin(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	info *AdminInfo,
+	subcommand *discordgo.ApplicationCommandInteractionDataOption,
+) {
+	user := invoker(i)
+	if !info.Owners[user.ID] {
+		log.Printf("%s: refused /admin %s in %s", user, subcommand.Name, i.GuildID)
+		respondAdmin(s, i, "Only the bot's owners can use this command.")
+		return
+	}
+	log.Printf("%s: /admin %s in %s", user, subcommand.Name, i.GuildID)
+
+	opts := ParseOptions(subcommand.Options)
+	switch subcommand.Name {
+	case ADMIN_STATS:
+		respondAdmin(s, i, adminStats(s, o, info))
+	case ADMIN_WATCHES:
+		respondAdmin(s, i, adminWatches(s, o))
+	case ADMIN_CANCEL:
+		respondAdmin(s, i, adminCancel(o, opts[GUILD_OPT].StringValue(), opts[MEETING_OPT].StringValue()))
+	case ADMIN_BROADCAST:
+		adminBroadcast(s, i, o, info.Outbox, opts)
+	}
+}
+
+func respondAdmin(s *discordgo.Session, i *discordgo.InteractionCreate, response string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: response,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("HandleAdmin: could not respond to interaction: %s", err)
+	}
+}
+
+func adminStats(s *discordgo.Session, o *orchestrator.Orchestrator, info *AdminInfo) string {
+	watches := o.GetAllWatches()
+	watchedGuilds := make(map[string]bool)
+	for _, watch := range watches {
+		watchedGuilds[watch.GuildID] = true
+	}
+
+	role := "Standalone"
+	if o.Cluster.Clustered() {
+		role = "Follower"
+		if o.IsLeader() {
+			role = "Leader"
+		}
+	}
+
+	builder := new(strings.Builder)
+	builder.WriteString("**Meeting Mate v" + info.Version + "**")
+	fmt.Fprintf(builder, "\nServers: %d", len(s.State.Guilds))
+	fmt.Fprintf(builder, "\nWatches: %d across %d servers", len(watches), len(watchedGuilds))
+	fmt.Fprintf(builder, "\nLive meetings: %d", o.LiveMeetings())
+	fmt.Fprintf(builder, "\nUptime: %s", time.Since(info.StartedAt).Round(time.Second))
+	fmt.Fprintf(builder, "\nNode: `%s` (%s)", o.Cluster.NodeID, role)
+	if o.Cluster.Clustered() {
+		builder.WriteString("\n\n" + clusterStatus(o))
+	}
+	return builder.String()
+}
+
+// Lists every watch, grouped by server, cutting the list short if it won't fit in a single message
+func adminWatches(s *discordgo.Session, o *orchestrator.Orchestrator) string {
+	watches := o.GetAllWatches()
+	if len(watches) == 0 {
+		return "There are no watches in any server."
+	}
+	slices.SortFunc(watches, func(a, b db.WatchData) int {
+		if a.GuildID != b.GuildID {
+			return strings.Compare(a.GuildID, b.GuildID)
+		}
+		return strings.Compare(a.MeetingID, b.MeetingID)
+	})
+
+	builder := new(strings.Builder)
+	builder.WriteString(strconv.Itoa(len(watches)) + " watches:")
+	lastGuild := ""
+	for n, watch := range watches {
+		var entry string
+		if watch.GuildID != lastGuild {
+			entry = "\n**" + guildName(s, watch.GuildID) + "** (`" + watch.GuildID + "`)"
+			lastGuild = watch.GuildID
+		}
+		entry += "\n- `" + watch.MeetingID + "`"
+		if watch.MeetingTopic != "" {
+			entry += " (" + watch.MeetingTopic + ")"
+		}
+		entry += " in <#" + watch.ChannelID + ">"
+
+		more := fmt.Sprintf("\n…and %d more", len(watches)-n)
+		if builder.Len()+len(entry)+len(more) > maxMessageLength {
+			builder.WriteString(more)
+			break
+		}
+		builder.WriteString(entry)
+	}
+	return builder.String()
+}
+
+func guildName(s *discordgo.Session, guildID string) string {
+	if guild, err := s.State.Guild(guildID); err == nil && guild.Name != "" {
+		return guild.Name
+	}
+	return "Unknown server"
+}
+
+// Cancels a meeting's watches in every channel of the server
+func adminCancel(o *orchestrator.Orchestrator, guildID string, meetingID string) string {
+	channels := o.GetWatchChannels(guildID, meetingID)
+	if len(channels) == 0 {
+		return "Nothing to cancel: there is no watch on meeting ID `" + meetingID + "` in server ID `" + guildID + "`."
+	}
+	failed := 0
+	for _, channelID := range channels {
+		if err := o.CancelWatch(guildID, meetingID, channelID); err != nil {
+			log.Printf("HandleAdmin: %s", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return "Could not cancel " + strconv.Itoa(failed) + " of the " + strconv.Itoa(len(channels)) +
+			" watches on meeting ID `" + meetingID + "` in server ID `" + guildID + "`. Please try again later."
+	}
+	if len(channels) > 1 {
+		return "Canceled the " + strconv.Itoa(len(channels)) + " watches on meeting ID `" + meetingID +
+			"` in server ID `" + guildID + "`."
+	}
+	return "Canceled the watch on meeting ID `" + meetingID + "` in server ID `" + guildID + "`."
+}
+
+// Posts a maintenance notice once in each channel that has a watch, reporting back how many were reached
+func adminBroadcast(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	out *outbox.Outbox,
+	opts optionMap,
+) {
+	description := opts[MESSAGE_OPT].StringValue()
+	if v, ok := opts[START_OPT]; ok {
+		start, err := time.Parse(maintenanceLayout, strings.TrimSpace(v.StringValue()))
+		if err != nil {
+			respondAdmin(s, i, "Could not read the start time: use YYYY-MM-DD HH:MM, in UTC.")
+			return
+		}
+		description += fmt.Sprintf("\n\nStarting <t:%d:F> (<t:%d:R>).", start.Unix(), start.Unix())
+	}
+
+	channels := make(map[string]bool)
+	for _, watch := range o.GetAllWatches() {
+		channels[watch.ChannelID] = true
+	}
+	if len(channels) == 0 {
+		respondAdmin(s, i, "There are no watches to notify.")
+		return
+	}
+
+	// Posting to every channel can take longer than Discord waits for a response
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		log.Printf("HandleAdmin: could not respond to interaction: %s", err)
+		return
+	}
+
+	notice := &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{{
+			Title:       "Scheduled Maintenance",
+			Description: description,
+		}},
+	}
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
+	)
+	for channelID := range channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, sendErr := out.Send(channelID, notice); sendErr != nil {
+				log.Printf("could not send maintenance notice to channel ID %s: %s", channelID, sendErr)
+				mu.Lock()
+				failed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	response := fmt.Sprintf("Posted the maintenance notice in %d channels.", len(channels)-failed)
+	if failed > 0 {
+		response += fmt.Sprintf(" %d could not be reached; check the logs for details.", failed)
+	}
+	if _, err = s.InteractionResponseEdit(i.Interaction, &discordgo.WebhookEdit{Content: &response}); err != nil {
+		log.Printf("HandleAdmin: could not edit interaction response: %s", err)
+	}
+}
@@ -2,19 +2,25 @@ package interactions
 
 import (
 	"log"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/types"
 	"github.com/bwmarrin/discordgo"
 )
 
 const (
 	CANCEL_ID = "meeting_cancel_selection_"
 	noWatch   = "Nothing to cancel: there is no ongoing watch on this meeting."
+
+	selectionSeparator = ":" // Between the meeting and channel IDs in a selected watch
+	maxSelectOptions   = 25  // The most options Discord allows in a select menu
 )
 
 // Handles the initial `/cancel` command
-func HandleCancel(s *discordgo.Session, i *discordgo.InteractionCreate, o orchestrator.Orchestrator, opts optionMap) {
+func HandleCancel(s *discordgo.Session, i *discordgo.InteractionCreate, o *orchestrator.Orchestrator, opts optionMap) {
 	var (
 		err       error
 		meetingID string
@@ -23,23 +29,34 @@ func HandleCancel(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 	// Check for a meeting ID provided with the command
 	if v, ok := opts[MEETING_OPT]; ok && v.StringValue() != "" {
 		meetingID = v.StringValue()
-		log.Printf("%s in %s: /cancel ID %s", i.Member.User, i.GuildID, meetingID)
+		log.Printf("%s in %s: /cancel ID %s", invoker(i), i.GuildID, meetingID)
 	} else {
-		log.Printf("%s in %s: /cancel", i.Member.User, i.GuildID)
+		log.Printf("%s in %s: /cancel", invoker(i), i.GuildID)
+	}
+	if !checkRoles(s, i, o, types.CANCEL_ACTION) {
+		return
 	}
 
 	//
 	// ID provided path
 
-	if meetingID != "" || o.IsOngoingWatch(i.GuildID, meetingID) {
-		var (
-			response string
-			msgFlags discordgo.MessageFlags
-		)
+	if meetingID != "" {
+		var msgFlags discordgo.MessageFlags
 
-		if o.IsOngoingWatch(i.GuildID, meetingID) {
-			o.CancelWatch(i.GuildID, meetingID)
-			response = "Canceled watch on meeting ID `" + meetingID + "`."
+		channelID, response := watchedChannel(i, o, opts, meetingID)
+		if response != "" {
+			msgFlags = discordgo.MessageFlagsEphemeral
+		} else if o.IsOngoingWatch(i.GuildID, meetingID, channelID) {
+			if err = o.CancelWatch(i.GuildID, meetingID, channelID); err != nil {
+				log.Printf("HandleCancel: %s", err)
+				response = "Could not cancel the watch on meeting ID `" + meetingID + "` in <#" + channelID +
+					">. Please try again later."
+				msgFlags = discordgo.MessageFlagsEphemeral
+			} else if channelID != i.ChannelID {
+				response = "Canceled watch on meeting ID `" + meetingID + "` in <#" + channelID + ">."
+			} else {
+				response = "Canceled watch on meeting ID `" + meetingID + "`."
+			}
 		} else {
 			response = noWatch
 			msgFlags = discordgo.MessageFlagsEphemeral
@@ -61,18 +78,23 @@ func HandleCancel(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 	//
 	// ID not provided path
 
-	// Format all ongoing meeting watches in this server into selectable options
+	// Format all ongoing meeting watches in this server into selectable options, one for each channel
+	meetings := o.GetGuildMeetings(i.GuildID)
+	slices.Sort(meetings)
 	guildWatches := []discordgo.SelectMenuOption{}
-	for _, meeting := range o.GetGuildMeetings(i.GuildID) {
+	for _, meeting := range meetings {
 		meetingLabel := meeting
 		meetingName := o.GetMeetingName(meeting)
 		if meetingName != "" {
 			meetingLabel += " (" + meetingName + ")"
 		}
-		guildWatches = append(guildWatches, discordgo.SelectMenuOption{
-			Label: meetingLabel,
-			Value: meeting,
-		})
+		for _, channelID := range o.GetWatchChannels(i.GuildID, meeting) {
+			guildWatches = append(guildWatches, discordgo.SelectMenuOption{
+				Label:       meetingLabel,
+				Description: "in #" + channelName(s, channelID),
+				Value:       meeting + selectionSeparator + channelID,
+			})
+		}
 	}
 
 	if len(guildWatches) == 0 {
@@ -89,11 +111,20 @@ func HandleCancel(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 		return
 	}
 
+	// Discord refuses a menu with too many options, so the rest have to be canceled by ID
+	prompt := "Which ongoing meeting watches would you like to cancel?"
+	if len(guildWatches) > maxSelectOptions {
+		prompt += "\nOnly the first " + strconv.Itoa(maxSelectOptions) + " of this server's " +
+			strconv.Itoa(len(guildWatches)) + " watches fit in the menu. To cancel one that isn't listed, use " +
+			"`/cancel` with its `" + MEETING_OPT + "` and `" + CHANNEL_OPT + "`."
+		guildWatches = guildWatches[:maxSelectOptions]
+	}
+
 	minVals := 1
 	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Which ongoing meeting watches would you like to cancel?",
+			Content: prompt,
 			Components: []discordgo.MessageComponent{
 				discordgo.ActionsRow{Components: []discordgo.MessageComponent{
 					discordgo.SelectMenu{
@@ -101,12 +132,12 @@ func HandleCancel(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 						Options:     guildWatches,
 						MinValues:   &minVals,
 						MaxValues:   len(guildWatches),
-						CustomID:    CANCEL_ID + i.Interaction.Member.User.ID,
+						CustomID:    CANCEL_ID + invoker(i).ID,
 						Placeholder: "Select meeting ID(s)",
 					},
 				}},
 			},
-			CustomID: "meeting_cancel_modal_" + i.Interaction.Member.User.ID,
+			CustomID: "meeting_cancel_modal_" + invoker(i).ID,
 		},
 	})
 	if err != nil {
@@ -115,19 +146,41 @@ func HandleCancel(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 }
 
 // Handles the user response to the multiselect menu returned by /cancel when an ID is not provided
-func HandleCancelSelection(s *discordgo.Session, i *discordgo.InteractionCreate, o orchestrator.Orchestrator) {
+func HandleCancelSelection(s *discordgo.Session, i *discordgo.InteractionCreate, o *orchestrator.Orchestrator) {
+	// Roles may have changed since the menu was shown
+	if !checkRoles(s, i, o, types.CANCEL_ACTION) {
+		return
+	}
 	data := i.MessageComponentData()
 
+	var canceled, failed []string
+	for _, value := range data.Values {
+		meetingID, channelID, _ := strings.Cut(value, selectionSeparator)
+		if err := o.CancelWatch(i.GuildID, meetingID, channelID); err != nil {
+			log.Printf("HandleCancelSelection: %s", err)
+			failed = append(failed, "`"+meetingID+"` in <#"+channelID+">")
+			continue
+		}
+		canceled = append(canceled, "`"+meetingID+"` in <#"+channelID+">")
+	}
+
 	var responseMsg string
-	if len(data.Values) == 1 {
-		o.CancelWatch(i.GuildID, data.Values[0])
-		responseMsg = "Canceled watch on meeting ID `" + data.Values[0] + "`."
-	} else {
+	switch {
+	case len(data.Values) == 1 && len(canceled) == 1:
+		responseMsg = "Canceled watch on meeting ID " + canceled[0] + "."
+	case len(data.Values) == 1:
+		responseMsg = "Could not cancel the watch on meeting ID " + failed[0] + ". Please try again later."
+	default:
 		builder := new(strings.Builder)
-		builder.WriteString("Canceled watches on the following meetings:")
-		for _, meetingID := range data.Values {
-			o.CancelWatch(i.GuildID, meetingID)
-			builder.WriteString("\n- `" + meetingID + "`")
+		if len(canceled) > 0 {
+			builder.WriteString("Canceled watches on the following meetings:\n- " + strings.Join(canceled, "\n- "))
+		}
+		if len(failed) > 0 {
+			if len(canceled) > 0 {
+				builder.WriteString("\n\n")
+			}
+			builder.WriteString("Could not cancel watches on the following meetings. Please try again later.\n- " +
+				strings.Join(failed, "\n- "))
 		}
 		responseMsg = builder.String()
 	}
@@ -142,3 +195,11 @@ func HandleCancelSelection(s *discordgo.Session, i *discordgo.InteractionCreate,
 		log.Printf("HandleCancelSelections: could not respond to interaction: %s", err)
 	}
 }
+
+// The name of a channel as the bot last saw it, or its ID if it's unknown
+func channelName(s *discordgo.Session, channelID string) string {
+	if channel, err := s.State.Channel(channelID); err == nil && channel.Name != "" {
+		return channel.Name
+	}
+	return channelID
+}
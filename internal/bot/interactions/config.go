@@ -0,0 +1,157 @@
+package interactions
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/bwmarrin/discordgo"
+)
+
+var roleMention = regexp.MustCompile(`<@&(\d+)>`)
+
+// Looks up a guild's settings, falling back to the defaults if they can't be read so a database hiccup doesn't
+// block watches
+func guildSettings(o *orchestrator.Orchestrator, guildID string) db.GuildSettings {
+	settings, err := o.Database.GetGuildSettings(guildID)
+	if err != nil {
+		log.Printf("could not get settings for %s, using defaults: %s", guildID, err)
+		return db.DefaultGuildSettings(guildID)
+	}
+	return settings
+}
+
+// Handles the `/config` command and its subcommands
+func HandleConfig(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	subcommand *discordgo.ApplicationCommandInteractionDataOption,
+) {
+	log.Printf("%s: /config %s in %s", invoker(i), subcommand.Name, i.GuildID)
+
+	settings, err := o.Database.GetGuildSettings(i.GuildID)
+	if err != nil {
+		log.Printf("HandleConfig: %s", err)
+		respondConfig(s, i, "Could not look up this server's settings. Please try again later.")
+		return
+	}
+
+	opts := ParseOptions(subcommand.Options)
+	switch subcommand.Name {
+	case CONFIG_VIEW:
+		respondConfig(s, i, describeSettings(settings))
+		return
+	case CONFIG_RESET:
+		if err = o.DeleteGuildSettings(i.GuildID); err != nil {
+			log.Printf("HandleConfig: %s", err)
+			respondConfig(s, i, "Could not reset this server's settings. Please try again later.")
+			return
+		}
+		respondConfig(s, i, "This server is back to the default settings.\n\n"+
+			describeSettings(db.DefaultGuildSettings(i.GuildID)))
+		return
+	case CONFIG_WATCHES:
+		if v, ok := opts[SILENT_OPT]; ok {
+			settings.Defaults.Silent = v.BoolValue()
+		}
+		if v, ok := opts[SUMMARY_OPT]; ok {
+			settings.Defaults.SummaryLevel = v.StringValue()
+		}
+		if v, ok := opts[HISTORY_OPT]; ok {
+			settings.Defaults.HistoryLevel = v.StringValue()
+		}
+		if v, ok := opts[CHART_OPT]; ok {
+			settings.Defaults.TimelineChart = v.BoolValue()
+		}
+	case CONFIG_CHANNEL:
+		settings.ChannelID = ""
+		if v, ok := opts[CHANNEL_OPT]; ok {
+			settings.ChannelID = v.ChannelValue(nil).ID
+		}
+	case CONFIG_TIMEZONE:
+		timezone := strings.TrimSpace(opts[ZONE_OPT].StringValue())
+		if _, zoneErr := time.LoadLocation(timezone); zoneErr != nil || timezone == "" || timezone == "Local" {
+			respondConfig(s, i, "Unknown timezone `"+timezone+"`. Use a name like `America/New_York` or `UTC`.")
+			return
+		}
+		settings.Timezone = timezone
+		if timezone == "UTC" {
+			settings.Timezone = ""
+		}
+	case CONFIG_LOCALE:
+		settings.Locale = ""
+		if v, ok := opts[LOCALE_OPT]; ok {
+			locale := discordgo.Locale(strings.TrimSpace(v.StringValue()))
+			if _, known := discordgo.Locales[locale]; !known {
+				respondConfig(s, i, "Unknown locale `"+string(locale)+"`. Use one of Discord's, like `en-US` or `de`.")
+				return
+			}
+			settings.Locale = string(locale)
+		}
+	case CONFIG_MENTIONS:
+		settings.MentionRoles = nil
+		if v, ok := opts[ROLES_OPT]; ok {
+			for _, match := range roleMention.FindAllStringSubmatch(v.StringValue(), -1) {
+				settings.MentionRoles = append(settings.MentionRoles, match[1])
+			}
+			if len(settings.MentionRoles) == 0 {
+				respondConfig(s, i, "No roles found. Mention each role to ping, like `@Team @Leads`.")
+				return
+			}
+		}
+	}
+
+	if err = o.SaveGuildSettings(settings); err != nil {
+		log.Printf("HandleConfig: %s", err)
+		respondConfig(s, i, "Could not save this server's settings. Please try again later.")
+		return
+	}
+	respondConfig(s, i, "Settings saved. New watches use them from now on.\n\n"+describeSettings(settings))
+}
+
+func respondConfig(s *discordgo.Session, i *discordgo.InteractionCreate, response string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         response,
+			Flags:           discordgo.MessageFlagsEphemeral,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	if err != nil {
+		log.Printf("HandleConfig: could not respond to interaction: %s", err)
+	}
+}
+
+func describeSettings(settings db.GuildSettings) string {
+	channel := "wherever `/watch` is used"
+	if settings.ChannelID != "" {
+		channel = "<#" + settings.ChannelID + ">"
+	}
+	timezone := settings.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	locale := settings.Locale
+	if locale == "" {
+		locale = "Discord's default"
+	}
+	mentions := "nobody"
+	if len(settings.MentionRoles) > 0 {
+		mentions = mentionRoles(settings.MentionRoles)
+	}
+
+	return "**Server Settings**" +
+		"\n**Silent**: `" + boolLabel(settings.Defaults.Silent) +
+		"`\n**Summary level**: `" + settings.Defaults.SummaryLevel +
+		"`\n**History level**: `" + settings.Defaults.HistoryLevel +
+		"`\n**Timeline chart**: `" + boolLabel(settings.Defaults.TimelineChart) +
+		"`\n**Channel**: " + channel +
+		"\n**Timezone**: `" + timezone +
+		"`\n**Locale**: " + locale +
+		"\n**Pinged when a meeting starts**: " + mentions
+}
@@ -0,0 +1,70 @@
+package interactions
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Handles the `/digest` command and its subcommands
+func HandleDigest(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	subcommand *discordgo.ApplicationCommandInteractionDataOption,
+) {
+	log.Printf("%s: /digest %s in %s", invoker(i), subcommand.Name, i.GuildID)
+
+	var response string
+	switch {
+	case !o.Database.Persistent():
+		response = "Weekly digests aren't available because Meeting Mate is running without a database. " +
+			"They need persistent storage to keep their schedule and meeting history."
+	case subcommand.Name == DIGEST_DISABLE:
+		if err := o.DeleteDigestSettings(i.GuildID); err != nil {
+			log.Printf("HandleDigest: %s", err)
+			response = "Could not turn off weekly digests. Please try again later."
+			break
+		}
+		response = "Weekly digests have been turned off for this server."
+	default:
+		opts := ParseOptions(subcommand.Options)
+		settings := db.DigestSettings{
+			GuildID:   i.GuildID,
+			ChannelID: i.ChannelID,
+			Weekday:   time.Weekday(opts[DAY_OPT].IntValue()),
+			Hour:      int(opts[HOUR_OPT].IntValue()),
+		}
+		if v, ok := opts[CHANNEL_OPT]; ok {
+			settings.ChannelID = v.ChannelValue(nil).ID
+		}
+		if err := o.SaveDigestSettings(settings); err != nil {
+			log.Printf("HandleDigest: %s", err)
+			response = "Could not save the digest schedule. Please try again later."
+			break
+		}
+		guild := guildSettings(o, i.GuildID)
+		response = fmt.Sprintf(
+			"A digest of this server's watched meetings will be posted in <#%s> every %s at %s %s.",
+			settings.ChannelID,
+			settings.Weekday,
+			time.Date(2000, 1, 1, settings.Hour, 0, 0, 0, time.UTC).Format(guild.ClockFormat()),
+			guild.Location(),
+		)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: response,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("HandleDigest: could not respond to interaction: %s", err)
+	}
+}
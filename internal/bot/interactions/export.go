@@ -0,0 +1,61 @@
+package interactions
+
+import (
+	"bytes"
+	"log"
+
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"github.com/bwmarrin/discordgo"
+)
+
+func HandleExport(s *discordgo.Session, i *discordgo.InteractionCreate, o *orchestrator.Orchestrator, opts optionMap) {
+	meetingID := opts[MEETING_OPT].StringValue()
+	log.Printf("%s: /export ID %s in %s", invoker(i), meetingID, i.GuildID)
+
+	format := types.EXPORT_CSV
+	if v, ok := opts[FORMAT_OPT]; ok {
+		format = v.StringValue()
+	}
+	var fromDate, toDate string
+	if v, ok := opts[FROM_OPT]; ok {
+		fromDate = v.StringValue()
+	}
+	if v, ok := opts[TO_OPT]; ok {
+		toDate = v.StringValue()
+	}
+
+	response := &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral}
+
+	if allowed, err := o.CanExport(i.GuildID, meetingID); err != nil {
+		log.Printf("HandleExport: %s", err)
+		response.Content = "Could not check the meeting's history. Please try again later."
+	} else if !allowed {
+		response.Content = "Nothing to export: meeting ID `" + meetingID +
+			"` isn't being watched in this server and has no history here."
+	} else if from, to, err := orchestrator.ParseExportRange(fromDate, toDate); err != nil {
+		response.Content = "Could not export attendance: " + err.Error() + "."
+	} else if file, fileName, exportErr := o.ExportAttendance(meetingID, from, to, format); exportErr != nil {
+		log.Printf("HandleExport: %s", exportErr)
+		response.Content = "Something went wrong while building the export. Please try again later."
+	} else {
+		contentType := "text/csv"
+		if format == types.EXPORT_JSON {
+			contentType = "application/json"
+		}
+		response.Content = "Here's the attendance for meeting ID `" + meetingID + "`!"
+		response.Files = []*discordgo.File{{
+			Name:        fileName,
+			ContentType: contentType,
+			Reader:      bytes.NewReader(file),
+		}}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: response,
+	})
+	if err != nil {
+		log.Printf("HandleExport: could not respond to interaction: %s", err)
+	}
+}
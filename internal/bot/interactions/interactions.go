@@ -1,8 +1,13 @@
 package interactions
 
 import (
+	"log"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
 	"github.com/angelajfisher/meeting-mate/internal/types"
 	"github.com/bwmarrin/discordgo"
 )
@@ -13,6 +18,42 @@ const (
 	CANCEL_COMMAND = "cancel"
 	STATUS_COMMAND = "status"
 	UPDATE_COMMAND = "update"
+	EXPORT_COMMAND = "export"
+	ROSTER_COMMAND = "roster"
+	DIGEST_COMMAND = "digest"
+	ADMIN_COMMAND  = "admin"
+	PERMS_COMMAND  = "permissions"
+	CONFIG_COMMAND = "config"
+
+	// Roster subcommands
+	ROSTER_ADD    = "add"
+	ROSTER_REMOVE = "remove"
+	ROSTER_VIEW   = "view"
+	ROSTER_CLEAR  = "clear"
+
+	// Digest subcommands
+	DIGEST_SET     = "set"
+	DIGEST_DISABLE = "disable"
+
+	// Permissions subcommands
+	PERMISSIONS_ALLOW = "allow"
+	PERMISSIONS_DENY  = "disallow"
+	PERMISSIONS_VIEW  = "view"
+
+	// Config subcommands
+	CONFIG_VIEW     = "view"
+	CONFIG_WATCHES  = "watches"
+	CONFIG_CHANNEL  = "channel"
+	CONFIG_TIMEZONE = "timezone"
+	CONFIG_LOCALE   = "locale"
+	CONFIG_MENTIONS = "mentions"
+	CONFIG_RESET    = "reset"
+
+	// Admin subcommands
+	ADMIN_STATS     = "stats"
+	ADMIN_WATCHES   = "watches"
+	ADMIN_CANCEL    = "cancel"
+	ADMIN_BROADCAST = "broadcast"
 
 	// Watch option flags
 	MEETING_OPT = "meeting_id"
@@ -20,24 +61,73 @@ const (
 	LINK_OPT    = "join_link"
 	SUMMARY_OPT = "summary"
 	HISTORY_OPT = "keep_history"
+	CHART_OPT   = "timeline"
+	CHANNEL_OPT = "channel"
+	NO_LINK     = "none" // Join link that removes a watch's link with `/update`
+
+	// Export option flags
+	FORMAT_OPT = "format"
+	FROM_OPT   = "from"
+	TO_OPT     = "to"
+
+	// Roster option flags
+	PEOPLE_OPT = "people"
+
+	// Digest option flags
+	DAY_OPT  = "day"
+	HOUR_OPT = "hour"
+
+	// Permissions option flags
+	ACTION_OPT = "action"
+	ROLE_OPT   = "role"
+
+	// Config option flags
+	ZONE_OPT   = "name"
+	LOCALE_OPT = "locale"
+	ROLES_OPT  = "roles"
+
+	// Admin option flags
+	GUILD_OPT   = "guild_id"
+	MESSAGE_OPT = "message"
+	START_OPT   = "start"
 )
 
+// Channels a watch can post its updates in
+var watchChannelTypes = []discordgo.ChannelType{
+	discordgo.ChannelTypeGuildText,
+	discordgo.ChannelTypeGuildNews,
+	discordgo.ChannelTypeGuildPublicThread,
+	discordgo.ChannelTypeGuildPrivateThread,
+}
+
 func InteractionList() []*discordgo.ApplicationCommand {
 	watchOptions := watchOptions()
-	return []*discordgo.ApplicationCommand{
+
+	// Who sees each command until a server's admins say otherwise under Server Settings > Integrations. Watches
+	// are for moderators; attendance and server-wide settings are for managers. `/status` is open to everyone.
+	var (
+		moderators int64 = discordgo.PermissionManageMessages
+		managers   int64 = discordgo.PermissionManageGuild
+	)
+	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        WATCH_COMMAND,
 			Description: "Begin watching a meeting's participant list",
 			Options:     watchOptions,
+
+			DefaultMemberPermissions: &moderators,
 		}, {
 			Name:        CANCEL_COMMAND,
 			Description: "Cancel the watch on a meeting",
+
+			DefaultMemberPermissions: &moderators,
 			Options: []*discordgo.ApplicationCommandOption{
 				{
 					Name:        MEETING_OPT,
 					Description: "ID of the Zoom meeting",
 					Type:        discordgo.ApplicationCommandOptionString,
 				},
+				targetChannelOption("cancel"),
 			},
 		}, {
 			Name:        STATUS_COMMAND,
@@ -45,9 +135,97 @@ func InteractionList() []*discordgo.ApplicationCommand {
 		}, {
 			Name:        UPDATE_COMMAND,
 			Description: "Update the options on an ongoing watch",
-			Options:     watchOptions,
+			Options:     updateOptions(),
+
+			DefaultMemberPermissions: &moderators,
+		}, {
+			Name:        EXPORT_COMMAND,
+			Description: "Download attendance records for a watched meeting",
+
+			DefaultMemberPermissions: &managers,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        MEETING_OPT,
+					Description: "ID of the Zoom meeting",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+				},
+				{
+					Name:        FORMAT_OPT,
+					Description: "File format of the export (default: csv)",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "CSV", Value: types.EXPORT_CSV},
+						{Name: "JSON", Value: types.EXPORT_JSON},
+					},
+				},
+				{
+					Name:        FROM_OPT,
+					Description: "First day to include, as YYYY-MM-DD (default: 30 days ago)",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+				{
+					Name:        TO_OPT,
+					Description: "Last day to include, as YYYY-MM-DD (default: today)",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		}, {
+			Name:        ROSTER_COMMAND,
+			Description: "Manage who is expected to attend a watched meeting",
+			Options:     rosterOptions(),
+
+			DefaultMemberPermissions: &moderators,
+		}, {
+			Name:        DIGEST_COMMAND,
+			Description: "Schedule a weekly digest of this server's watched meetings",
+			Options:     digestOptions(),
+
+			DefaultMemberPermissions: &managers,
+		}, {
+			Name:        PERMS_COMMAND,
+			Description: "Choose which roles can start, change, and cancel watches",
+			Options:     permissionsOptions(),
+
+			DefaultMemberPermissions: &managers,
+		}, {
+			Name:        CONFIG_COMMAND,
+			Description: "Choose this server's defaults for new watches and how times are shown",
+			Options:     configOptions(),
+
+			DefaultMemberPermissions: &managers,
 		},
 	}
+
+	// Watches belong to a server, so none of the commands are offered in DMs
+	guildOnly := []discordgo.InteractionContextType{discordgo.InteractionContextGuild}
+	for _, command := range commands {
+		command.Contexts = &guildOnly
+	}
+	return commands
+}
+
+// Turns away commands sent from a DM, which outdated clients may still offer
+func RefuseDM(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "Meeting Mate's commands only work in servers. " +
+				"Please use them in the channel you'd like meeting updates posted to.",
+			Flags: discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("RefuseDM: could not respond to interaction: %s", err)
+	}
+}
+
+// The user who sent an interaction, whether it came from a server or a DM
+func invoker(i *discordgo.InteractionCreate) *discordgo.User {
+	if i.Member != nil {
+		return i.Member.User
+	}
+	return i.User
 }
 
 type optionMap = map[string]*discordgo.ApplicationCommandInteractionDataOption
@@ -60,6 +238,45 @@ func ParseOptions(options []*discordgo.ApplicationCommandInteractionDataOption)
 	return om
 }
 
+// Works out which of a meeting's watches a command means: the one in the channel given, the only one there is, or
+// the one in the channel the command was used in. Explains how to pick if it can't tell.
+func watchedChannel(
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	opts optionMap,
+	meetingID string,
+) (channelID string, problem string) {
+	if v, ok := opts[CHANNEL_OPT]; ok {
+		return v.ChannelValue(nil).ID, ""
+	}
+
+	channels := o.GetWatchChannels(i.GuildID, meetingID)
+	if len(channels) == 1 {
+		return channels[0], ""
+	}
+	if len(channels) == 0 || slices.Contains(channels, i.ChannelID) {
+		return i.ChannelID, ""
+	}
+	return "", "Meeting ID `" + meetingID + "` is watched in " + mentionChannels(channels) +
+		". Please choose one with the `" + CHANNEL_OPT + "` option."
+}
+
+// Where a meeting isn't being watched: anywhere in the server, or just in the channel asked about
+func unwatchedIn(o *orchestrator.Orchestrator, guildID string, meetingID string, channelID string) string {
+	if len(o.GetWatchChannels(guildID, meetingID)) == 0 {
+		return "this server"
+	}
+	return "<#" + channelID + ">"
+}
+
+func mentionChannels(channelIDs []string) string {
+	mentions := make([]string, len(channelIDs))
+	for n, id := range channelIDs {
+		mentions[n] = "<#" + id + ">"
+	}
+	return strings.Join(mentions, ", ")
+}
+
 func watchOptions() []*discordgo.ApplicationCommandOption {
 	return []*discordgo.ApplicationCommandOption{
 		{
@@ -80,8 +297,14 @@ func watchOptions() []*discordgo.ApplicationCommandOption {
 		},
 		{
 			Name:        SUMMARY_OPT,
-			Description: "Display meeting stats after it ends (default: true)",
-			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Description: "How much detail to show in the stats after a meeting ends (default: Basic)",
+			Type:        discordgo.ApplicationCommandOptionString,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: types.NO_SUMMARY, Value: types.NO_SUMMARY},
+				{Name: types.BASIC_SUMMARY, Value: types.BASIC_SUMMARY},
+				{Name: types.DETAILED_SUMMARY, Value: types.DETAILED_SUMMARY},
+				{Name: types.FULL_SUMMARY, Value: types.FULL_SUMMARY},
+			},
 		},
 		{
 			Name:        HISTORY_OPT,
@@ -93,49 +316,302 @@ func watchOptions() []*discordgo.ApplicationCommandOption {
 				{Name: types.MINIMAL_HISTORY, Value: types.MINIMAL_HISTORY},
 			},
 		},
+		{
+			Name:        CHART_OPT,
+			Description: "Attach an attendance timeline chart to the summary (default: false)",
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+		},
+		{
+			Name:         CHANNEL_OPT,
+			Description:  "Channel to post updates in (default: the server's watch channel, or this one)",
+			Type:         discordgo.ApplicationCommandOptionChannel,
+			ChannelTypes: watchChannelTypes,
+		},
 	}
 }
 
-func generateWatchFlags(opts optionMap) types.FeatureFlags {
-	// Begin building new restart command
-	builder := new(strings.Builder)
-	builder.WriteString("```/watch meeting_id: " + opts[MEETING_OPT].StringValue())
+// The watch options, with the channel picking which watch to change rather than where a new one posts. Options
+// left out keep their current values, so none of them have defaults.
+func updateOptions() []*discordgo.ApplicationCommandOption {
+	options := watchOptions()
+	for n, option := range options {
+		option.Description, _, _ = strings.Cut(option.Description, " (default:")
+		switch option.Name {
+		case LINK_OPT:
+			option.Description += `, or "` + NO_LINK + `" to remove it`
+		case CHANNEL_OPT:
+			options[n] = targetChannelOption("update")
+		}
+	}
+	return options
+}
+
+// Picks out one of a meeting's watches when it's watched from more than one channel
+func targetChannelOption(verb string) *discordgo.ApplicationCommandOption {
+	return &discordgo.ApplicationCommandOption{
+		Name:         CHANNEL_OPT,
+		Description:  "Channel of the watch to " + verb + ", if the meeting is watched in several (default: this one)",
+		Type:         discordgo.ApplicationCommandOptionChannel,
+		ChannelTypes: watchChannelTypes,
+	}
+}
+
+func rosterOptions() []*discordgo.ApplicationCommandOption {
+	meetingOpt := &discordgo.ApplicationCommandOption{
+		Name:        MEETING_OPT,
+		Description: "ID of the Zoom meeting",
+		Type:        discordgo.ApplicationCommandOptionString,
+		Required:    true,
+	}
+	peopleOpt := &discordgo.ApplicationCommandOption{
+		Name:        PEOPLE_OPT,
+		Description: "Comma-separated names or emails",
+		Type:        discordgo.ApplicationCommandOptionString,
+		Required:    true,
+	}
+
+	channelOpt := targetChannelOption("use")
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Name:        ROSTER_ADD,
+			Description: "Add people to the roster",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     []*discordgo.ApplicationCommandOption{meetingOpt, peopleOpt, channelOpt},
+		},
+		{
+			Name:        ROSTER_REMOVE,
+			Description: "Remove people from the roster",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     []*discordgo.ApplicationCommandOption{meetingOpt, peopleOpt, channelOpt},
+		},
+		{
+			Name:        ROSTER_VIEW,
+			Description: "Show the roster",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     []*discordgo.ApplicationCommandOption{meetingOpt, channelOpt},
+		},
+		{
+			Name:        ROSTER_CLEAR,
+			Description: "Remove everyone from the roster",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     []*discordgo.ApplicationCommandOption{meetingOpt, channelOpt},
+		},
+	}
+}
+
+func digestOptions() []*discordgo.ApplicationCommandOption {
+	minHour, maxHour := 0.0, 23.0
+	days := make([]*discordgo.ApplicationCommandOptionChoice, 0, 7)
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		days = append(days, &discordgo.ApplicationCommandOptionChoice{Name: day.String(), Value: int(day)})
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Name:        DIGEST_SET,
+			Description: "Choose when and where the digest is posted",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        DAY_OPT,
+					Description: "Day of the week to post the digest",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Required:    true,
+					Choices:     days,
+				},
+				{
+					Name:        HOUR_OPT,
+					Description: "Hour of the day to post the digest, in the server's timezone (0-23)",
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Required:    true,
+					MinValue:    &minHour,
+					MaxValue:    maxHour,
+				},
+				{
+					Name:         CHANNEL_OPT,
+					Description:  "Channel to post the digest in (default: this channel)",
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				},
+			},
+		},
+		{
+			Name:        DIGEST_DISABLE,
+			Description: "Stop posting the digest",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+		},
+	}
+}
 
-	// Store flag choices
+func configOptions() []*discordgo.ApplicationCommandOption {
+	// The same choices as a watch, but none of them required
+	watchDefaults := []*discordgo.ApplicationCommandOption{}
+	for _, option := range watchOptions() {
+		if option.Name != MEETING_OPT && option.Name != LINK_OPT && option.Name != CHANNEL_OPT {
+			watchDefaults = append(watchDefaults, option)
+		}
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Name:        CONFIG_VIEW,
+			Description: "Show this server's settings",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+		},
+		{
+			Name:        CONFIG_WATCHES,
+			Description: "Set the options new watches start with. Options left out stay as they are",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     watchDefaults,
+		},
+		{
+			Name:        CONFIG_CHANNEL,
+			Description: "Set where new watches post their updates",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:         CHANNEL_OPT,
+					Description:  "Channel for new watches (default: wherever /watch is used)",
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					ChannelTypes: watchChannelTypes,
+				},
+			},
+		},
+		{
+			Name:        CONFIG_TIMEZONE,
+			Description: "Set the timezone for timeline charts and digests",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        ZONE_OPT,
+					Description: "Timezone name, like America/New_York or UTC",
+					Type:        discordgo.ApplicationCommandOptionString,
+					Required:    true,
+				},
+			},
+		},
+		{
+			Name:        CONFIG_LOCALE,
+			Description: "Set the locale used to write times of day",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        LOCALE_OPT,
+					Description: "Discord locale, like en-US or de (default: Discord's default)",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		},
+		{
+			Name:        CONFIG_MENTIONS,
+			Description: "Set the roles pinged when a watched meeting starts",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        ROLES_OPT,
+					Description: "Roles to ping, like @Team @Leads (default: nobody)",
+					Type:        discordgo.ApplicationCommandOptionString,
+				},
+			},
+		},
+		{
+			Name:        CONFIG_RESET,
+			Description: "Return every setting to its default",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+		},
+	}
+}
+
+func permissionsOptions() []*discordgo.ApplicationCommandOption {
+	actions := []*discordgo.ApplicationCommandOptionChoice{
+		{Name: "Start watches", Value: types.CREATE_ACTION},
+		{Name: "Change watches", Value: types.MODIFY_ACTION},
+		{Name: "Cancel watches", Value: types.CANCEL_ACTION},
+	}
+	roleOptions := []*discordgo.ApplicationCommandOption{
+		{
+			Name:        ACTION_OPT,
+			Description: "What the role can do",
+			Type:        discordgo.ApplicationCommandOptionString,
+			Required:    true,
+			Choices:     actions,
+		},
+		{
+			Name:        ROLE_OPT,
+			Description: "The role",
+			Type:        discordgo.ApplicationCommandOptionRole,
+			Required:    true,
+		},
+	}
+
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Name:        PERMISSIONS_ALLOW,
+			Description: "Let a role take an action. Once any role is allowed, everyone else is refused",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     roleOptions,
+		},
+		{
+			Name:        PERMISSIONS_DENY,
+			Description: "Stop letting a role take an action",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+			Options:     roleOptions,
+		},
+		{
+			Name:        PERMISSIONS_VIEW,
+			Description: "Show which roles can take each action",
+			Type:        discordgo.ApplicationCommandOptionSubCommand,
+		},
+	}
+}
+
+// Works out the options of a watch posting to channelID from those given with the command, keeping the base options
+// for any left out: the server's defaults for a new watch, or the watch's current options for `/update`
+func generateWatchFlags(opts optionMap, channelID string, base types.FeatureFlags) types.FeatureFlags {
 	flags := types.FeatureFlags{
-		Silent: func() bool {
-			if v, exists := opts[SILENT_OPT]; exists && !v.BoolValue() {
-				builder.WriteString(" " + SILENT_OPT + ": false")
-				return false
-			}
-			return true
-		}(),
-		JoinLink: func() string {
-			if v, exists := opts[LINK_OPT]; exists && v.StringValue() != "" {
-				builder.WriteString(" " + LINK_OPT + ": " + v.StringValue())
-				return v.StringValue()
-			}
-			return ""
-		}(),
-		Summaries: func() bool {
-			if v, exists := opts[SUMMARY_OPT]; exists && !v.BoolValue() {
-				builder.WriteString(" " + SUMMARY_OPT + ": false")
-				return false
-			}
-			return true
-		}(),
-		HistoryLevel: func() string {
-			if v, exists := opts[HISTORY_OPT]; exists && v.StringValue() != types.PARTIAL_HISTORY {
-				builder.WriteString(" " + HISTORY_OPT + ": " + v.StringValue())
-				return v.StringValue()
-			}
-			return types.PARTIAL_HISTORY
-		}(),
-		RestartCommand: func() string {
-			builder.WriteString("```")
-			return builder.String()
-		}(),
+		Silent:        base.Silent,
+		JoinLink:      base.JoinLink,
+		SummaryLevel:  base.SummaryLevel,
+		HistoryLevel:  base.HistoryLevel,
+		TimelineChart: base.TimelineChart,
+	}
+	if v, exists := opts[SILENT_OPT]; exists {
+		flags.Silent = v.BoolValue()
+	}
+	if v, exists := opts[LINK_OPT]; exists {
+		flags.JoinLink = v.StringValue()
+		if strings.EqualFold(flags.JoinLink, NO_LINK) {
+			flags.JoinLink = ""
+		}
+	}
+	if v, exists := opts[SUMMARY_OPT]; exists {
+		flags.SummaryLevel = v.StringValue()
+	}
+	if v, exists := opts[HISTORY_OPT]; exists {
+		flags.HistoryLevel = v.StringValue()
 	}
+	if v, exists := opts[CHART_OPT]; exists {
+		flags.TimelineChart = v.BoolValue()
+	}
+	flags.RestartCommand = restartCommand(opts[MEETING_OPT].StringValue(), channelID, flags)
 
 	return flags
 }
+
+// Builds the `/watch` command that recreates a watch in the same channel with the given options. The channel and
+// every option are spelled out, since any left out would be filled in from the server's defaults at the time the
+// command is used. A watch without a join link has none to spell out, and no server default can add one.
+func restartCommand(meetingID string, channelID string, flags types.FeatureFlags) string {
+	builder := new(strings.Builder)
+	builder.WriteString("```/watch meeting_id: " + meetingID + " " + CHANNEL_OPT + ": <#" + channelID + ">")
+	builder.WriteString(" " + SILENT_OPT + ": " + strconv.FormatBool(flags.Silent))
+	if flags.JoinLink != "" {
+		builder.WriteString(" " + LINK_OPT + ": " + flags.JoinLink)
+	}
+	builder.WriteString(" " + SUMMARY_OPT + ": " + flags.SummaryLevel)
+	builder.WriteString(" " + HISTORY_OPT + ": " + flags.HistoryLevel)
+	builder.WriteString(" " + CHART_OPT + ": " + strconv.FormatBool(flags.TimelineChart))
+	builder.WriteString("```")
+	return builder.String()
+}
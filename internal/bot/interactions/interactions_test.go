@@ -0,0 +1,107 @@
+package interactions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Reads a restart command back into the options Discord would hand /watch if it were pasted in
+func parseRestartCommand(t *testing.T, command string) optionMap {
+	t.Helper()
+
+	command, found := strings.CutPrefix(strings.Trim(command, "`"), "/watch ")
+	if !found {
+		t.Fatalf("%q isn't a /watch command", command)
+	}
+	fields := strings.Fields(command)
+	if len(fields)%2 != 0 {
+		t.Fatalf("%q doesn't pair every option with a value", command)
+	}
+
+	opts := make(optionMap)
+	for n := 0; n < len(fields); n += 2 {
+		name, found := strings.CutSuffix(fields[n], ":")
+		if !found {
+			t.Fatalf("expected an option name in %q, found %q", command, fields[n])
+		}
+		if _, repeated := opts[name]; repeated {
+			t.Fatalf("%q sets %s more than once", command, name)
+		}
+
+		option := &discordgo.ApplicationCommandInteractionDataOption{Name: name, Value: fields[n+1]}
+		switch name {
+		case SILENT_OPT, CHART_OPT:
+			option.Type = discordgo.ApplicationCommandOptionBoolean
+			option.Value = fields[n+1] == "true"
+		case CHANNEL_OPT:
+			option.Type = discordgo.ApplicationCommandOptionChannel
+			option.Value = strings.TrimSuffix(strings.TrimPrefix(fields[n+1], "<#"), ">")
+		default:
+			option.Type = discordgo.ApplicationCommandOptionString
+		}
+		opts[name] = option
+	}
+	return opts
+}
+
+// Pasting a watch's restart command back in must recreate the same watch, whatever the server's defaults are by then
+func TestRestartCommandRoundTrip(t *testing.T) {
+	builtIn := db.DefaultGuildSettings("g1").Defaults
+	custom := types.FeatureFlags{
+		Silent:        !builtIn.Silent,
+		SummaryLevel:  types.FULL_SUMMARY,
+		HistoryLevel:  types.MINIMAL_HISTORY,
+		TimelineChart: !builtIn.TimelineChart,
+	}
+	if custom.SummaryLevel == builtIn.SummaryLevel || custom.HistoryLevel == builtIn.HistoryLevel {
+		t.Fatal("custom defaults must differ from the built-in ones in every option")
+	}
+
+	watches := []struct {
+		name  string
+		flags types.FeatureFlags
+	}{
+		{"built-in defaults", builtIn},
+		{"custom defaults", custom},
+		{"join link", types.FeatureFlags{
+			Silent:       false,
+			JoinLink:     "https://zoom.us/j/123456789?pwd=abc",
+			SummaryLevel: types.NO_SUMMARY,
+			HistoryLevel: types.FULL_HISTORY,
+		}},
+	}
+	// The server's defaults when the command is pasted back in, which may have changed since it was generated
+	defaults := []struct {
+		name  string
+		flags types.FeatureFlags
+	}{
+		{"built-in", builtIn},
+		{"custom", custom},
+	}
+
+	for _, watch := range watches {
+		command := restartCommand("123456789", "c1", watch.flags)
+		for _, pasted := range defaults {
+			t.Run(watch.name+" pasted with "+pasted.name+" defaults", func(t *testing.T) {
+				opts := parseRestartCommand(t, command)
+				if got := opts[MEETING_OPT].StringValue(); got != "123456789" {
+					t.Errorf("meeting ID = %q, want 123456789", got)
+				}
+				if got := opts[CHANNEL_OPT].Value; got != "c1" {
+					t.Errorf("channel = %q, want c1", got)
+				}
+
+				got := generateWatchFlags(opts, "c1", pasted.flags)
+				want := watch.flags
+				want.RestartCommand = command
+				if got != want {
+					t.Errorf("%s recreated the watch with\n%+v\nwant\n%+v", command, got, want)
+				}
+			})
+		}
+	}
+}
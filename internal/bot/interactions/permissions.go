@@ -0,0 +1,132 @@
+package interactions
+
+import (
+	"log"
+	"strings"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"github.com/bwmarrin/discordgo"
+)
+
+type permission struct {
+	bit    int64
+	name   string // As shown in Discord's settings
+	reason string
+}
+
+var (
+	viewChannelPerm = permission{
+		bit: discordgo.PermissionViewChannel, name: "View Channel", reason: "to see the channel",
+	}
+	sendMessagesPerm = permission{
+		bit: discordgo.PermissionSendMessages, name: "Send Messages", reason: "to post meeting updates",
+	}
+	embedLinksPerm = permission{
+		bit: discordgo.PermissionEmbedLinks, name: "Embed Links", reason: "to show meeting details",
+	}
+	manageMessagesPerm = permission{
+		bit: discordgo.PermissionManageMessages, name: "Manage Messages", reason: "to remove old meeting messages",
+	}
+	threadMessagesPerm = permission{
+		bit: discordgo.PermissionSendMessagesInThreads, name: "Send Messages in Threads",
+		reason: "to post meeting updates in a thread",
+	}
+)
+
+// Checks that the bot can run a watch with the given options in a channel. Returns a message explaining what's
+// missing, or an empty string if nothing is. If the bot's permissions can't be looked up, the watch is let through
+// so a Discord hiccup doesn't block it.
+func permissionProblem(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	channelID string,
+	flags types.FeatureFlags,
+) string {
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		if channel, err = s.Channel(channelID); err != nil {
+			log.Printf("could not look up channel ID %s to check permissions: %s", channelID, err)
+			return ""
+		}
+	}
+
+	// Interactions carry the bot's permissions in the channel they were used in; other channels are worked out
+	granted := i.AppPermissions
+	if channelID != i.ChannelID {
+		target := channelID
+		if channel.IsThread() {
+			target = channel.ParentID // Threads follow the permissions of the channel they're in
+		}
+		if granted, err = s.UserChannelPermissions(s.State.User.ID, target); err != nil {
+			log.Printf("could not look up permissions in channel ID %s: %s", channelID, err)
+			return ""
+		}
+	}
+
+	required := []permission{viewChannelPerm, embedLinksPerm}
+	if channel.IsThread() {
+		required = append(required, threadMessagesPerm)
+	} else {
+		required = append(required, sendMessagesPerm)
+	}
+	if flags.HistoryLevel != types.FULL_HISTORY {
+		required = append(required, manageMessagesPerm)
+	}
+
+	missing := new(strings.Builder)
+	for _, perm := range required {
+		if granted&perm.bit != perm.bit {
+			missing.WriteString("\n- **" + perm.name + "** (" + perm.reason + ")")
+		}
+	}
+	if missing.Len() == 0 {
+		return ""
+	}
+	return "Meeting Mate is missing the following permissions in <#" + channelID + ">:" + missing.String() +
+		"\n\nPlease ask a server admin to grant them, then try again."
+}
+
+// Checks that whoever used the command can see and post in the channel they asked a watch to post in, so the
+// option can't be used to post somewhere they couldn't themselves. Returns a message explaining what's missing, or an
+// empty string if nothing is. Unlike the bot's own permissions, a failed lookup refuses the watch.
+func invokerProblem(s *discordgo.Session, i *discordgo.InteractionCreate, channelID string) string {
+	if i.Member == nil || i.Member.User == nil {
+		return "Watches can only be started from within a server."
+	}
+
+	channel, err := s.State.Channel(channelID)
+	if err != nil {
+		channel, err = s.Channel(channelID)
+	}
+	// Interactions carry the invoker's permissions in the channel they were used in; other channels are worked out
+	granted := i.Member.Permissions
+	if err == nil && channelID != i.ChannelID {
+		target := channelID
+		if channel.IsThread() {
+			target = channel.ParentID // Threads follow the permissions of the channel they're in
+		}
+		granted, err = s.UserChannelPermissions(i.Member.User.ID, target)
+	}
+	if err != nil {
+		log.Printf("could not look up %s's permissions in channel ID %s: %s", invoker(i), channelID, err)
+		return "Could not check your permissions in <#" + channelID + ">. Please try again later."
+	}
+
+	if granted&discordgo.PermissionAdministrator != 0 {
+		return ""
+	}
+	required := []permission{viewChannelPerm, sendMessagesPerm}
+	if channel.IsThread() {
+		required[1] = threadMessagesPerm
+	}
+	missing := new(strings.Builder)
+	for _, perm := range required {
+		if granted&perm.bit != perm.bit {
+			missing.WriteString("\n- **" + perm.name + "**")
+		}
+	}
+	if missing.Len() == 0 {
+		return ""
+	}
+	return "You need the following permissions in <#" + channelID + "> to start a watch there:" + missing.String()
+}
@@ -0,0 +1,107 @@
+package interactions
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestInvokerProblem(t *testing.T) {
+	const (
+		guildID = "g1"
+		adminID = "r-admin"
+	)
+	viewAndSend := int64(discordgo.PermissionViewChannel | discordgo.PermissionSendMessages)
+
+	s, err := discordgo.New("Bot token")
+	if err != nil {
+		t.Fatalf("discordgo.New: %s", err)
+	}
+	err = s.State.GuildAdd(&discordgo.Guild{
+		ID:      guildID,
+		OwnerID: "owner",
+		Roles: []*discordgo.Role{
+			{ID: guildID, Permissions: viewAndSend}, // @everyone
+			{ID: adminID, Permissions: discordgo.PermissionAdministrator},
+		},
+	})
+	if err != nil {
+		t.Fatalf("GuildAdd: %s", err)
+	}
+	channels := []*discordgo.Channel{
+		{ID: "open", GuildID: guildID, Type: discordgo.ChannelTypeGuildText},
+		{
+			ID: "hidden", GuildID: guildID, Type: discordgo.ChannelTypeGuildText,
+			PermissionOverwrites: []*discordgo.PermissionOverwrite{
+				{ID: guildID, Type: discordgo.PermissionOverwriteTypeRole, Deny: discordgo.PermissionViewChannel},
+			},
+		},
+		{
+			ID: "read-only", GuildID: guildID, Type: discordgo.ChannelTypeGuildText,
+			PermissionOverwrites: []*discordgo.PermissionOverwrite{
+				{ID: guildID, Type: discordgo.PermissionOverwriteTypeRole, Deny: discordgo.PermissionSendMessages},
+			},
+		},
+		{ID: "thread", GuildID: guildID, ParentID: "hidden", Type: discordgo.ChannelTypeGuildPublicThread},
+	}
+	for _, channel := range channels {
+		if err = s.State.ChannelAdd(channel); err != nil {
+			t.Fatalf("ChannelAdd: %s", err)
+		}
+	}
+	member := func(userID string, roles ...string) *discordgo.Member {
+		m := &discordgo.Member{GuildID: guildID, User: &discordgo.User{ID: userID}, Roles: roles}
+		if err := s.State.MemberAdd(m); err != nil {
+			t.Fatalf("MemberAdd: %s", err)
+		}
+		return m
+	}
+	regular, admin := member("u1"), member("u2", adminID)
+
+	tests := []struct {
+		name      string
+		member    *discordgo.Member
+		channelID string
+		missing   []string // Empty if the watch is allowed
+	}{
+		{"open channel", regular, "open", nil},
+		{"hidden channel", regular, "hidden", []string{viewChannelPerm.name}},
+		{"read-only channel", regular, "read-only", []string{sendMessagesPerm.name}},
+		{"thread in a hidden channel", regular, "thread", []string{viewChannelPerm.name, threadMessagesPerm.name}},
+		{"administrator in a hidden channel", admin, "hidden", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Used from a channel where the invoker can't post, so their permissions there don't count
+			i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+				GuildID:   guildID,
+				ChannelID: "elsewhere",
+				Member:    &discordgo.Member{User: test.member.User, Roles: test.member.Roles},
+			}}
+
+			problem := invokerProblem(s, i, test.channelID)
+			if len(test.missing) == 0 {
+				if problem != "" {
+					t.Errorf("refused with %q", problem)
+				}
+				return
+			}
+			for _, name := range test.missing {
+				if !strings.Contains(problem, "**"+name+"**") {
+					t.Errorf("%q doesn't mention %s", problem, name)
+				}
+			}
+		})
+	}
+
+	// The interaction's own permissions are used for the channel it came from
+	i := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		GuildID:   guildID,
+		ChannelID: "hidden",
+		Member:    &discordgo.Member{User: regular.User, Permissions: viewAndSend},
+	}}
+	if problem := invokerProblem(s, i, "hidden"); problem != "" {
+		t.Errorf("refused in the channel the command was used in: %q", problem)
+	}
+}
@@ -0,0 +1,145 @@
+package interactions
+
+import (
+	"log"
+	"strings"
+
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"github.com/bwmarrin/discordgo"
+)
+
+// How each action is described to someone who isn't allowed to take it
+var actionVerbs = map[string]string{
+	types.CREATE_ACTION: "start",
+	types.MODIFY_ACTION: "change",
+	types.CANCEL_ACTION: "cancel",
+}
+
+// Checks that the user may take an action on this server's watches, turning them away if not. Server managers can
+// always act; everyone else needs one of the roles allowed with `/permissions`, if any have been.
+func checkRoles(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	action string,
+) bool {
+	if i.Member == nil {
+		return false
+	}
+	if i.Member.Permissions&(discordgo.PermissionAdministrator|discordgo.PermissionManageGuild) != 0 {
+		return true
+	}
+
+	var response string
+	roles, err := o.Database.GetCommandRoles(i.GuildID)
+	if err != nil {
+		log.Printf("checkRoles: %s", err)
+		response = "Could not check your roles. Please try again later."
+	} else if allowed := roles[action]; len(allowed) == 0 || hasAnyRole(i.Member, allowed) {
+		return true
+	} else {
+		response = "You need one of these roles to " + actionVerbs[action] + " watches in this server: " +
+			mentionRoles(allowed) + "."
+	}
+
+	log.Printf("%s: refused %s in %s", invoker(i), action, i.GuildID)
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         response,
+			Flags:           discordgo.MessageFlagsEphemeral,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	if err != nil {
+		log.Printf("checkRoles: could not respond to interaction: %s", err)
+	}
+	return false
+}
+
+func hasAnyRole(member *discordgo.Member, roleIDs []string) bool {
+	for _, role := range member.Roles {
+		for _, allowed := range roleIDs {
+			if role == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mentionRoles(roleIDs []string) string {
+	mentions := make([]string, len(roleIDs))
+	for n, id := range roleIDs {
+		mentions[n] = "<@&" + id + ">"
+	}
+	return strings.Join(mentions, ", ")
+}
+
+// Handles the `/permissions` command and its subcommands
+func HandlePermissions(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	subcommand *discordgo.ApplicationCommandInteractionDataOption,
+) {
+	log.Printf("%s: /permissions %s in %s", invoker(i), subcommand.Name, i.GuildID)
+
+	opts := ParseOptions(subcommand.Options)
+	var response string
+	switch subcommand.Name {
+	case PERMISSIONS_ALLOW, PERMISSIONS_DENY:
+		action := opts[ACTION_OPT].StringValue()
+		role := opts[ROLE_OPT].RoleValue(nil, i.GuildID)
+		var err error
+		if subcommand.Name == PERMISSIONS_ALLOW {
+			err = o.AddCommandRole(i.GuildID, action, role.ID)
+			response = "<@&" + role.ID + "> can now " + actionVerbs[action] + " watches."
+		} else {
+			err = o.RemoveCommandRole(i.GuildID, action, role.ID)
+			response = "<@&" + role.ID + "> can no longer " + actionVerbs[action] + " watches."
+		}
+		if err != nil {
+			log.Printf("HandlePermissions: %s", err)
+			response = "Could not save the change. Please try again later."
+			break
+		}
+		response += "\n\n" + describeRoles(o, i.GuildID)
+	default:
+		response = describeRoles(o, i.GuildID)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content:         response,
+			Flags:           discordgo.MessageFlagsEphemeral,
+			AllowedMentions: &discordgo.MessageAllowedMentions{},
+		},
+	})
+	if err != nil {
+		log.Printf("HandlePermissions: could not respond to interaction: %s", err)
+	}
+}
+
+// Lists who may take each action on this server's watches
+func describeRoles(o *orchestrator.Orchestrator, guildID string) string {
+	roles, err := o.Database.GetCommandRoles(guildID)
+	if err != nil {
+		log.Printf("HandlePermissions: %s", err)
+		return "Could not look up this server's permissions. Please try again later."
+	}
+
+	builder := new(strings.Builder)
+	builder.WriteString("Server managers can always manage watches. Besides them:")
+	for _, action := range []string{types.CREATE_ACTION, types.MODIFY_ACTION, types.CANCEL_ACTION} {
+		builder.WriteString("\n- " + strings.ToUpper(actionVerbs[action][:1]) + actionVerbs[action][1:] + ": ")
+		if len(roles[action]) == 0 {
+			builder.WriteString("anyone who can use the command")
+		} else {
+			builder.WriteString(mentionRoles(roles[action]))
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,125 @@
+package interactions
+
+import (
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Handles the `/roster` command and its subcommands
+func HandleRoster(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	subcommand *discordgo.ApplicationCommandInteractionDataOption,
+) {
+	opts := ParseOptions(subcommand.Options)
+	meetingID := opts[MEETING_OPT].StringValue()
+	log.Printf("%s: /roster %s ID %s in %s", invoker(i), subcommand.Name, meetingID, i.GuildID)
+	if subcommand.Name != ROSTER_VIEW && !checkRoles(s, i, o, types.MODIFY_ACTION) {
+		return
+	}
+
+	channelID, response := watchedChannel(i, o, opts, meetingID)
+	switch {
+	case response != "":
+	case !o.IsOngoingWatch(i.GuildID, meetingID, channelID):
+		response = "Nothing to change: meeting ID `" + meetingID + "` isn't being watched in " +
+			unwatchedIn(o, i.GuildID, meetingID, channelID) + "."
+	default:
+		roster := o.GetRoster(i.GuildID, meetingID, channelID)
+
+		var err error
+		switch subcommand.Name {
+		case ROSTER_ADD:
+			for _, entry := range parseRosterEntries(opts[PEOPLE_OPT].StringValue()) {
+				if !slices.ContainsFunc(roster, func(e string) bool { return strings.EqualFold(e, entry) }) {
+					roster = append(roster, entry)
+				}
+			}
+			err = o.SetRoster(i.GuildID, meetingID, channelID, roster)
+		case ROSTER_REMOVE:
+			for _, entry := range parseRosterEntries(opts[PEOPLE_OPT].StringValue()) {
+				roster = slices.DeleteFunc(roster, func(e string) bool { return strings.EqualFold(e, entry) })
+			}
+			err = o.SetRoster(i.GuildID, meetingID, channelID, roster)
+		case ROSTER_CLEAR:
+			roster = nil
+			err = o.SetRoster(i.GuildID, meetingID, channelID, roster)
+		}
+
+		if err != nil {
+			log.Printf("HandleRoster: %s", err)
+			response = "Could not save the roster for meeting ID `" + meetingID + "`. Please try again later."
+		} else if len(roster) == 0 {
+			response = "The roster for meeting ID `" + meetingID + "` is empty."
+		} else {
+			response = "The roster for meeting ID `" + meetingID + "` expects:\n- " + strings.Join(roster, "\n- ")
+		}
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: response,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("HandleRoster: could not respond to interaction: %s", err)
+	}
+}
+
+// Splits a comma- or newline-separated list of names and emails into individual entries
+func parseRosterEntries(list string) []string {
+	entries := []string{}
+	for _, entry := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == '\n' || r == ';' }) {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// Builds the embed field comparing the roster against who's currently in the meeting
+func rosterStatusField(roster []string, present []types.AttendanceRecord) *discordgo.MessageEmbedField {
+	if len(roster) == 0 {
+		return nil
+	}
+
+	report := types.CheckRoster(roster, present, time.Time{})
+	builder := new(strings.Builder)
+	builder.WriteString("Present: " + strconv.Itoa(len(report.Present)) + "/" + strconv.Itoa(len(roster)))
+	if len(report.Present) > 0 {
+		builder.WriteString(" (" + strings.Join(report.Present, ", ") + ")")
+	}
+	if len(report.Missing) > 0 {
+		builder.WriteString("\nMissing: " + strings.Join(report.Missing, ", "))
+	}
+
+	return &discordgo.MessageEmbedField{Name: "Roster", Value: truncateText(builder.String(), maxFieldLength)}
+}
+
+// Builds the embed field listing who on the roster never showed up or arrived late
+func rosterSummaryField(roster []string, summary types.MeetingSummary) *discordgo.MessageEmbedField {
+	if len(roster) == 0 {
+		return nil
+	}
+
+	report := types.CheckRoster(roster, summary.Attendance, summary.Start)
+	lines := []string{"Attended: " + strconv.Itoa(len(report.Present)) + "/" + strconv.Itoa(len(roster))}
+	if len(report.Missing) > 0 {
+		lines = append(lines, "No-shows: "+strings.Join(report.Missing, ", "))
+	}
+	if len(report.Late) > 0 {
+		lines = append(lines, "Late arrivals: "+strings.Join(report.Late, ", "))
+	}
+
+	return &discordgo.MessageEmbedField{Name: "Roster", Value: truncateText(strings.Join(lines, "\n"), maxFieldLength)}
+}
@@ -2,13 +2,14 @@ package interactions
 
 import (
 	"log"
+	"strconv"
 	"strings"
 
 	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
 	"github.com/bwmarrin/discordgo"
 )
 
-func HandleStatus(s *discordgo.Session, i *discordgo.InteractionCreate, o orchestrator.Orchestrator) {
+func HandleStatus(s *discordgo.Session, i *discordgo.InteractionCreate, o *orchestrator.Orchestrator) {
 	var (
 		activeWatches = o.GetGuildMeetings(i.GuildID)
 		response      string
@@ -19,19 +20,18 @@ func HandleStatus(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 	} else {
 		builder := new(strings.Builder)
 		meetingIDs := make([]string, len(activeWatches))
-		i := 0
+		n := 0
 		for _, id := range activeWatches {
-			meetingIDs[i] = id
-			i++
+			meetingIDs[n] = id
+			n++
 		}
 		if len(activeWatches) == 1 {
-			builder.WriteString("There is an ongoing watch on meeting ID `" + meetingIDs[0])
+			builder.WriteString("There is an ongoing watch on meeting ID `" + meetingIDs[0] + "`")
 			meetingName := o.GetMeetingName(meetingIDs[0])
 			if meetingName != "" {
-				builder.WriteString("` (" + meetingName + ").")
-			} else {
-				builder.WriteString("`.")
+				builder.WriteString(" (" + meetingName + ")")
 			}
+			builder.WriteString(" in " + mentionChannels(o.GetWatchChannels(i.GuildID, meetingIDs[0])) + ".")
 		} else {
 			builder.WriteString("The following meeting IDs have ongoing watches:")
 			for _, id := range meetingIDs {
@@ -40,11 +40,16 @@ func HandleStatus(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 				if meetingName != "" {
 					builder.WriteString(" (" + meetingName + ")")
 				}
+				builder.WriteString(" in " + mentionChannels(o.GetWatchChannels(i.GuildID, id)))
 			}
 		}
 		response = builder.String()
 	}
 
+	if o.Cluster.Clustered() {
+		response += "\n\n" + clusterStatus(o)
+	}
+
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
@@ -56,3 +61,29 @@ func HandleStatus(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 		log.Printf("HandleStatus: could not respond to interaction: %s", err)
 	}
 }
+
+// Describes which node in the cluster is answering and which of the others it can reach
+func clusterStatus(o *orchestrator.Orchestrator) string {
+	members := o.Cluster.Members()
+	reachable := 0
+	nodes := make([]string, 0, len(members))
+	for _, member := range members {
+		name := member.NodeID
+		if name == "" {
+			name = member.Address // Peers that have never answered are only known by address
+		}
+		switch {
+		case !member.Reachable:
+			nodes = append(nodes, "~~"+name+"~~")
+		case member.Leader:
+			reachable++
+			nodes = append(nodes, "**`"+name+"`**")
+		default:
+			reachable++
+			nodes = append(nodes, "`"+name+"`")
+		}
+	}
+
+	return "-# Active node: `" + o.Cluster.Leader() + "` · " + strconv.Itoa(reachable) + "/" +
+		strconv.Itoa(len(members)) + " nodes up: " + strings.Join(nodes, ", ")
+}
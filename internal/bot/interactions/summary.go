@@ -0,0 +1,96 @@
+package interactions
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord rejects embed field values longer than this
+const maxFieldLength = 1024
+
+// Builds the embed fields shown once a meeting ends, according to the watch's summary level
+func summaryFields(level string, summary types.MeetingSummary, duration string) []*discordgo.MessageEmbedField {
+	if level == types.NO_SUMMARY || level == "" {
+		return nil
+	}
+
+	stats := new(strings.Builder)
+	fmt.Fprintf(stats, "Duration: %s\nTotal Participants: %d\nPeak Participants: %d",
+		duration, summary.UniqueParticipants, summary.PeakParticipants)
+
+	if level == types.DETAILED_SUMMARY || level == types.FULL_SUMMARY {
+		fmt.Fprintf(stats, "\nReconnects: %d", summary.Reconnects)
+		if summary.FirstPresent != "" {
+			stats.WriteString("\nFirst to Arrive: " + summary.FirstPresent)
+		}
+		if summary.LastPresent != "" {
+			stats.WriteString("\nLast to Leave: " + summary.LastPresent)
+		}
+	}
+
+	fields := []*discordgo.MessageEmbedField{{Name: "Summary", Value: stats.String()}}
+
+	if level == types.FULL_SUMMARY && len(summary.Attendance) > 0 {
+		attendees := make([]string, 0, len(summary.Attendance))
+		for _, record := range summary.Attendance {
+			line := record.Name + " — " + formatDuration(record.TimePresent)
+			if len(record.Sessions) > 1 {
+				line += fmt.Sprintf(" (%d sessions)", len(record.Sessions))
+			}
+			attendees = append(attendees, line)
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Attendees",
+			Value: truncateLines(attendees, maxFieldLength),
+		})
+	}
+
+	return fields
+}
+
+// Rounds a duration to the nearest minute for display, showing seconds only for very short stays
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return d.Round(time.Second).String()
+	}
+	return strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+}
+
+// Joins lines with newlines, cutting the list short with a count of what's missing if it won't fit within limit
+func truncateLines(lines []string, limit int) string {
+	full := strings.Join(lines, "\n")
+	if len(full) <= limit {
+		return full
+	}
+
+	// Leave room for the "…and N more" note
+	limit -= len("\n…and 00000 more")
+
+	builder := new(strings.Builder)
+	for i, line := range lines {
+		if builder.Len()+len(line) > limit {
+			fmt.Fprintf(builder, "…and %d more", len(lines)-i)
+			break
+		}
+		builder.WriteString(line + "\n")
+	}
+	return builder.String()
+}
+
+// Cuts text off with an ellipsis if it's longer than limit bytes
+func truncateText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+
+	limit -= len("…")
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit] + "…"
+}
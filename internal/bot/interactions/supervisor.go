@@ -0,0 +1,145 @@
+package interactions
+
+import (
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/outbox"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// How many crashes within the window it takes before the operator is told about a watch
+	crashLimit  = 3
+	crashWindow = 10 * time.Minute
+	// How long a crashed watch waits before restarting, doubling with each crash in the window
+	restartDelay    = time.Second
+	maxRestartDelay = 5 * time.Minute
+)
+
+// Owns every watch process, restarting any that panic from the watch's saved details
+type Supervisor struct {
+	session *discordgo.Session
+	o       *orchestrator.Orchestrator
+	outbox  *outbox.Outbox
+	crashes map[[3]string][]time.Time // map[{guildID, meetingID, channelID}]recent crashes, oldest first
+	running sync.WaitGroup            // Watch processes that haven't stopped for good
+	stopped chan struct{}             // Closed once no more watches may start or restart
+	mu      sync.Mutex
+}
+
+func NewSupervisor(
+	s *discordgo.Session,
+	o *orchestrator.Orchestrator,
+	out *outbox.Outbox,
+) *Supervisor {
+	return &Supervisor{
+		session: s,
+		o:       o,
+		outbox:  out,
+		crashes: make(map[[3]string][]time.Time),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Runs a watch process in its own goroutine until it stops on its own, restarting it each time it panics
+func (sv *Supervisor) start(watch *watchProcess, meetingTopic string) {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	select {
+	case <-sv.stopped:
+		return
+	default:
+	}
+
+	sv.running.Add(1)
+	go func() {
+		defer sv.running.Done()
+		for sv.run(watch, meetingTopic) {
+			select {
+			case <-time.After(sv.recordCrash(watch.guildID, watch.meetingID, watch.channelID)):
+			case <-sv.stopped:
+				return
+			}
+
+			// Pick up any changes made while the watch was down, unless it was canceled in the meantime
+			watchData, exists := sv.o.GetWatch(watch.guildID, watch.meetingID, watch.channelID)
+			if !exists {
+				return
+			}
+			watch = sv.newProcess(watchData)
+			watch.restarted = true
+			meetingTopic = watchData.MeetingTopic
+		}
+	}()
+}
+
+// Stops watches from starting or restarting, then waits for the running ones to finish, reporting whether they did
+// so in time
+func (sv *Supervisor) Stop(timeout time.Duration) bool {
+	sv.mu.Lock()
+	close(sv.stopped)
+	sv.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		sv.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Runs a watch process, reporting whether it panicked
+func (sv *Supervisor) run(watch *watchProcess, meetingTopic string) (crashed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf(
+				"watch on meeting ID %s in channel ID %s of %s crashed: %v\n%s",
+				watch.meetingID, watch.channelID, watch.guildID, r, debug.Stack(),
+			)
+			crashed = true
+		}
+	}()
+
+	watch.listen(meetingTopic)
+	return false
+}
+
+// Notes a crash and works out how long to wait before restarting the watch
+func (sv *Supervisor) recordCrash(guildID string, meetingID string, channelID string) time.Duration {
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+
+	now := time.Now()
+	key := [3]string{guildID, meetingID, channelID}
+	recent := []time.Time{}
+	for _, crash := range sv.crashes[key] {
+		if now.Sub(crash) < crashWindow {
+			recent = append(recent, crash)
+		}
+	}
+	recent = append(recent, now)
+	sv.crashes[key] = recent
+
+	if len(recent) == crashLimit {
+		sv.notifyOperator(guildID, meetingID, channelID, len(recent))
+	}
+	return min(restartDelay<<(len(recent)-1), maxRestartDelay)
+}
+
+func (sv *Supervisor) notifyOperator(guildID string, meetingID string, channelID string, crashes int) {
+	sv.o.Alerts.Raise(
+		"crash:"+guildID+":"+meetingID+":"+channelID, "Watch Keeps Crashing",
+		"The watch on meeting ID `%s` in channel ID `%s` of server ID `%s` crashed %d times in the last %s. "+
+			"It will keep restarting, but check the logs for stack traces.",
+		meetingID, channelID, guildID, crashes, crashWindow,
+	)
+}
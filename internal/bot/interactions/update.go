@@ -1,32 +1,53 @@
 package interactions
 
 import (
+	"errors"
 	"log"
 	"net/url"
+	"strings"
 
+	"github.com/angelajfisher/meeting-mate/internal/db"
 	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/types"
 	"github.com/bwmarrin/discordgo"
 )
 
-func HandleUpdate(s *discordgo.Session, i *discordgo.InteractionCreate, o orchestrator.Orchestrator, opts optionMap) {
+func HandleUpdate(s *discordgo.Session, i *discordgo.InteractionCreate, o *orchestrator.Orchestrator, opts optionMap) {
 	meetingID := opts[MEETING_OPT].StringValue()
-	log.Printf("%s: /update ID %s in %s", i.Member.User, meetingID, i.GuildID)
-
-	var invalidResponseMsg string
+	log.Printf("%s: /update ID %s in %s", invoker(i), meetingID, i.GuildID)
+	if !checkRoles(s, i, o, types.MODIFY_ACTION) {
+		return
+	}
 
 	// Verify that the requested meeting ID exists
-	if !o.IsOngoingWatch(i.GuildID, meetingID) {
-		invalidResponseMsg = "Nothing to update: meeting ID `" + meetingID + "` isn't being watched in this server."
+	channelID, invalidResponseMsg := watchedChannel(i, o, opts, meetingID)
+	watch, exists := o.GetWatch(i.GuildID, meetingID, channelID)
+	if invalidResponseMsg == "" && (!exists || !o.IsOngoingWatch(i.GuildID, meetingID, channelID)) {
+		invalidResponseMsg = "Nothing to update: meeting ID `" + meetingID + "` isn't being watched in " +
+			unwatchedIn(o, i.GuildID, meetingID, channelID) + "."
 	}
 
-	// Check for valid join link if provided
-	if v, ok := opts[LINK_OPT]; ok && invalidResponseMsg == "" {
+	// Check for valid join link if provided, unless it's being removed
+	if v, ok := opts[LINK_OPT]; ok && invalidResponseMsg == "" && !strings.EqualFold(v.StringValue(), NO_LINK) {
 		u, parseErr := url.Parse(v.StringValue())
 		if parseErr != nil || u.Scheme != "https" {
 			invalidResponseMsg = "Invalid join link provided. Please ensure your URL is correct and starts with \"https://\""
 		}
 	}
 
+	// Only the options given change; the rest stay as they are
+	newFlags := generateWatchFlags(opts, channelID, watch.Options)
+	changes := flagChanges(watch.Options, newFlags)
+	if invalidResponseMsg == "" && len(changes) == 0 {
+		invalidResponseMsg = "Nothing to update: the watch on meeting ID `" + meetingID + "` in <#" + channelID +
+			"> already has these options."
+	}
+
+	// The new options may need permissions the watch didn't before
+	if invalidResponseMsg == "" {
+		invalidResponseMsg = permissionProblem(s, i, watch.ChannelID, newFlags)
+	}
+
 	if invalidResponseMsg != "" {
 		err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 			Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -41,32 +62,66 @@ func HandleUpdate(s *discordgo.Session, i *discordgo.InteractionCreate, o orches
 		return
 	}
 
-	newFlags := generateWatchFlags(opts)
-	o.UpdateFlags(i.GuildID, meetingID, newFlags)
-
-	silent := "True"
-	if !newFlags.Silent {
-		silent = "False"
-	}
-	summaries := "True"
-	if !newFlags.Summaries {
-		summaries = "False"
+	if err := o.UpdateFlags(i.GuildID, meetingID, channelID, newFlags); err != nil {
+		failedResponseMsg := "Could not save the new options for meeting ID `" + meetingID + "`. Please try again later."
+		if errors.Is(err, db.ErrUnknownWatch) {
+			// Canceled since the checks above
+			failedResponseMsg = "Nothing to update: meeting ID `" + meetingID + "` is no longer being watched in <#" +
+				channelID + ">."
+		} else {
+			log.Printf("HandleUpdate: %s", err)
+		}
+		err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: failedResponseMsg,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+		if err != nil {
+			log.Printf("HandleUpdate-Failed: could not respond to interaction: %s", err)
+		}
+		return
 	}
 
 	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
-			Content: "Successfully updated! The watch on meeting ID `" + meetingID +
-				"` now has the following options selected:\n\n**Silent**: `" +
-				silent + "`\n**Join link**: " + func() string {
-				if newFlags.JoinLink == "" {
-					return "n/a"
-				}
-				return "`" + newFlags.JoinLink + "`"
-			}() + "\n**Summaries**: `" + summaries + "`\n**History level**: `" + newFlags.HistoryLevel + "`",
+			Content: "Successfully updated! The watch on meeting ID `" + meetingID + "` in <#" + channelID +
+				"> changed the following options:\n\n" + strings.Join(changes, "\n"),
 		},
 	})
 	if err != nil {
 		log.Printf("HandleUpdate-Success: could not respond to interaction: %s", err)
 	}
 }
+
+// Lists each option that differs between two sets of watch options, showing the old and new values
+func flagChanges(old types.FeatureFlags, updated types.FeatureFlags) []string {
+	changes := []string{}
+	change := func(name string, before string, after string) {
+		if before != after {
+			changes = append(changes, "**"+name+"**: "+before+" → "+after)
+		}
+	}
+	change("Silent", "`"+boolLabel(old.Silent)+"`", "`"+boolLabel(updated.Silent)+"`")
+	change("Join link", linkLabel(old.JoinLink), linkLabel(updated.JoinLink))
+	change("Summary level", "`"+old.SummaryLevel+"`", "`"+updated.SummaryLevel+"`")
+	change("History level", "`"+old.HistoryLevel+"`", "`"+updated.HistoryLevel+"`")
+	change("Timeline chart", "`"+boolLabel(old.TimelineChart)+"`", "`"+boolLabel(updated.TimelineChart)+"`")
+	return changes
+}
+
+func linkLabel(link string) string {
+	if link == "" {
+		return "n/a"
+	}
+	return "`" + link + "`"
+}
+
+func boolLabel(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
@@ -1,13 +1,17 @@
 package interactions
 
 import (
-	"fmt"
+	"bytes"
+	"errors"
 	"log"
 	"net/url"
 	"strings"
+	"sync"
 
+	"github.com/angelajfisher/meeting-mate/internal/chart"
 	"github.com/angelajfisher/meeting-mate/internal/db"
 	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/outbox"
 	"github.com/angelajfisher/meeting-mate/internal/types"
 	"github.com/bwmarrin/discordgo"
 )
@@ -18,13 +22,27 @@ type watchProcess struct {
 	flags             types.FeatureFlags     // The user-configurable options for the watch
 	channelID         string                 // The ID of the channel this watch is for
 	session           *discordgo.Session     // The active Discord session used for communication
+	outbox            *outbox.Outbox         // Queues changes to the status message so bursts collapse into one edit
 	meetingInProgress bool                   // Whether the meeting is currently ongoing
 	meetingMsgContent *discordgo.MessageSend // The data the message should contain
 	meetingStatusMsg  *discordgo.Message     // The message sent by the bot
-	o                 orchestrator.Orchestrator
+	resumed           bool                   // Whether the status message was picked up from an earlier run
+	restarted         bool                   // Whether this process took over from one that crashed
+	paused            bool                   // Whether posting stopped because the bot lost access to the channel
+	channelGone       bool                   // Whether the channel was deleted, which cancels the watch
+	archived          bool                   // Whether the watch's thread was archived, so it's reopened before posting
+	failure           error                  // The last edit that failed for good, dealt with on the next update
+	failureMu         sync.Mutex
+	o                 *orchestrator.Orchestrator
 }
 
-func HandleWatch(s *discordgo.Session, i *discordgo.InteractionCreate, o orchestrator.Orchestrator, opts optionMap) {
+func HandleWatch(
+	s *discordgo.Session,
+	i *discordgo.InteractionCreate,
+	o *orchestrator.Orchestrator,
+	sup *Supervisor,
+	opts optionMap,
+) {
 	var (
 		newMeetingID = opts[MEETING_OPT].StringValue()
 		err          error
@@ -35,12 +53,25 @@ func HandleWatch(s *discordgo.Session, i *discordgo.InteractionCreate, o orchest
 		}
 	)
 
-	log.Printf("%s: /watch ID %s in %s", i.Member.User, newMeetingID, i.GuildID)
+	log.Printf("%s: /watch ID %s in %s", invoker(i), newMeetingID, i.GuildID)
+	if !checkRoles(s, i, o, types.CREATE_ACTION) {
+		return
+	}
+
+	// Start from the server's defaults, posting to the channel asked for, else the server's default channel if it
+	// has one, else this one
+	settings := guildSettings(o, i.GuildID)
+	channelID := i.ChannelID
+	if v, ok := opts[CHANNEL_OPT]; ok {
+		channelID = v.ChannelValue(nil).ID
+	} else if settings.ChannelID != "" {
+		channelID = settings.ChannelID
+	}
 
-	// Check if the meeting ID is currently being watched
-	if o.IsOngoingWatch(i.GuildID, newMeetingID) {
-		responseMsg.msg = "Watch on meeting ID `" + newMeetingID +
-			"` is already ongoing. It will continue indefinitely unless `/cancel`ed." +
+	// Check if the meeting ID is currently being watched in that channel
+	if o.IsOngoingWatch(i.GuildID, newMeetingID, channelID) {
+		responseMsg.msg = "Watch on meeting ID `" + newMeetingID + "` in <#" + channelID +
+			"> is already ongoing. It will continue indefinitely unless `/cancel`ed." +
 			"\nIf you'd like to change the settings on this watch, try `/update `" + newMeetingID + "`!"
 		responseMsg.flags = discordgo.MessageFlagsEphemeral
 		responseMsg.terminate = true
@@ -60,8 +91,61 @@ func HandleWatch(s *discordgo.Session, i *discordgo.InteractionCreate, o orchest
 		}
 	}
 
+	// Initialize the new watch process
+	watch := watchProcess{
+		meetingID:         newMeetingID,
+		guildID:           i.GuildID,
+		flags:             generateWatchFlags(opts, channelID, settings.Defaults),
+		session:           s,
+		outbox:            sup.outbox,
+		channelID:         channelID,
+		meetingInProgress: false,
+		meetingMsgContent: &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{{Type: discordgo.EmbedTypeRich,
+			Description: "Loading..."}}},
+		meetingStatusMsg: nil,
+		o:                o,
+	}
+	if watch.flags.Silent {
+		watch.meetingMsgContent.Flags = discordgo.MessageFlagsSuppressNotifications
+	}
+
+	// A channel asked for must be one the invoker could post in themselves
+	if _, ok := opts[CHANNEL_OPT]; ok && !responseMsg.terminate {
+		if problem := invokerProblem(s, i, channelID); problem != "" {
+			responseMsg.msg = problem
+			responseMsg.flags = discordgo.MessageFlagsEphemeral
+			responseMsg.terminate = true
+		}
+	}
+
+	// Make sure the bot can actually post updates before taking on the watch
 	if !responseMsg.terminate {
-		responseMsg.msg = "Initiating watch on meeting ID `" + newMeetingID + "`!\nStop at any time with `/cancel`"
+		if problem := permissionProblem(s, i, watch.channelID, watch.flags); problem != "" {
+			responseMsg.msg = problem
+			responseMsg.flags = discordgo.MessageFlagsEphemeral
+			responseMsg.terminate = true
+		}
+	}
+
+	// Store watch details in order to restore the state upon a restart or failover
+	if !responseMsg.terminate {
+		if err = o.SaveWatch(db.WatchData{
+			MeetingID: watch.meetingID,
+			GuildID:   watch.guildID,
+			ChannelID: watch.channelID,
+			Options:   watch.flags,
+		}); err != nil {
+			log.Printf("HandleWatch: %s", err)
+			responseMsg.msg = "Could not save the watch on meeting ID `" + newMeetingID + "`. Please try again later."
+			responseMsg.flags = discordgo.MessageFlagsEphemeral
+			responseMsg.terminate = true
+		} else {
+			responseMsg.msg = "Initiating watch on meeting ID `" + newMeetingID + "`!\nStop at any time with `/cancel`"
+			if watch.channelID != i.ChannelID {
+				responseMsg.msg = "Initiating watch on meeting ID `" + newMeetingID + "` in <#" + watch.channelID +
+					">!\nStop at any time with `/cancel`"
+			}
+		}
 	}
 
 	// Send the interaction response message to the user
@@ -78,45 +162,22 @@ func HandleWatch(s *discordgo.Session, i *discordgo.InteractionCreate, o orchest
 		return
 	}
 
-	// Initialize the new watch process
-	watch := watchProcess{
-		meetingID:         newMeetingID,
-		guildID:           i.GuildID,
-		flags:             generateWatchFlags(opts),
-		session:           s,
-		channelID:         i.ChannelID,
-		meetingInProgress: false,
-		meetingMsgContent: &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{{Type: discordgo.EmbedTypeRich,
-			Description: "Loading..."}}},
-		meetingStatusMsg: nil,
-		o:                o,
-	}
-	if watch.flags.Silent {
-		watch.meetingMsgContent.Flags = discordgo.MessageFlagsSuppressNotifications
-	}
+	sup.start(&watch, "")
+}
 
-	// Store watch details in the database in order to restore the state upon a restart
-	o.Database.SaveWatch(db.WatchData{
-		MeetingID: watch.meetingID,
-		GuildID:   watch.guildID,
-		ChannelID: watch.channelID,
-		Options:   watch.flags,
-	})
-	watch.listen("")
+// Restores an ongoing watch by initializing a watch process with data saved before a restart or sent by a peer
+func (sv *Supervisor) LoadSavedWatch(watchData db.WatchData) {
+	sv.start(sv.newProcess(watchData), watchData.MeetingTopic)
 }
 
-// Restores an ongoing watch by initializing a watch process with saved data
-func LoadSavedWatch(
-	s *discordgo.Session,
-	o orchestrator.Orchestrator,
-	watchData db.WatchData,
-) {
-	// Initialize the watch process
-	watch := watchProcess{
+// Initializes a watch process from a watch's saved details
+func (sv *Supervisor) newProcess(watchData db.WatchData) *watchProcess {
+	watch := &watchProcess{
 		meetingID:         watchData.MeetingID,
 		guildID:           watchData.GuildID,
 		flags:             watchData.Options,
-		session:           s,
+		session:           sv.session,
+		outbox:            sv.outbox,
 		channelID:         watchData.ChannelID,
 		meetingInProgress: false,
 		meetingMsgContent: &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{{
@@ -124,28 +185,46 @@ func LoadSavedWatch(
 			Description: "Loading...",
 		}}},
 		meetingStatusMsg: nil,
-		o:                o,
+		o:                sv.o,
 	}
 	if watch.flags.Silent {
 		watch.meetingMsgContent.Flags = discordgo.MessageFlagsSuppressNotifications
 	}
-
-	watch.listen(watchData.MeetingTopic)
+	if watchData.StatusMessageID != "" {
+		watch.attachStatusMsg(watchData.StatusMessageID)
+	}
+	return watch
 }
 
-// Starts a goroutine to listen to Zoom meeting changes and update the meeting message accordingly
+// Listens to Zoom meeting changes and updates the meeting message accordingly. Meant to be run by the Supervisor.
 //
 //nolint:gocognit
 func (w *watchProcess) listen(meetingTopic string) {
 	var (
-		err      error
+		updates  <-chan types.UpdateData
+		started  bool
 		shutdown = false
 	)
-	for updateData := range w.o.StartWatch(w.guildID, w.meetingID, meetingTopic) {
+	if w.restarted {
+		updates, started = w.o.ResumeWatch(w.guildID, w.meetingID, w.channelID)
+	} else {
+		updates, started = w.o.StartWatch(w.guildID, w.meetingID, w.channelID, meetingTopic)
+	}
+	if !started {
+		log.Printf(
+			"watch on meeting ID %s in %s/%s is already running or has stopped", w.meetingID, w.guildID, w.channelID,
+		)
+		return
+	}
+	// A crashed process's message only missed an update or two, so it's left as is until the next one
+	if w.resumed && !w.restarted && w.o.IsLeader() {
+		w.showResumed(meetingTopic)
+	}
+	for updateData := range updates {
 		if updateData.EventType == types.SYSTEM_SHUTDOWN {
 			messageBuilder := new(strings.Builder)
 			messageBuilder.WriteString("**Status Unknown**\nThe watch stopped due to bot shutdown.")
-			if w.o.Database.Enabled {
+			if w.o.Database.Persistent() {
 				messageBuilder.WriteString(
 					" No action is needed on your part — the watch should automatically resume when Meeting Mate returns.",
 				)
@@ -169,6 +248,10 @@ func (w *watchProcess) listen(meetingTopic string) {
 			w.meetingMsgContent.Components = []discordgo.MessageComponent{}
 			break
 		}
+		if updateData.EventType == types.STATUS_MESSAGE {
+			w.attachStatusMsg(updateData.StatusMessageID)
+			continue
+		}
 		if updateData.EventType == types.UPDATE_FLAGS {
 			w.flags = updateData.Flags
 			if w.flags.Silent {
@@ -179,14 +262,39 @@ func (w *watchProcess) listen(meetingTopic string) {
 			continue
 		}
 
-		// Remove old meeting message if needed (full history messages will be nil if not in progress)
-		if !w.meetingInProgress && w.meetingStatusMsg != nil {
+		// Deal with any edit that failed since the last update before touching the message again
+		if failure := w.takeFailure(); failure != nil {
+			w.handleFailure(failure)
+		}
+		if w.channelGone {
+			continue // The cancellation is already on its way
+		}
+		if w.paused {
+			// Sit out the rest of the meeting, then try again once the next one starts
+			if w.meetingInProgress {
+				if updateData.EventType == types.ZOOM_MEETING_END {
+					w.meetingInProgress = false
+				}
+				continue
+			}
+			w.paused = false
+		}
+
+		// Threads archive themselves after a while without activity, and nothing can be posted in them until reopened
+		if w.threadArchived() && !w.reopenThread() && w.stalled(updateData) {
+			continue
+		}
+
+		// Remove old meeting message if needed (full history messages will be nil if not in progress). A message
+		// picked up from an earlier run is reused instead, since whatever it showed is out of date.
+		if !w.meetingInProgress && w.meetingStatusMsg != nil && !w.resumed {
 			func() {
 				defer func() { w.meetingStatusMsg = nil }()
 				if w.flags.HistoryLevel == types.PARTIAL_HISTORY {
 					channel, chanErr := w.session.Channel(w.channelID)
 					if chanErr != nil {
 						log.Printf("could not get channel info: %s", chanErr)
+						w.handleFailure(chanErr)
 						return
 					}
 					// Keep meeting history if it's been buried by conversation
@@ -194,17 +302,32 @@ func (w *watchProcess) listen(meetingTopic string) {
 						return
 					}
 				}
-				delErr := w.session.ChannelMessageDelete(w.channelID, w.meetingStatusMsg.ID)
-				if delErr != nil {
+				delErr := w.outbox.Delete(w.channelID, w.meetingStatusMsg.ID)
+				if delErr != nil && outbox.ErrorCode(delErr) != discordgo.ErrCodeUnknownMessage {
 					log.Printf("could not delete previous meeting message: %s", delErr)
+					w.handleFailure(delErr)
 				}
 			}()
+			if w.stalled(updateData) {
+				continue
+			}
 		}
 
+		w.resumed = false
+
 		if w.meetingStatusMsg == nil {
-			w.meetingStatusMsg, err = w.session.ChannelMessageSendComplex(w.channelID, w.meetingMsgContent)
-			if err != nil {
-				log.Printf("WatchListener-Update: could not respond to interaction: %s", err)
+			message := w.withMentions(w.meetingMsgContent, updateData)
+			sent, sendErr := w.outbox.Send(w.channelID, message)
+			if outbox.ErrorCode(sendErr) == discordgo.ErrCodePerformedOperationOnArchivedThread && w.reopenThread() {
+				sent, sendErr = w.outbox.Send(w.channelID, message)
+			}
+			if sendErr != nil {
+				log.Printf("WatchListener-Update: could not respond to interaction: %s", sendErr)
+				w.handleFailure(sendErr)
+			}
+			w.setStatusMsg(sent)
+			if w.stalled(updateData) {
+				continue
 			}
 		}
 
@@ -214,6 +337,7 @@ func (w *watchProcess) listen(meetingTopic string) {
 			w.meetingMsgContent.Embeds[0].Title = updateData.MeetingName
 			w.meetingMsgContent.Embeds[0].Description = "This meeting is in progress."
 			w.meetingMsgContent.Embeds[0].Fields = []*discordgo.MessageEmbedField{{Name: "Current Participants"}}
+			w.meetingMsgContent.Embeds[0].Image = nil
 			if w.flags.JoinLink != "" {
 				w.meetingMsgContent.Components = []discordgo.MessageComponent{
 					discordgo.ActionsRow{Components: []discordgo.MessageComponent{
@@ -230,6 +354,12 @@ func (w *watchProcess) listen(meetingTopic string) {
 		w.updateMeetingMsg(updateData)
 	}
 
+	// Followers never post, so they leave the leader's message alone. Neither can a watch that lost its channel.
+	// The shutdown notice is only sent by a leader with no one to take over, which has stepped down by now.
+	if !(shutdown || w.o.IsLeader()) || w.channelGone || w.paused {
+		return
+	}
+
 	// Update any existing status messages w/ notice that the watch stopped
 	if w.meetingStatusMsg != nil {
 		w.meetingMsgContent.Embeds[0].Fields = nil
@@ -239,37 +369,197 @@ func (w *watchProcess) listen(meetingTopic string) {
 			Channel:    w.meetingStatusMsg.ChannelID,
 			Components: &[]discordgo.MessageComponent{},
 		}
-		if _, err = w.session.ChannelMessageEditComplex(&updatedContent); err != nil {
-			log.Printf("WatchListener-Stop: could not respond to interaction: %s", err)
-		}
+		w.outbox.Edit(&updatedContent, nil)
 	} else if shutdown {
-		_, err = w.session.ChannelMessageSendComplex(w.channelID, w.meetingMsgContent)
+		sent, err := w.outbox.Send(w.channelID, w.meetingMsgContent)
 		if err != nil {
 			log.Printf("WatchListener-Shutdown: could not respond to interaction: %s", err)
 		}
+		// Saved so the notice is edited in place once the watch resumes
+		w.setStatusMsg(sent)
+	}
+}
+
+// Points the watch at a status message sent by an earlier run or by another node, which is reused for the next
+// update rather than replaced
+func (w *watchProcess) attachStatusMsg(messageID string) {
+	if messageID == "" {
+		w.meetingStatusMsg = nil
+		w.resumed = false
+		return
+	}
+	w.meetingStatusMsg = &discordgo.Message{ID: messageID, ChannelID: w.channelID}
+	w.resumed = true
+}
+
+// Replaces the status message left from before a restart with a notice that the watch is running again
+func (w *watchProcess) showResumed(meetingTopic string) {
+	title := meetingTopic
+	if title == "" {
+		title = "Meeting ID: " + w.meetingID
+	}
+	w.meetingMsgContent.Embeds[0].Title = title
+	w.meetingMsgContent.Embeds[0].Description = "**Status Unknown**\nMeeting Mate restarted and resumed this watch. " +
+		"Data for in-progress meetings has been lost, but this message will update as usual once there's activity."
+	w.meetingMsgContent.Components = []discordgo.MessageComponent{}
+	w.outbox.Edit(&discordgo.MessageEdit{
+		Embeds:     &w.meetingMsgContent.Embeds,
+		ID:         w.meetingStatusMsg.ID,
+		Channel:    w.meetingStatusMsg.ChannelID,
+		Components: &w.meetingMsgContent.Components,
+	}, w.recordFailure)
+}
+
+// Called by the outbox when an edit to the status message fails for good
+func (w *watchProcess) recordFailure(err error) {
+	w.failureMu.Lock()
+	defer w.failureMu.Unlock()
+
+	w.failure = err
+}
+
+func (w *watchProcess) takeFailure() error {
+	w.failureMu.Lock()
+	defer w.failureMu.Unlock()
+
+	err := w.failure
+	w.failure = nil
+	return err
+}
+
+// Adjusts the watch to a request Discord refused: a deleted status message is sent again with the next update,
+// a deleted channel cancels the watch, and lost access pauses it until the next meeting starts
+func (w *watchProcess) handleFailure(err error) {
+	switch outbox.ErrorCode(err) {
+	case discordgo.ErrCodeUnknownMessage:
+		log.Printf("status message for meeting ID %s in %s was deleted; sending a new one", w.meetingID, w.guildID)
+		w.setStatusMsg(nil)
+	case discordgo.ErrCodeUnknownChannel, discordgo.ErrCodeUnknownGuild:
+		log.Printf("channel ID %s no longer exists; canceling watch on meeting ID %s", w.channelID, w.meetingID)
+		w.channelGone = true
+		if cancelErr := w.o.CancelWatch(w.guildID, w.meetingID, w.channelID); cancelErr != nil {
+			log.Printf("could not cancel watch on meeting ID %s: %s", w.meetingID, cancelErr)
+		}
+	case discordgo.ErrCodePerformedOperationOnArchivedThread:
+		w.archived = true
+	case discordgo.ErrCodeMissingAccess, discordgo.ErrCodeMissingPermissions:
+		w.o.Alerts.Raise(
+			"access:"+w.channelID, "Lost Access to a Channel",
+			"Lost access to channel ID `%s` in server ID `%s`, so the watch on meeting ID `%s` is paused until "+
+				"the next meeting: %s",
+			w.channelID, w.guildID, w.meetingID, err,
+		)
+		w.paused = true
+	}
+}
+
+// Whether the watch's channel is a thread that has been archived, going by what the gateway last reported
+func (w *watchProcess) threadArchived() bool {
+	if w.archived {
+		return true
+	}
+	channel, err := w.session.State.Channel(w.channelID)
+	return err == nil && channel.IsThread() && channel.ThreadMetadata != nil && channel.ThreadMetadata.Archived
+}
+
+// Unarchives the watch's thread. Reports whether it worked.
+func (w *watchProcess) reopenThread() bool {
+	archived := false
+	if _, err := w.session.ChannelEdit(w.channelID, &discordgo.ChannelEdit{Archived: &archived}); err != nil {
+		log.Printf("could not unarchive thread ID %s for meeting ID %s: %s", w.channelID, w.meetingID, err)
+		w.handleFailure(err)
+		return false
+	}
+	w.archived = false
+	return true
+}
+
+// Whether the watch stopped posting while handling an update. A paused watch counts the meeting as underway so it
+// doesn't try again until the next one starts.
+func (w *watchProcess) stalled(updateData types.UpdateData) bool {
+	if w.paused {
+		w.meetingInProgress = updateData.EventType != types.ZOOM_MEETING_END
+	}
+	return w.channelGone || w.paused
+}
+
+// Adds a ping for the server's mention roles to a status message sent as a meeting starts
+func (w *watchProcess) withMentions(
+	message *discordgo.MessageSend,
+	updateData types.UpdateData,
+) *discordgo.MessageSend {
+	if w.meetingInProgress || updateData.EventType == types.ZOOM_MEETING_END {
+		return message
+	}
+	roles := guildSettings(w.o, w.guildID).MentionRoles
+	if len(roles) == 0 {
+		return message
+	}
+	mentioned := *message
+	mentioned.Content = mentionRoles(roles)
+	mentioned.AllowedMentions = &discordgo.MessageAllowedMentions{Roles: roles}
+	return &mentioned
+}
+
+// Keeps track of the current status message and saves it so the watch can pick it up again after a restart
+func (w *watchProcess) setStatusMsg(msg *discordgo.Message) {
+	w.meetingStatusMsg = msg
+	messageID := ""
+	if msg != nil {
+		messageID = msg.ID
+	}
+	if err := w.o.SetStatusMessage(w.guildID, w.meetingID, w.channelID, messageID); err != nil {
+		log.Printf("could not save status message for meeting ID %s: %s", w.meetingID, err)
 	}
 }
 
 func (w *watchProcess) updateMeetingMsg(updateData types.UpdateData) {
-	var err error
+	var files []*discordgo.File
 
 	if updateData.EventType == types.ZOOM_MEETING_END {
 		w.meetingMsgContent.Embeds[0].Description = "This meeting ended."
 		w.meetingInProgress = false
 		w.meetingMsgContent.Components = []discordgo.MessageComponent{}
-		if w.flags.Summaries {
-			w.meetingMsgContent.Embeds[0].Fields = []*discordgo.MessageEmbedField{
-				{Name: "Summary", Value: fmt.Sprintf(
-					"Total Participants: %v\nDuration: %s",
-					updateData.TotalParticipants,
-					updateData.MeetingDuration,
-				)},
+		w.meetingMsgContent.Embeds[0].Fields = summaryFields(
+			w.flags.SummaryLevel,
+			updateData.Summary,
+			updateData.MeetingDuration,
+		)
+		if w.flags.SummaryLevel != types.NO_SUMMARY {
+			roster := w.o.GetRoster(w.guildID, w.meetingID, w.channelID)
+			if field := rosterSummaryField(roster, updateData.Summary); field != nil {
+				w.meetingMsgContent.Embeds[0].Fields = append(w.meetingMsgContent.Embeds[0].Fields, field)
+			}
+		}
+		if w.flags.TimelineChart {
+			settings := guildSettings(w.o, w.guildID)
+			timeline, chartErr := chart.Timeline(
+				updateData.Summary, updateData.Summary.Start, updateData.Summary.End,
+				settings.Location(), settings.ClockFormat(),
+			)
+			if chartErr == nil {
+				files = []*discordgo.File{{
+					Name:        chart.TIMELINE_FILENAME,
+					ContentType: "image/png",
+					Reader:      bytes.NewReader(timeline),
+				}}
+				w.meetingMsgContent.Embeds[0].Image = &discordgo.MessageEmbedImage{
+					URL: "attachment://" + chart.TIMELINE_FILENAME,
+				}
+			} else if !errors.Is(chartErr, chart.ErrNoAttendance) {
+				log.Printf("could not render timeline chart: %s", chartErr)
 			}
-		} else {
-			w.meetingMsgContent.Embeds[0].Fields = nil
 		}
 	} else {
+		if len(w.meetingMsgContent.Embeds[0].Fields) == 0 {
+			w.meetingMsgContent.Embeds[0].Fields = []*discordgo.MessageEmbedField{{Name: "Current Participants"}}
+		}
 		w.meetingMsgContent.Embeds[0].Fields[0].Value = updateData.Participants
+		w.meetingMsgContent.Embeds[0].Fields = w.meetingMsgContent.Embeds[0].Fields[:1]
+		roster := w.o.GetRoster(w.guildID, w.meetingID, w.channelID)
+		if field := rosterStatusField(roster, updateData.Present); field != nil {
+			w.meetingMsgContent.Embeds[0].Fields = append(w.meetingMsgContent.Embeds[0].Fields, field)
+		}
 	}
 
 	if w.meetingStatusMsg != nil {
@@ -278,14 +568,12 @@ func (w *watchProcess) updateMeetingMsg(updateData types.UpdateData) {
 			ID:         w.meetingStatusMsg.ID,
 			Channel:    w.meetingStatusMsg.ChannelID,
 			Components: &w.meetingMsgContent.Components,
+			Files:      files,
 		}
-		w.meetingStatusMsg, err = w.session.ChannelMessageEditComplex(&updatedContent)
-		if err != nil {
-			log.Printf("UpdateMeetingMsg: could not respond to interaction: %s\n", err)
-		}
+		w.outbox.Edit(&updatedContent, w.recordFailure)
 		// Since all messages are kept with full history, remove reference to old message so it isn't removed
 		if !w.meetingInProgress && w.flags.HistoryLevel == types.FULL_HISTORY {
-			w.meetingStatusMsg = nil
+			w.setStatusMsg(nil)
 		}
 	} else {
 		log.Println("could not update meeting status: meeting message is nil")
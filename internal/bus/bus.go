@@ -0,0 +1,175 @@
+// Fans meeting updates out to every watch without letting a slow one hold up the rest
+package bus
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+// Delivery counts across every subscriber
+type Stats struct {
+	Subscribers int
+	Published   uint64        // Updates addressed to a subscriber, counted once per subscriber
+	Delivered   uint64        // Updates a subscriber has received
+	Dropped     uint64        // Updates replaced by a newer one before their subscriber got to them
+	Pending     int           // Updates waiting in a mailbox
+	MaxLag      time.Duration // Longest any subscriber has taken to receive an update
+	CurrentLag  time.Duration // Age of the oldest update still waiting to be received
+}
+
+// How long a closed mailbox waits for its subscriber to receive the final update before giving up on it
+var closeGrace = time.Minute
+
+type Bus struct {
+	mailboxes map[string]map[[2]string]*mailbox // map[meetingID]map[{guildID, channelID}]
+	mu        sync.RWMutex
+
+	published atomic.Uint64
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+	maxLag    atomic.Int64
+}
+
+func New() *Bus {
+	return &Bus{
+		mailboxes: make(map[string]map[[2]string]*mailbox),
+	}
+}
+
+// Subscribes a guild's channel to updates about a meeting. Subscribing again before the mailbox is closed returns
+// the same channel. The channel is closed right after the update passed to Close or CloseAll is received.
+func (b *Bus) Subscribe(guildID string, meetingID string, channelID string) <-chan types.UpdateData {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := [2]string{guildID, channelID}
+	if m, exists := b.mailboxes[meetingID][key]; exists {
+		return m.out
+	}
+
+	if _, exists := b.mailboxes[meetingID]; !exists {
+		b.mailboxes[meetingID] = make(map[[2]string]*mailbox)
+	}
+	m := newMailbox(b)
+	b.mailboxes[meetingID][key] = m
+	go m.run()
+
+	return m.out
+}
+
+// Returns the channel of an existing subscription, if the guild's channel is still subscribed to the meeting
+func (b *Bus) Resubscribe(guildID string, meetingID string, channelID string) (<-chan types.UpdateData, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	m, exists := b.mailboxes[meetingID][[2]string{guildID, channelID}]
+	if !exists {
+		return nil, false
+	}
+	return m.out, true
+}
+
+// Hands an update to every channel subscribed to the meeting without waiting for any of them
+func (b *Bus) Publish(meetingID string, update types.UpdateData) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, m := range b.mailboxes[meetingID] {
+		m.push(update)
+	}
+}
+
+// Hands an update to a single subscriber. Reports whether the guild's channel is subscribed to the meeting.
+func (b *Bus) Send(guildID string, meetingID string, channelID string, update types.UpdateData) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	m, exists := b.mailboxes[meetingID][[2]string{guildID, channelID}]
+	if exists {
+		m.push(update)
+	}
+	return exists
+}
+
+// Unsubscribes a guild's channel from a meeting. The reason is delivered ahead of anything still waiting, which is
+// dropped. A subscriber that doesn't receive the reason within a minute is given up on.
+func (b *Bus) Close(guildID string, meetingID string, channelID string, reason types.UpdateData) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := [2]string{guildID, channelID}
+	m, exists := b.mailboxes[meetingID][key]
+	if !exists {
+		return
+	}
+	delete(b.mailboxes[meetingID], key)
+	if len(b.mailboxes[meetingID]) == 0 {
+		delete(b.mailboxes, meetingID)
+	}
+	m.close(reason)
+	time.AfterFunc(closeGrace, m.abandon)
+}
+
+// Closes every mailbox with the same reason, then waits until each subscriber has received it or the timeout passes.
+// Reports whether every subscriber received it in time; those that didn't are given up on.
+func (b *Bus) CloseAll(reason types.UpdateData, timeout time.Duration) bool {
+	b.mu.Lock()
+	closed := []*mailbox{}
+	for _, meetingMailboxes := range b.mailboxes {
+		for _, m := range meetingMailboxes {
+			m.close(reason)
+			closed = append(closed, m)
+		}
+	}
+	b.mailboxes = make(map[string]map[[2]string]*mailbox)
+	b.mu.Unlock()
+
+	deadline := time.After(timeout)
+	for _, m := range closed {
+		select {
+		case <-m.done:
+		case <-deadline:
+			for _, m := range closed {
+				m.abandon()
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bus) Stats() Stats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stats := Stats{
+		Published: b.published.Load(),
+		Delivered: b.delivered.Load(),
+		Dropped:   b.dropped.Load(),
+		MaxLag:    time.Duration(b.maxLag.Load()),
+	}
+	now := time.Now()
+	for _, meetingMailboxes := range b.mailboxes {
+		for _, m := range meetingMailboxes {
+			stats.Subscribers++
+			pending, oldest := m.backlog()
+			stats.Pending += pending
+			if pending > 0 {
+				stats.CurrentLag = max(stats.CurrentLag, now.Sub(oldest))
+			}
+		}
+	}
+	return stats
+}
+
+func (b *Bus) recordLag(lag time.Duration) {
+	for {
+		current := b.maxLag.Load()
+		if int64(lag) <= current || b.maxLag.CompareAndSwap(current, int64(lag)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,364 @@
+package bus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+const receiveTimeout = 10 * time.Second
+
+// Reads a subscription until it's closed, failing the test if that takes too long
+func receiveAll(t *testing.T, updates <-chan types.UpdateData) []types.UpdateData {
+	t.Helper()
+
+	var received []types.UpdateData
+	timeout := time.After(receiveTimeout)
+	for {
+		select {
+		case update, open := <-updates:
+			if !open {
+				return received
+			}
+			received = append(received, update)
+		case <-timeout:
+			t.Errorf("subscription still open after %s", receiveTimeout)
+			return received
+		}
+	}
+}
+
+// Waits until every subscriber has received everything sent to it so far
+func waitForDelivery(t *testing.T, b *Bus) {
+	t.Helper()
+
+	deadline := time.Now().Add(receiveTimeout)
+	for b.Stats().Pending > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("updates still pending after %s", receiveTimeout)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Updates that can't be coalesced, tagged with who sent them and in which order
+func numbered(sender int, seq int) types.UpdateData {
+	return types.UpdateData{EventType: types.STATUS_MESSAGE, StatusMessageID: fmt.Sprintf("%d/%d", sender, seq)}
+}
+
+// Splits the updates from numbered back out by sender, checking none arrived out of order
+func bySender(t *testing.T, received []types.UpdateData) map[int][]int {
+	t.Helper()
+
+	seqs := make(map[int][]int)
+	for _, update := range received {
+		sender, seq, _ := strings.Cut(update.StatusMessageID, "/")
+		s, _ := strconv.Atoi(sender)
+		n, _ := strconv.Atoi(seq)
+		if prev := seqs[s]; len(prev) > 0 && prev[len(prev)-1] >= n {
+			t.Errorf("update %d/%d received after %d/%d", s, n, s, prev[len(prev)-1])
+		}
+		seqs[s] = append(seqs[s], n)
+	}
+	return seqs
+}
+
+// Every subscriber must receive every update from every publisher, each publisher's in order, then the close
+func TestConcurrentPublish(t *testing.T) {
+	const (
+		subscribers = 20
+		publishers  = 10
+		updates     = 500
+	)
+	b := New()
+	reason := types.UpdateData{EventType: types.WATCH_CANCELED}
+
+	results := make([][]types.UpdateData, subscribers)
+	var readers sync.WaitGroup
+	for s := range subscribers {
+		channelID := fmt.Sprintf("c%d", s)
+		sub := b.Subscribe("g1", "m1", channelID)
+		if again := b.Subscribe("g1", "m1", channelID); again != sub {
+			t.Fatal("subscribing twice returned a different channel")
+		}
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			results[s] = receiveAll(t, sub)
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for p := range publishers {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for n := range updates {
+				b.Publish("m1", numbered(p, n))
+				// A meeting nobody watches goes nowhere
+				b.Publish("m2", numbered(p, n))
+			}
+		}()
+	}
+	// Direct sends interleave with the published updates
+	for s := range subscribers {
+		writers.Add(1)
+		go func() {
+			defer writers.Done()
+			for n := range updates {
+				if !b.Send("g1", "m1", fmt.Sprintf("c%d", s), numbered(publishers+s, n)) {
+					t.Errorf("Send to subscribed c%d failed", s)
+					return
+				}
+			}
+		}()
+	}
+	writers.Wait()
+	// Closing drops anything still waiting, so let every subscriber catch up first
+	waitForDelivery(t, b)
+
+	var closers sync.WaitGroup
+	for s := range subscribers {
+		closers.Add(1)
+		go func() {
+			defer closers.Done()
+			channelID := fmt.Sprintf("c%d", s)
+			b.Close("g1", "m1", channelID, reason)
+			// Closing twice is harmless
+			b.Close("g1", "m1", channelID, reason)
+		}()
+	}
+	closers.Wait()
+	readers.Wait()
+
+	for s, received := range results {
+		want := (publishers+1)*updates + 1
+		if len(received) != want {
+			t.Errorf("c%d received %d updates, want %d", s, len(received), want)
+			continue
+		}
+		if last := received[len(received)-1]; last.EventType != types.WATCH_CANCELED {
+			t.Errorf("c%d last received %q, want the close reason", s, last.EventType)
+		}
+		seqs := bySender(t, received[:len(received)-1])
+		for sender, got := range seqs {
+			if len(got) != updates {
+				t.Errorf("c%d received %d updates from sender %d, want %d", s, len(got), sender, updates)
+			}
+			if sender >= publishers && sender != publishers+s {
+				t.Errorf("c%d received updates sent to c%d", s, sender-publishers)
+			}
+		}
+	}
+
+	stats := b.Stats()
+	wantPublished := uint64(subscribers * ((publishers+1)*updates + 1))
+	if stats.Published != wantPublished || stats.Delivered != wantPublished || stats.Dropped != 0 {
+		t.Errorf("stats = %+v, want %d published and delivered with none dropped", stats, wantPublished)
+	}
+	if stats.Subscribers != 0 || stats.Pending != 0 {
+		t.Errorf("stats = %+v, want no subscribers or pending updates left", stats)
+	}
+}
+
+// Participant updates carry the whole meeting, so a slow subscriber may skip some but must end on the latest
+func TestConcurrentCoalescing(t *testing.T) {
+	const (
+		subscribers = 20
+		updates     = 2000
+	)
+	b := New()
+
+	results := make([][]types.UpdateData, subscribers)
+	var readers sync.WaitGroup
+	for s := range subscribers {
+		sub := b.Subscribe("g1", "m1", fmt.Sprintf("c%d", s))
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			results[s] = receiveAll(t, sub)
+		}()
+	}
+
+	for n := range updates {
+		b.Publish("m1", types.UpdateData{EventType: types.ZOOM_PARTICIPANT_JOIN, Participants: strconv.Itoa(n)})
+	}
+	b.Publish("m1", types.UpdateData{EventType: types.ZOOM_MEETING_END})
+	waitForDelivery(t, b)
+	if !b.CloseAll(types.UpdateData{EventType: types.SYSTEM_SHUTDOWN}, receiveTimeout) {
+		t.Fatal("CloseAll timed out with every subscriber reading")
+	}
+	readers.Wait()
+
+	for s, received := range results {
+		n := len(received)
+		if n < 3 {
+			t.Errorf("c%d received %d updates, want at least one participant update, the meeting end, and shutdown", s, n)
+			continue
+		}
+		if received[n-2].EventType != types.ZOOM_MEETING_END || received[n-1].EventType != types.SYSTEM_SHUTDOWN {
+			t.Errorf("c%d ended with %q and %q, want the meeting end and shutdown",
+				s, received[n-2].EventType, received[n-1].EventType)
+		}
+		last := -1
+		for _, update := range received[:n-2] {
+			seq, _ := strconv.Atoi(update.Participants)
+			if update.EventType != types.ZOOM_PARTICIPANT_JOIN || seq <= last {
+				t.Errorf("c%d received %q %d after participant update %d", s, update.EventType, seq, last)
+			}
+			last = seq
+		}
+		if last != updates-1 {
+			t.Errorf("c%d received the meeting end after participant update %d instead of the latest", s, last)
+		}
+	}
+
+	stats := b.Stats()
+	if stats.Published != stats.Delivered+stats.Dropped {
+		t.Errorf("stats = %+v, want every published update delivered or dropped", stats)
+	}
+	if stats.Subscribers != 0 || stats.Pending != 0 {
+		t.Errorf("stats = %+v, want no subscribers or pending updates left", stats)
+	}
+}
+
+// Closing while updates are still arriving must cut each subscriber off cleanly: whatever was received is an
+// in-order prefix of what was sent, followed by the close reason
+func TestConcurrentClose(t *testing.T) {
+	const (
+		subscribers = 50
+		publishers  = 8
+		updates     = 500
+	)
+	b := New()
+	reason := types.UpdateData{EventType: types.WATCH_CANCELED}
+
+	results := make([][]types.UpdateData, subscribers)
+	var readers sync.WaitGroup
+	for s := range subscribers {
+		sub := b.Subscribe("g1", "m1", fmt.Sprintf("c%d", s))
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			results[s] = receiveAll(t, sub)
+		}()
+	}
+
+	var wg sync.WaitGroup
+	for p := range publishers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range updates {
+				b.Publish("m1", numbered(p, n))
+			}
+		}()
+	}
+	for s := range subscribers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			channelID := fmt.Sprintf("c%d", s)
+			// Stagger the closes across the stream of updates
+			for range s * 10 {
+				if _, subscribed := b.Resubscribe("g1", "m1", channelID); !subscribed {
+					t.Errorf("c%d unsubscribed before being closed", s)
+					return
+				}
+			}
+			b.Close("g1", "m1", channelID, reason)
+			if _, subscribed := b.Resubscribe("g1", "m1", channelID); subscribed {
+				t.Errorf("c%d still subscribed after being closed", s)
+			}
+			if b.Send("g1", "m1", channelID, numbered(0, 0)) {
+				t.Errorf("Send to closed c%d succeeded", s)
+			}
+		}()
+	}
+	wg.Wait()
+	readers.Wait()
+
+	for s, received := range results {
+		if len(received) == 0 || received[len(received)-1].EventType != types.WATCH_CANCELED {
+			t.Errorf("c%d didn't end with the close reason", s)
+			continue
+		}
+		for sender, seqs := range bySender(t, received[:len(received)-1]) {
+			for i, seq := range seqs {
+				if seq != i {
+					t.Errorf("c%d is missing update %d/%d, which was sent before %d/%d", s, sender, i, sender, seq)
+					break
+				}
+			}
+		}
+	}
+
+	stats := b.Stats()
+	if stats.Published != stats.Delivered+stats.Dropped {
+		t.Errorf("stats = %+v, want every published update delivered or dropped", stats)
+	}
+	if stats.Subscribers != 0 || stats.Pending != 0 {
+		t.Errorf("stats = %+v, want no subscribers or pending updates left", stats)
+	}
+	// Publishing after everyone left reaches no one
+	b.Publish("m1", numbered(0, updates))
+	if after := b.Stats(); after.Published != stats.Published {
+		t.Errorf("publishing with no subscribers counted %d updates", after.Published-stats.Published)
+	}
+}
+
+// A subscriber that stopped reading must not keep its mailbox's goroutine around once the mailbox is closed
+func TestAbandonedSubscriber(t *testing.T) {
+	defer func(grace time.Duration) { closeGrace = grace }(closeGrace)
+	closeGrace = 10 * time.Millisecond
+
+	const updates = 100
+	tests := []struct {
+		name  string
+		close func(b *Bus)
+	}{
+		{"Close", func(b *Bus) {
+			b.Close("g1", "m1", "c1", types.UpdateData{EventType: types.WATCH_CANCELED})
+		}},
+		{"CloseAll", func(b *Bus) {
+			if b.CloseAll(types.UpdateData{EventType: types.SYSTEM_SHUTDOWN}, 10*time.Millisecond) {
+				t.Error("CloseAll reported success with nobody reading")
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := New()
+			sub := b.Subscribe("g1", "m1", "c1")
+			m := b.mailboxes["m1"][[2]string{"g1", "c1"}]
+
+			// Only the first update is taken; the goroutine is left waiting to hand over the second
+			for n := range updates {
+				b.Publish("m1", numbered(0, n))
+			}
+			if first := <-sub; first.StatusMessageID != "0/0" {
+				t.Fatalf("received %s first, want 0/0", first.StatusMessageID)
+			}
+			test.close(b)
+
+			select {
+			case <-m.done:
+			case <-time.After(receiveTimeout):
+				t.Fatal("mailbox still waiting on a subscriber that stopped reading")
+			}
+			if _, open := <-sub; open {
+				t.Error("subscription still open after being given up on")
+			}
+
+			stats := b.Stats()
+			if stats.Published != updates+1 || stats.Delivered != 1 || stats.Dropped != updates {
+				t.Errorf("stats = %+v, want %d published, 1 delivered, and the rest dropped", stats, updates+1)
+			}
+		})
+	}
+}
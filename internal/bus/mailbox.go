@@ -0,0 +1,172 @@
+package bus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+type envelope struct {
+	update types.UpdateData
+	sentAt time.Time // When the oldest update this one replaced was published
+}
+
+// Holds the updates a subscriber hasn't received yet. Consecutive participant updates each carry the whole state
+// of the meeting, so only the latest is kept; meeting ends and option changes are always delivered in order.
+type mailbox struct {
+	bus     *Bus
+	pending []envelope
+	final   *envelope // Delivered ahead of anything pending, after which the mailbox is finished
+	sending *envelope // Taken from the mailbox but not yet received
+	mu      sync.Mutex
+
+	wake      chan struct{} // Signals the delivery goroutine that something arrived
+	out       chan types.UpdateData
+	closed    chan struct{} // Closed along with the mailbox, so an update being sent gives way to the final one
+	abandoned chan struct{} // Closed once the bus stops waiting for the subscriber to receive the final update
+	abandon   func()
+	done      chan struct{} // Closed once the final update has been received or abandoned
+}
+
+func newMailbox(b *Bus) *mailbox {
+	m := &mailbox{
+		bus:       b,
+		wake:      make(chan struct{}, 1),
+		out:       make(chan types.UpdateData),
+		closed:    make(chan struct{}),
+		abandoned: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	m.abandon = sync.OnceFunc(func() { close(m.abandoned) })
+	return m
+}
+
+func (m *mailbox) push(update types.UpdateData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.final != nil {
+		return
+	}
+
+	m.bus.published.Add(1)
+	if last := len(m.pending) - 1; last >= 0 && coalesces(m.pending[last].update, update) {
+		m.pending[last].update = update
+		m.bus.dropped.Add(1)
+	} else {
+		m.pending = append(m.pending, envelope{update: update, sentAt: time.Now()})
+	}
+	m.signal()
+}
+
+func (m *mailbox) close(reason types.UpdateData) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.final != nil {
+		return
+	}
+
+	m.bus.published.Add(1)
+	m.bus.dropped.Add(uint64(len(m.pending)))
+	m.pending = nil
+	m.final = &envelope{update: reason, sentAt: time.Now()}
+	close(m.closed)
+	m.signal()
+}
+
+// Hands updates to the subscriber one at a time until the final one is received. A subscriber that stopped reading
+// can't hold the goroutine forever: once the mailbox is closed, the update it was waiting on is dropped with the
+// rest, and once the final update is abandoned, so is the subscriber.
+func (m *mailbox) run() {
+	defer close(m.out)
+	defer close(m.done)
+
+	for {
+		next, final := m.next()
+		giveUp := m.closed
+		if final {
+			giveUp = m.abandoned
+		}
+		select {
+		case m.out <- next.update:
+		case <-giveUp:
+			m.mu.Lock()
+			m.sending = nil
+			m.mu.Unlock()
+			m.bus.dropped.Add(1)
+			if final {
+				return
+			}
+			continue
+		}
+
+		m.mu.Lock()
+		m.sending = nil
+		m.mu.Unlock()
+		m.bus.delivered.Add(1)
+		m.bus.recordLag(time.Since(next.sentAt))
+		if final {
+			return
+		}
+	}
+}
+
+// Waits for the next update to deliver and reports whether it's the final one
+func (m *mailbox) next() (envelope, bool) {
+	for {
+		m.mu.Lock()
+		if m.final != nil {
+			final := *m.final
+			m.sending = &final
+			m.mu.Unlock()
+			return final, true
+		}
+		if len(m.pending) > 0 {
+			next := m.pending[0]
+			m.pending = m.pending[1:]
+			m.sending = &next
+			m.mu.Unlock()
+			return next, false
+		}
+		m.mu.Unlock()
+
+		<-m.wake
+	}
+}
+
+func (m *mailbox) backlog() (int, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	waiting := len(m.pending)
+	oldest := time.Time{}
+	if len(m.pending) > 0 {
+		oldest = m.pending[0].sentAt
+	}
+	if m.sending != nil {
+		waiting++
+		oldest = m.sending.sentAt
+	}
+	return waiting, oldest
+}
+
+func (m *mailbox) signal() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Whether a newer update makes an older, still undelivered one redundant
+func coalesces(older types.UpdateData, newer types.UpdateData) bool {
+	switch older.EventType {
+	case types.ZOOM_PARTICIPANT_JOIN, types.ZOOM_PARTICIPANT_LEAVE:
+		return newer.EventType == types.ZOOM_PARTICIPANT_JOIN || newer.EventType == types.ZOOM_PARTICIPANT_LEAVE
+	case types.UPDATE_FLAGS:
+		return newer.EventType == types.UPDATE_FLAGS
+	default:
+		return false
+	}
+}
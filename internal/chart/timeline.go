@@ -0,0 +1,210 @@
+// Renders meeting attendance as images for Discord embeds
+package chart
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"sort"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	TIMELINE_FILENAME = "timeline.png"
+
+	width        = 800
+	padding      = 12
+	labelWidth   = 150 // Space reserved on the left for participant names
+	curveHeight  = 110 // Height of the concurrency curve above the bars
+	rowHeight    = 18  // Height of each participant's row, including the gap between rows
+	barHeight    = 12
+	axisHeight   = 20 // Space reserved at the bottom for time labels
+	maxRows      = 40 // Participants beyond this are left out to keep the image readable
+	axisTicks    = 5
+	maxNameChars = labelWidth/7 - 1 // basicfont glyphs are 7px wide and cover ASCII only
+)
+
+var (
+	background = color.RGBA{0x31, 0x33, 0x38, 0xff} // Discord's dark theme embed background
+	gridColor  = color.RGBA{0x4e, 0x50, 0x58, 0xff}
+	textColor  = color.RGBA{0xdb, 0xde, 0xe1, 0xff}
+	barColor   = color.RGBA{0x58, 0x65, 0xf2, 0xff}
+	curveColor = color.RGBA{0x57, 0xf2, 0x87, 0xff}
+	curveFill  = color.NRGBA{0x57, 0xf2, 0x87, 0x40}
+)
+
+var ErrNoAttendance = errors.New("no attendance to chart")
+
+// Draws a Gantt-style PNG with one bar per participant session and a concurrency curve above it.
+// The time axis spans from start to end, widened as needed to fit every session, and is labeled in the given
+// timezone & clock layout.
+func Timeline(
+	summary types.MeetingSummary,
+	start time.Time,
+	end time.Time,
+	loc *time.Location,
+	clockFormat string,
+) ([]byte, error) {
+	records := make([]types.AttendanceRecord, 0, len(summary.Attendance))
+	for _, record := range summary.Attendance {
+		if len(record.Sessions) > 0 {
+			records = append(records, record)
+		}
+	}
+	if len(records) == 0 {
+		return nil, ErrNoAttendance
+	}
+
+	// List participants in the order they first arrived
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Sessions[0].Join.Before(records[j].Sessions[0].Join)
+	})
+
+	for _, record := range records {
+		for _, session := range record.Sessions {
+			if start.IsZero() || session.Join.Before(start) {
+				start = session.Join
+			}
+			if session.Leave.After(end) {
+				end = session.Leave
+			}
+		}
+	}
+	if !end.After(start) {
+		end = start.Add(time.Minute)
+	}
+
+	hidden := 0
+	if len(records) > maxRows {
+		hidden = len(records) - maxRows
+	}
+	rows := len(records) - hidden
+	if hidden > 0 {
+		rows++ // for the note about who was left out
+	}
+	height := padding*2 + curveHeight + padding + rows*rowHeight + axisHeight
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{background}, image.Point{}, draw.Src)
+
+	plot := plotArea{
+		left:  padding + labelWidth,
+		right: width - padding,
+		start: start,
+		end:   end,
+	}
+
+	// Vertical grid lines & time labels shared by the curve and the bars
+	axisY := height - padding - axisHeight
+	for tick := 0; tick <= axisTicks; tick++ {
+		x := plot.left + (plot.right-plot.left)*tick/axisTicks
+		fillRect(img, x, padding, x+1, axisY, gridColor)
+		at := start.Add(end.Sub(start) * time.Duration(tick) / axisTicks)
+		label := at.In(loc).Format(clockFormat)
+		// Center labels under their grid line, keeping the last one inside the image
+		labelX := min(x-len(label)*7/2, width-padding/2-len(label)*7)
+		drawText(img, labelX, axisY+15, label, textColor)
+	}
+	drawText(img, padding, axisY+15, start.In(loc).Format("MST"), textColor)
+
+	drawConcurrency(img, plot, records, padding, padding+curveHeight)
+
+	rowTop := padding*2 + curveHeight
+	for _, record := range records[:len(records)-hidden] {
+		drawText(img, padding, rowTop+barHeight, truncate(record.Name, maxNameChars), textColor)
+		for _, session := range record.Sessions {
+			x0, x1 := plot.x(session.Join), plot.x(session.Leave)
+			fillRect(img, x0, rowTop+1, max(x1, x0+2), rowTop+1+barHeight, barColor)
+		}
+		rowTop += rowHeight
+	}
+	if hidden > 0 {
+		drawText(img, padding, rowTop+barHeight, fmt.Sprintf("...and %d more", hidden), textColor)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		return nil, fmt.Errorf("could not encode timeline: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// The horizontal region of the image that maps onto the meeting's time span
+type plotArea struct {
+	left  int
+	right int
+	start time.Time
+	end   time.Time
+}
+
+func (p plotArea) x(t time.Time) int {
+	span := p.end.Sub(p.start)
+	return p.left + int(int64(p.right-p.left)*int64(t.Sub(p.start))/int64(span))
+}
+
+// Draws the number of participants present over time as a filled step curve between top and bottom
+func drawConcurrency(img *image.RGBA, plot plotArea, records []types.AttendanceRecord, top int, bottom int) {
+	// Count how many people are present in each pixel column
+	counts := make([]int, plot.right-plot.left+1)
+	for _, record := range records {
+		for _, session := range record.Sessions {
+			x0, x1 := plot.x(session.Join)-plot.left, plot.x(session.Leave)-plot.left
+			for x := max(x0, 0); x < min(max(x1, x0+1), len(counts)); x++ {
+				counts[x]++
+			}
+		}
+	}
+
+	peak := 0
+	for _, count := range counts {
+		peak = max(peak, count)
+	}
+	if peak == 0 {
+		return
+	}
+
+	fillRect(img, plot.left, bottom, plot.right, bottom+1, gridColor)
+	drawText(img, padding, top+10, fmt.Sprintf("Peak: %d", peak), textColor)
+
+	prevY := bottom
+	for i, count := range counts {
+		x := plot.left + i
+		y := bottom - (bottom-top)*count/peak
+		fillRect(img, x, y, x+1, bottom, curveFill)
+		// Connect vertical steps so the outline is continuous
+		fillRect(img, x, min(y, prevY), x+1, max(y, prevY)+1, curveColor)
+		prevY = y
+	}
+}
+
+func fillRect(img *image.RGBA, x0 int, y0 int, x1 int, y1 int, c color.Color) {
+	draw.Draw(img, image.Rect(x0, y0, x1, y1), &image.Uniform{c}, image.Point{}, draw.Over)
+}
+
+// Writes text with its baseline at (x, y)
+func drawText(img *image.RGBA, x int, y int, text string, c color.Color) {
+	d := font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
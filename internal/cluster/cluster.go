@@ -0,0 +1,343 @@
+// Elects one node of a high-availability cluster to talk to Discord while the rest follow along silently
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/alert"
+	"github.com/angelajfisher/meeting-mate/internal/peer"
+)
+
+const (
+	STATUS_SLUG = "/peer/status"
+
+	// How many polls in a row a peer can miss before it's considered gone
+	maxMissedPolls = 2
+)
+
+// What each node reports to the others when polled
+type Status struct {
+	NodeID   string `json:"node_id"`
+	Leader   string `json:"leader"`   // The node this one is following, or its own ID if it's leading
+	Eligible bool   `json:"eligible"` // False once the node has started shutting down
+}
+
+// A node in the cluster as last seen by this one
+type Member struct {
+	NodeID    string
+	Address   string // Empty for this node
+	Reachable bool
+	Leader    bool
+}
+
+type Cluster struct {
+	NodeID   string
+	peers    []*peer.Client
+	interval time.Duration
+	alerts   *alert.Alerter
+
+	mu       sync.RWMutex
+	leader   string
+	eligible bool
+	seen     map[string]peerState // map[peer address]last poll result
+
+	stop chan struct{}
+	once sync.Once
+}
+
+type peerState struct {
+	status Status
+	missed int
+}
+
+// Creates a cluster of this node and its peers, polling them at the given interval.
+// Without any peers, this node is always the leader.
+func New(nodeID string, peers []*peer.Client, interval time.Duration, alerts *alert.Alerter) *Cluster {
+	c := &Cluster{
+		NodeID:   nodeID,
+		peers:    peers,
+		interval: interval,
+		alerts:   alerts,
+		eligible: true,
+		seen:     make(map[string]peerState),
+		stop:     make(chan struct{}),
+	}
+	if len(peers) == 0 {
+		c.leader = nodeID
+	}
+	return c
+}
+
+// Whether this node should be posting to Discord
+func (c *Cluster) IsLeader() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.leader == c.NodeID
+}
+
+// The ID of the node currently posting to Discord, or empty if none is known
+func (c *Cluster) Leader() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.leader
+}
+
+// Whether this node has any peers at all
+func (c *Cluster) Clustered() bool {
+	return len(c.peers) > 0
+}
+
+// What this node reports to its peers
+func (c *Cluster) Status() Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return Status{NodeID: c.NodeID, Leader: c.leader, Eligible: c.eligible}
+}
+
+// Lists every node in the cluster, starting with this one
+func (c *Cluster) Members() []Member {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	members := []Member{{NodeID: c.NodeID, Reachable: true, Leader: c.leader == c.NodeID}}
+	for _, p := range c.peers {
+		state := c.seen[p.Address]
+		members = append(members, Member{
+			NodeID:    state.status.NodeID,
+			Address:   p.Address,
+			Reachable: state.missed < maxMissedPolls && state.status.NodeID != "",
+			Leader:    state.status.NodeID != "" && state.status.NodeID == c.leader,
+		})
+	}
+	return members
+}
+
+// Lists the peers that answered recently, starting with the leader if it's one of them
+func (c *Cluster) ReachablePeers() []*peer.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	reachable := []*peer.Client{}
+	for _, p := range c.peers {
+		state := c.seen[p.Address]
+		if state.missed >= maxMissedPolls || state.status.NodeID == "" {
+			continue
+		}
+		if state.status.NodeID == c.leader {
+			reachable = append([]*peer.Client{p}, reachable...)
+		} else {
+			reachable = append(reachable, p)
+		}
+	}
+	return reachable
+}
+
+// Polls every peer and settles on a leader. Called once at startup so the node knows its role before
+// connecting to Discord, then repeatedly by Run.
+//
+// A leader is only chosen while this node can reach a majority of the cluster, counting itself, so the smaller side
+// of a network split steps down rather than posting alongside the larger one. An even split is won by the side with
+// the lowest node ID, which lets a two-node cluster fail over to the lower node when the other goes quiet. Nodes that
+// stepped down before going quiet have left the cluster and don't count toward its size, so a graceful shutdown
+// fails over either way. Both sides of a split can still lead for up to maxMissedPolls intervals before the old
+// leader notices.
+func (c *Cluster) Elect() {
+	if len(c.peers) == 0 {
+		return
+	}
+
+	statuses := c.poll()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for address, status := range statuses {
+		state := c.seen[address]
+		if status == nil {
+			state.missed++
+			if state.missed == maxMissedPolls {
+				c.alerts.Raise(
+					"peer-down:"+address, "Cluster Node Unreachable",
+					"Node %s hasn't been able to reach %s for %d polls in a row.", c.NodeID, address, maxMissedPolls,
+				)
+			}
+		} else {
+			state = peerState{status: *status}
+		}
+		c.seen[address] = state
+	}
+
+	// Candidates are every eligible node we can reach, including ourselves
+	var candidates, claimants []string
+	if c.eligible {
+		candidates = append(candidates, c.NodeID)
+		if c.leader == c.NodeID {
+			claimants = append(claimants, c.NodeID)
+		}
+	}
+	for _, state := range c.seen {
+		if state.missed >= maxMissedPolls || !state.status.Eligible || state.status.NodeID == "" {
+			continue
+		}
+		candidates = append(candidates, state.status.NodeID)
+		if state.status.Leader == state.status.NodeID {
+			claimants = append(claimants, state.status.NodeID)
+		}
+	}
+
+	// Keep a sitting leader so a node rejoining doesn't take over; if several claim it (e.g. after a network
+	// split heals) or none do, the lowest ID wins
+	reachable, size, quorum := c.quorum()
+	leader := ""
+	switch {
+	case !quorum:
+		// This node may be on the smaller side of a split, so no one it can see is safe to follow
+	case len(claimants) > 0:
+		leader = slices.Min(claimants)
+	case len(candidates) > 0:
+		leader = slices.Min(candidates)
+	}
+
+	if leader != c.leader {
+		switch {
+		case !quorum:
+			log.Printf(
+				"Node %s can only reach %d of %d nodes in the cluster; no one leads until a majority is reachable",
+				c.NodeID, reachable, size,
+			)
+		case leader == c.NodeID:
+			log.Printf("Node %s is now the cluster leader", c.NodeID)
+		case c.leader == c.NodeID:
+			log.Printf("Node %s stepped down; %s is now the cluster leader", c.NodeID, leaderName(leader))
+		default:
+			log.Printf("Node %s is following %s", c.NodeID, leaderName(leader))
+		}
+		c.leader = leader
+	}
+}
+
+// Counts the nodes this one can reach, itself included, against the size of the cluster, and reports whether they
+// make up a majority or win an even split. Callers must hold the lock.
+func (c *Cluster) quorum() (reachable int, size int, ok bool) {
+	reachable, size = 1, 1
+	lowestReachable, lowestUnreachable := c.NodeID, ""
+	unknown := false // Whether any node this one can't reach has never told it its ID
+	for _, p := range c.peers {
+		state := c.seen[p.Address]
+		nodeID := state.status.NodeID
+		switch {
+		case nodeID == "":
+			size++
+			unknown = true
+		case state.missed < maxMissedPolls:
+			reachable++
+			size++
+			lowestReachable = min(lowestReachable, nodeID)
+		case !state.status.Eligible:
+			// Stepped down and then went quiet, so it has left rather than been cut off
+		default:
+			size++
+			if lowestUnreachable == "" || nodeID < lowestUnreachable {
+				lowestUnreachable = nodeID
+			}
+		}
+	}
+
+	if 2*reachable == size {
+		// The other half would make the same call, so exactly one side can win as long as every ID is known
+		return reachable, size, !unknown && lowestReachable < lowestUnreachable
+	}
+	return reachable, size, 2*reachable > size
+}
+
+// Re-elects at every interval until Stop is called. Always returns nil so it can be used in a run.Group.
+func (c *Cluster) Run() error {
+	if len(c.peers) == 0 {
+		<-c.stop
+		return nil
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return nil
+		case <-ticker.C:
+			c.Elect()
+		}
+	}
+}
+
+func (c *Cluster) Stop() {
+	c.once.Do(func() { close(c.stop) })
+}
+
+// Gives up leadership and any chance of regaining it, then reports whether another node is ready to take over
+func (c *Cluster) StepDown() bool {
+	c.mu.Lock()
+	c.eligible = false
+	c.leader = ""
+	c.mu.Unlock()
+
+	c.Elect()
+	return c.Leader() != ""
+}
+
+// Asks every peer for its status concurrently. Unreachable peers map to nil.
+func (c *Cluster) poll() map[string]*Status {
+	var (
+		results = make(map[string]*Status, len(c.peers))
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for _, p := range c.peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, err := fetchStatus(p)
+			if err != nil {
+				status = nil
+			}
+			mu.Lock()
+			results[p.Address] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func fetchStatus(p *peer.Client) (*Status, error) {
+	resp, err := p.Do(context.Background(), http.MethodGet, STATUS_SLUG, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer responded with %s", resp.Status)
+	}
+	var status Status
+	if err = json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("could not decode peer status: %w", err)
+	}
+	return &status, nil
+}
+
+func leaderName(nodeID string) string {
+	if nodeID == "" {
+		return "no one"
+	}
+	return nodeID
+}
@@ -0,0 +1,307 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/peer"
+)
+
+// A cluster whose links can be cut one way at a time. Node i reaches node j through a server of its own, so a
+// partition can be modeled by refusing just the requests that cross it.
+type testNetwork struct {
+	t     *testing.T
+	nodes []*Cluster
+	urls  [][]string // urls[i][j] is where node i reaches node j
+
+	mu   sync.Mutex
+	cut  map[[2]int]bool // Links that refuse requests
+	down map[int]bool    // Nodes that neither answer nor elect
+}
+
+func newTestNetwork(t *testing.T, size int) *testNetwork {
+	t.Helper()
+
+	n := &testNetwork{
+		t:     t,
+		nodes: make([]*Cluster, size),
+		urls:  make([][]string, size),
+		cut:   make(map[[2]int]bool),
+		down:  make(map[int]bool),
+	}
+	for i := range size {
+		n.urls[i] = make([]string, size)
+		for j := range size {
+			if i == j {
+				continue
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n.mu.Lock()
+				refused := n.cut[[2]int{i, j}] || n.down[j]
+				node := n.nodes[j]
+				n.mu.Unlock()
+				if refused {
+					http.Error(w, "unreachable", http.StatusServiceUnavailable)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(node.Status())
+			}))
+			t.Cleanup(server.Close)
+			n.urls[i][j] = server.URL
+		}
+	}
+	for i := range size {
+		n.start(i)
+	}
+	return n
+}
+
+// Starts a node afresh, as if its process had just been launched
+func (n *testNetwork) start(i int) {
+	n.t.Helper()
+
+	var peers []*peer.Client
+	for j, url := range n.urls[i] {
+		if j == i {
+			continue
+		}
+		client, err := peer.NewClient(url, peer.Config{Secret: "secret", Timeout: 5 * time.Second})
+		if err != nil {
+			n.t.Fatalf("NewClient: %s", err)
+		}
+		peers = append(peers, client)
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.nodes[i] = New(nodeName(i), peers, time.Second, nil)
+	delete(n.down, i)
+}
+
+func (n *testNetwork) stop(i int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.down[i] = true
+}
+
+// Cuts every link between nodes in different groups and restores the rest
+func (n *testNetwork) partition(groups ...[]int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	group := make(map[int]int)
+	for g, members := range groups {
+		for _, i := range members {
+			group[i] = g
+		}
+	}
+	n.cut = make(map[[2]int]bool)
+	for i := range n.nodes {
+		for j := range n.nodes {
+			if group[i] != group[j] {
+				n.cut[[2]int{i, j}] = true
+			}
+		}
+	}
+}
+
+func (n *testNetwork) heal() {
+	n.partition()
+}
+
+// Runs enough elections on every running node for unreachable peers to be noticed and a leader to settle
+func (n *testNetwork) settle() {
+	for range maxMissedPolls + 2 {
+		for i, node := range n.nodes {
+			n.mu.Lock()
+			down := n.down[i]
+			n.mu.Unlock()
+			if !down {
+				node.Elect()
+			}
+		}
+	}
+}
+
+// Checks who every running node follows, with "" meaning no one
+func (n *testNetwork) expectLeaders(want ...string) {
+	n.t.Helper()
+
+	for i, node := range n.nodes {
+		n.mu.Lock()
+		down := n.down[i]
+		n.mu.Unlock()
+		if down {
+			continue
+		}
+		if got := node.Leader(); got != want[i] {
+			n.t.Errorf("%s follows %q, want %q", node.NodeID, got, want[i])
+		}
+		if node.IsLeader() != (want[i] == node.NodeID) {
+			n.t.Errorf("%s IsLeader() = %t with leader %q", node.NodeID, node.IsLeader(), want[i])
+		}
+	}
+}
+
+func nodeName(i int) string {
+	return fmt.Sprintf("n%d", i+1)
+}
+
+func TestElectSingleNode(t *testing.T) {
+	c := New("n1", nil, time.Second, nil)
+	c.Elect()
+	if !c.IsLeader() {
+		t.Error("a node without peers isn't the leader")
+	}
+	if c.StepDown() {
+		t.Error("StepDown reported a failover with no peers")
+	}
+	if c.IsLeader() {
+		t.Error("still the leader after stepping down")
+	}
+}
+
+func TestElectThreeNodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario func(n *testNetwork)
+		want     []string
+	}{
+		{"all up", func(n *testNetwork) {}, []string{"n1", "n1", "n1"}},
+		{"follower down", func(n *testNetwork) {
+			n.stop(2)
+		}, []string{"n1", "n1", ""}},
+		{"leader down", func(n *testNetwork) {
+			n.stop(0)
+		}, []string{"", "n2", "n2"}},
+		{"two down", func(n *testNetwork) {
+			n.stop(0)
+			n.stop(1)
+		}, []string{"", "", ""}},
+		{"leader cut off", func(n *testNetwork) {
+			n.partition([]int{0}, []int{1, 2})
+		}, []string{"", "n2", "n2"}},
+		{"leader cut off, then healed", func(n *testNetwork) {
+			n.partition([]int{0}, []int{1, 2})
+			n.settle()
+			n.heal()
+		}, []string{"n2", "n2", "n2"}},
+		{"every node cut off", func(n *testNetwork) {
+			n.partition([]int{0}, []int{1}, []int{2})
+		}, []string{"", "", ""}},
+		{"starting with the others down", func(n *testNetwork) {
+			n.stop(1)
+			n.stop(2)
+			n.settle()
+			n.start(0)
+		}, []string{"", "", ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := newTestNetwork(t, 3)
+			n.settle()
+			n.expectLeaders("n1", "n1", "n1")
+
+			test.scenario(n)
+			n.settle()
+			n.expectLeaders(test.want...)
+		})
+	}
+}
+
+func TestElectTwoNodes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenario func(n *testNetwork)
+		want     []string
+	}{
+		{"both up", func(n *testNetwork) {}, []string{"n1", "n1"}},
+		// An even split goes to the side with the lowest ID
+		{"higher node down", func(n *testNetwork) {
+			n.stop(1)
+		}, []string{"n1", ""}},
+		{"lower node down", func(n *testNetwork) {
+			n.stop(0)
+		}, []string{"", ""}},
+		{"split", func(n *testNetwork) {
+			n.partition([]int{0}, []int{1})
+		}, []string{"n1", ""}},
+		{"split, then healed", func(n *testNetwork) {
+			n.partition([]int{0}, []int{1})
+			n.settle()
+			n.heal()
+		}, []string{"n1", "n1"}},
+		// Without ever hearing from its peer, a node can't tell whether it's on the winning side
+		{"starting with the peer down", func(n *testNetwork) {
+			n.stop(1)
+			n.settle()
+			n.start(0)
+		}, []string{"", ""}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			n := newTestNetwork(t, 2)
+			n.settle()
+			n.expectLeaders("n1", "n1")
+
+			test.scenario(n)
+			n.settle()
+			n.expectLeaders(test.want...)
+		})
+	}
+}
+
+// A node shutting down hands over to the others, who keep leading once it's gone
+func TestStepDown(t *testing.T) {
+	for _, size := range []int{2, 3} {
+		for leaving := range size {
+			t.Run(fmt.Sprintf("%d nodes, %s leaving", size, nodeName(leaving)), func(t *testing.T) {
+				n := newTestNetwork(t, size)
+				n.settle()
+
+				wasLeader := n.nodes[leaving].IsLeader()
+				if !n.nodes[leaving].StepDown() {
+					t.Fatal("StepDown reported no one ready to take over")
+				}
+				if n.nodes[leaving].IsLeader() {
+					t.Error("still the leader after stepping down")
+				}
+
+				// The first node left standing takes over if the leader stepped down; otherwise nothing changes
+				want := "n1"
+				if wasLeader {
+					want = nodeName((leaving + 1) % size)
+				}
+				n.settle()
+				n.stop(leaving)
+				n.settle()
+
+				wants := make([]string, size)
+				for i := range wants {
+					wants[i] = want
+				}
+				n.expectLeaders(wants...)
+			})
+		}
+	}
+}
+
+// Stepping down with no one to hand over to leaves the cluster without a leader
+func TestStepDownAlone(t *testing.T) {
+	n := newTestNetwork(t, 3)
+	n.settle()
+	n.partition([]int{0}, []int{1, 2})
+	n.settle()
+
+	if n.nodes[0].StepDown() {
+		t.Error("StepDown reported a failover with every peer unreachable")
+	}
+	n.settle()
+	n.expectLeaders("", "n2", "n2")
+}
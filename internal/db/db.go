@@ -1,6 +1,7 @@
 package db
 
 import (
+	"context"
 	"fmt"
 	"time"
 
@@ -10,8 +11,8 @@ import (
 
 const connTimeout = 5 * time.Second
 
+// The SQLite implementation of Store
 type DatabasePool struct {
-	Enabled  bool   // whether the program should use an external database
 	location string // cleaned filepath to db
 	pool     *sqlitex.Pool
 }
@@ -26,9 +27,28 @@ func NewDatabasePool(cleanedDbPath string) (DatabasePool, error) {
 	}
 
 	return DatabasePool{
-			Enabled:  true,
 			location: cleanedDbPath,
 			pool:     pool,
 		},
 		nil
 }
+
+func (db DatabasePool) Persistent() bool {
+	return true
+}
+
+// Takes a connection from the pool. The caller must hand it back by calling release once done.
+func (db DatabasePool) take() (*sqlite.Conn, func(), error) {
+	// The context interrupts the connection once it's done, so it must outlive every query run on it
+	ctx, cancel := context.WithTimeout(context.Background(), connTimeout)
+
+	conn, err := db.pool.Take(ctx)
+	if err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("could not get new connection from database: %w", err)
+	}
+	return conn, func() {
+		db.pool.Put(conn)
+		cancel()
+	}, nil
+}
@@ -0,0 +1,211 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// When and where a guild wants its weekly digest posted
+type DigestSettings struct {
+	GuildID   string
+	ChannelID string
+	Weekday   time.Weekday
+	Hour      int // In the guild's timezone
+	LastSent  time.Time
+}
+
+// Aggregate stats for a meeting over a period of time
+type MeetingStats struct {
+	MeetingID    string
+	MeetingTopic string
+	Occurrences  int
+	TotalTime    time.Duration
+	TopAttendees []AttendeeTotal
+}
+
+type AttendeeTotal struct {
+	Name      string
+	TotalTime time.Duration
+}
+
+func (db DatabasePool) GetAllDigestSettings() ([]DigestSettings, error) {
+	conn, release, err := db.take()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	settings := []DigestSettings{}
+	err = sqlitex.Execute(conn, `
+		SELECT
+			server_id,
+			channel_id,
+			weekday,
+			hour,
+			last_sent
+		FROM digests
+		ORDER BY server_id;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				settings = append(settings, DigestSettings{
+					GuildID:   stmt.ColumnText(0),
+					ChannelID: stmt.ColumnText(1),
+					Weekday:   time.Weekday(stmt.ColumnInt(2)),
+					Hour:      stmt.ColumnInt(3),
+					LastSent:  parseTime(stmt.ColumnText(4)),
+				})
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not get digest settings from database: %w", err)
+	}
+
+	return settings, nil
+}
+
+// Creates or replaces a guild's digest schedule
+func (db DatabasePool) SaveDigestSettings(settings DigestSettings) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		INSERT INTO digests (
+			server_id,
+			channel_id,
+			weekday,
+			hour
+		) VALUES (
+			?, ?, ?, ?
+		)
+		ON CONFLICT (server_id) DO UPDATE SET
+			channel_id = excluded.channel_id,
+			weekday = excluded.weekday,
+			hour = excluded.hour;`,
+		&sqlitex.ExecOptions{
+			Args: []any{settings.GuildID, settings.ChannelID, int(settings.Weekday), settings.Hour},
+		})
+	if err != nil {
+		return fmt.Errorf("could not save digest settings to database: %w", err)
+	}
+	return nil
+}
+
+func (db DatabasePool) DeleteDigestSettings(guildID string) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		DELETE FROM digests
+		WHERE server_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{guildID},
+		})
+	if err != nil {
+		return fmt.Errorf("could not delete digest settings from database: %w", err)
+	}
+	return nil
+}
+
+// Records when a guild's digest was last posted so it isn't sent twice
+func (db DatabasePool) MarkDigestSent(guildID string, sent time.Time) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		UPDATE digests
+		SET last_sent = ?
+		WHERE server_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{formatTime(sent), guildID},
+		})
+	if err != nil {
+		return fmt.Errorf("could not update digest in database: %w", err)
+	}
+	return nil
+}
+
+// Totals up how often a meeting happened within [from, to), how long it ran, and who attended the most
+func (db DatabasePool) GetMeetingStats(
+	meetingID string,
+	from time.Time,
+	to time.Time,
+	topN int,
+) (MeetingStats, error) {
+	stats := MeetingStats{MeetingID: meetingID}
+
+	conn, release, err := db.take()
+	if err != nil {
+		return stats, err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(unixepoch(end_time) - unixepoch(start_time)), 0),
+			(
+				SELECT meeting_topic
+				FROM meeting_history
+				WHERE meeting_id = ?1
+				ORDER BY start_time DESC, id DESC
+				LIMIT 1
+			)
+		FROM meeting_history
+		WHERE meeting_id = ?1
+			AND start_time >= ?2
+			AND start_time < ?3;`,
+		&sqlitex.ExecOptions{
+			Args: []any{meetingID, formatTime(from), formatTime(to)},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				stats.Occurrences = stmt.ColumnInt(0)
+				stats.TotalTime = time.Duration(stmt.ColumnInt64(1)) * time.Second
+				stats.MeetingTopic = stmt.ColumnText(2)
+				return nil
+			},
+		})
+	if err != nil {
+		return stats, fmt.Errorf("could not get meeting stats from database: %w", err)
+	}
+
+	err = sqlitex.Execute(conn, `
+		SELECT
+			attendance.participant_name,
+			SUM(unixepoch(attendance.leave_time) - unixepoch(attendance.join_time)) AS total
+		FROM attendance
+		JOIN meeting_history
+			ON meeting_history.id = attendance.history_id
+		WHERE meeting_history.meeting_id = ?
+			AND meeting_history.start_time >= ?
+			AND meeting_history.start_time < ?
+		GROUP BY attendance.participant_name
+		ORDER BY total DESC, attendance.participant_name
+		LIMIT ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{meetingID, formatTime(from), formatTime(to), topN},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				stats.TopAttendees = append(stats.TopAttendees, AttendeeTotal{
+					Name:      stmt.ColumnText(0),
+					TotalTime: time.Duration(stmt.ColumnInt64(1)) * time.Second,
+				})
+				return nil
+			},
+		})
+	if err != nil {
+		return stats, fmt.Errorf("could not get top attendees from database: %w", err)
+	}
+
+	return stats, nil
+}
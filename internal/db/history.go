@@ -0,0 +1,209 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// A single occurrence of a meeting along with everyone who attended it
+type MeetingHistory struct {
+	MeetingID    string
+	MeetingTopic string
+	StartTime    time.Time
+	EndTime      time.Time
+	Attendance   []types.AttendanceRecord
+	GuildIDs     []string // The servers watching the meeting when it ended, which may export its attendance
+}
+
+// A single stretch of time a participant spent in a past meeting
+type AttendanceRow struct {
+	MeetingID        string
+	MeetingTopic     string
+	MeetingStart     time.Time
+	ParticipantID    string
+	ParticipantName  string
+	ParticipantEmail string
+	JoinTime         time.Time
+	LeaveTime        time.Time
+}
+
+// Records a finished meeting and its attendance so it can be reported on later
+func (db DatabasePool) SaveMeetingHistory(history MeetingHistory) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = func() (err error) {
+		defer sqlitex.Save(conn)(&err)
+
+		err = sqlitex.Execute(conn, `
+			INSERT INTO meeting_history (
+				meeting_id,
+				meeting_topic,
+				start_time,
+				end_time
+			) VALUES (
+				?, ?, ?, ?
+			);`,
+			&sqlitex.ExecOptions{
+				Args: []any{
+					history.MeetingID,
+					history.MeetingTopic,
+					formatTime(history.StartTime),
+					formatTime(history.EndTime),
+				},
+			})
+		if err != nil {
+			return err
+		}
+		historyID := conn.LastInsertRowID()
+
+		for _, guildID := range history.GuildIDs {
+			err = sqlitex.Execute(conn, `
+				INSERT OR IGNORE INTO meeting_history_guilds (
+					history_id,
+					server_id
+				) VALUES (
+					?, ?
+				);`,
+				&sqlitex.ExecOptions{
+					Args: []any{historyID, guildID},
+				})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, record := range history.Attendance {
+			for _, session := range record.Sessions {
+				err = sqlitex.Execute(conn, `
+					INSERT INTO attendance (
+						history_id,
+						participant_id,
+						participant_name,
+						participant_email,
+						join_time,
+						leave_time
+					) VALUES (
+						?, ?, ?, ?, ?, ?
+					);`,
+					&sqlitex.ExecOptions{
+						Args: []any{
+							historyID,
+							record.ID,
+							record.Name,
+							record.Email,
+							formatTime(session.Join),
+							formatTime(session.Leave),
+						},
+					})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return fmt.Errorf("could not save meeting history to database: %w", err)
+	}
+	return nil
+}
+
+// Lists every attendance session for occurrences of the given meeting that started within [from, to)
+func (db DatabasePool) GetAttendance(meetingID string, from time.Time, to time.Time) ([]AttendanceRow, error) {
+	conn, release, err := db.take()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rows := []AttendanceRow{}
+	err = sqlitex.Execute(conn, `
+		SELECT
+			meeting_history.meeting_id,
+			meeting_history.meeting_topic,
+			meeting_history.start_time,
+			attendance.participant_id,
+			attendance.participant_name,
+			attendance.participant_email,
+			attendance.join_time,
+			attendance.leave_time
+		FROM attendance
+		JOIN meeting_history
+			ON meeting_history.id = attendance.history_id
+		WHERE meeting_history.meeting_id = ?
+			AND meeting_history.start_time >= ?
+			AND meeting_history.start_time < ?
+		ORDER BY meeting_history.start_time, meeting_history.id, attendance.join_time, attendance.rowid;`,
+		&sqlitex.ExecOptions{
+			Args: []any{meetingID, formatTime(from), formatTime(to)},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				rows = append(rows, AttendanceRow{
+					MeetingID:        stmt.ColumnText(0),
+					MeetingTopic:     stmt.ColumnText(1),
+					MeetingStart:     parseTime(stmt.ColumnText(2)),
+					ParticipantID:    stmt.ColumnText(3),
+					ParticipantName:  stmt.ColumnText(4),
+					ParticipantEmail: stmt.ColumnText(5),
+					JoinTime:         parseTime(stmt.ColumnText(6)),
+					LeaveTime:        parseTime(stmt.ColumnText(7)),
+				})
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not get attendance from database: %w", err)
+	}
+
+	return rows, nil
+}
+
+// Whether any occurrence of the given meeting was recorded while the guild was watching it
+func (db DatabasePool) HasMeetingHistory(guildID string, meetingID string) (bool, error) {
+	conn, release, err := db.take()
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	found := false
+	err = sqlitex.Execute(conn, `
+		SELECT 1
+		FROM meeting_history_guilds
+		JOIN meeting_history
+			ON meeting_history.id = meeting_history_guilds.history_id
+		WHERE meeting_history_guilds.server_id = ?
+			AND meeting_history.meeting_id = ?
+		LIMIT 1;`,
+		&sqlitex.ExecOptions{
+			Args: []any{guildID, meetingID},
+			ResultFunc: func(*sqlite.Stmt) error {
+				found = true
+				return nil
+			},
+		})
+	if err != nil {
+		return false, fmt.Errorf("could not check meeting history: %w", err)
+	}
+	return found, nil
+}
+
+// Times are stored as UTC text in Zoom's format so they sort and compare correctly as strings
+func formatTime(t time.Time) string {
+	return t.UTC().Format(types.ZOOM_TIME_FORMAT)
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(types.ZOOM_TIME_FORMAT, s)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,454 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+// The in-memory implementation of Store, used when the database is disabled. Everything is lost on shutdown.
+type MemoryStore struct {
+	watches map[[3]string]WatchData       // map[{guildID, meetingID, channelID}]watch
+	history []MeetingHistory              // In the order they were saved
+	digests map[string]DigestSettings     // map[guildID]settings
+	roles   map[[2]string][]string        // map[{guildID, action}]roleIDs
+	guilds  map[string]GuildSettings      // map[guildID]settings
+	queues  map[string][]ReplicationEvent // map[target]events, oldest first
+	mu      sync.RWMutex
+}
+
+var ErrDuplicateEvent = errors.New("event is already queued for this peer")
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		watches: make(map[[3]string]WatchData),
+		digests: make(map[string]DigestSettings),
+		roles:   make(map[[2]string][]string),
+		guilds:  make(map[string]GuildSettings),
+		queues:  make(map[string][]ReplicationEvent),
+	}
+}
+
+func (m *MemoryStore) Persistent() bool {
+	return false
+}
+
+func (m *MemoryStore) GetAllWatches() ([]WatchData, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	watches := make([]WatchData, 0, len(m.watches))
+	for _, watch := range m.watches {
+		watch.Roster = slices.Clone(watch.Roster)
+		watches = append(watches, watch)
+	}
+	sort.Slice(watches, func(i, j int) bool {
+		if watches[i].GuildID != watches[j].GuildID {
+			return watches[i].GuildID < watches[j].GuildID
+		}
+		if watches[i].MeetingID != watches[j].MeetingID {
+			return watches[i].MeetingID < watches[j].MeetingID
+		}
+		return watches[i].ChannelID < watches[j].ChannelID
+	})
+	return watches, nil
+}
+
+func (m *MemoryStore) SaveWatch(watch WatchData) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [3]string{watch.GuildID, watch.MeetingID, watch.ChannelID}
+	watch.Roster = m.watches[key].Roster
+	watch.StatusMessageID = m.watches[key].StatusMessageID
+	m.watches[key] = watch
+	return nil
+}
+
+func (m *MemoryStore) SaveWatchOptions(
+	guildID string,
+	meetingID string,
+	channelID string,
+	options types.FeatureFlags,
+) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [3]string{guildID, meetingID, channelID}
+	watch, exists := m.watches[key]
+	if !exists {
+		return fmt.Errorf("could not save watch options: %w", ErrUnknownWatch)
+	}
+	watch.Options = options
+	m.watches[key] = watch
+	return nil
+}
+
+func (m *MemoryStore) SaveStatusMessage(guildID string, meetingID string, channelID string, messageID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [3]string{guildID, meetingID, channelID}
+	watch, exists := m.watches[key]
+	if !exists {
+		return fmt.Errorf("could not save status message: %w", ErrUnknownWatch)
+	}
+	watch.StatusMessageID = messageID
+	m.watches[key] = watch
+	return nil
+}
+
+func (m *MemoryStore) DeleteWatch(guildID string, meetingID string, channelID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.watches, [3]string{guildID, meetingID, channelID})
+	return nil
+}
+
+func (m *MemoryStore) SaveRoster(guildID string, meetingID string, channelID string, roster []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [3]string{guildID, meetingID, channelID}
+	watch, exists := m.watches[key]
+	if !exists {
+		return fmt.Errorf("could not save roster: %w", ErrUnknownWatch)
+	}
+
+	watch.Roster = nil
+	for _, entry := range roster {
+		if !slices.Contains(watch.Roster, entry) {
+			watch.Roster = append(watch.Roster, entry)
+		}
+	}
+	m.watches[key] = watch
+	return nil
+}
+
+func (m *MemoryStore) SaveMeetingHistory(history MeetingHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history.StartTime = toSecond(history.StartTime)
+	history.EndTime = toSecond(history.EndTime)
+	attendance := slices.Clone(history.Attendance)
+	for i, record := range attendance {
+		attendance[i].Sessions = slices.Clone(record.Sessions)
+		for j, session := range attendance[i].Sessions {
+			attendance[i].Sessions[j].Join = toSecond(session.Join)
+			attendance[i].Sessions[j].Leave = toSecond(session.Leave)
+		}
+	}
+	history.Attendance = attendance
+	history.GuildIDs = slices.Clone(history.GuildIDs)
+
+	m.history = append(m.history, history)
+	return nil
+}
+
+func (m *MemoryStore) HasMeetingHistory(guildID string, meetingID string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.ContainsFunc(m.history, func(stored MeetingHistory) bool {
+		return stored.MeetingID == meetingID && slices.Contains(stored.GuildIDs, guildID)
+	}), nil
+}
+
+func (m *MemoryStore) GetAttendance(meetingID string, from time.Time, to time.Time) ([]AttendanceRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	occurrences := m.occurrences(meetingID, from, to)
+	rows := []AttendanceRow{}
+	for _, stored := range occurrences {
+		var meetingRows []AttendanceRow
+		for _, record := range stored.Attendance {
+			for _, session := range record.Sessions {
+				meetingRows = append(meetingRows, AttendanceRow{
+					MeetingID:        stored.MeetingID,
+					MeetingTopic:     stored.MeetingTopic,
+					MeetingStart:     stored.StartTime,
+					ParticipantID:    record.ID,
+					ParticipantName:  record.Name,
+					ParticipantEmail: record.Email,
+					JoinTime:         session.Join,
+					LeaveTime:        session.Leave,
+				})
+			}
+		}
+		sort.SliceStable(meetingRows, func(i, j int) bool {
+			return meetingRows[i].JoinTime.Before(meetingRows[j].JoinTime)
+		})
+		rows = append(rows, meetingRows...)
+	}
+	return rows, nil
+}
+
+func (m *MemoryStore) GetMeetingStats(
+	meetingID string,
+	from time.Time,
+	to time.Time,
+	topN int,
+) (MeetingStats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stats := MeetingStats{MeetingID: meetingID}
+
+	// The topic comes from the latest occurrence, in range or not
+	var latest *MeetingHistory
+	for i, stored := range m.history {
+		if stored.MeetingID == meetingID &&
+			(latest == nil || !stored.StartTime.Before(latest.StartTime)) {
+			latest = &m.history[i]
+		}
+	}
+	if latest != nil {
+		stats.MeetingTopic = latest.MeetingTopic
+	}
+
+	totals := make(map[string]time.Duration) // map[participant name]time attended
+	for _, stored := range m.occurrences(meetingID, from, to) {
+		stats.Occurrences++
+		stats.TotalTime += stored.EndTime.Sub(stored.StartTime)
+		for _, record := range stored.Attendance {
+			for _, session := range record.Sessions {
+				totals[record.Name] += session.Leave.Sub(session.Join)
+			}
+		}
+	}
+
+	for name, total := range totals {
+		stats.TopAttendees = append(stats.TopAttendees, AttendeeTotal{Name: name, TotalTime: total})
+	}
+	sort.Slice(stats.TopAttendees, func(i, j int) bool {
+		if stats.TopAttendees[i].TotalTime != stats.TopAttendees[j].TotalTime {
+			return stats.TopAttendees[i].TotalTime > stats.TopAttendees[j].TotalTime
+		}
+		return stats.TopAttendees[i].Name < stats.TopAttendees[j].Name
+	})
+	if len(stats.TopAttendees) > topN {
+		stats.TopAttendees = stats.TopAttendees[:max(topN, 0)]
+	}
+
+	return stats, nil
+}
+
+func (m *MemoryStore) GetAllDigestSettings() ([]DigestSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	settings := make([]DigestSettings, 0, len(m.digests))
+	for _, s := range m.digests {
+		settings = append(settings, s)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].GuildID < settings[j].GuildID })
+	return settings, nil
+}
+
+func (m *MemoryStore) SaveDigestSettings(settings DigestSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	settings.LastSent = m.digests[settings.GuildID].LastSent
+	m.digests[settings.GuildID] = settings
+	return nil
+}
+
+func (m *MemoryStore) DeleteDigestSettings(guildID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.digests, guildID)
+	return nil
+}
+
+func (m *MemoryStore) MarkDigestSent(guildID string, sent time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if settings, exists := m.digests[guildID]; exists {
+		settings.LastSent = toSecond(sent)
+		m.digests[guildID] = settings
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetAllGuildSettings() ([]GuildSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	allSettings := make([]GuildSettings, 0, len(m.guilds))
+	for _, settings := range m.guilds {
+		settings.MentionRoles = slices.Clone(settings.MentionRoles)
+		allSettings = append(allSettings, settings)
+	}
+	sort.Slice(allSettings, func(i, j int) bool { return allSettings[i].GuildID < allSettings[j].GuildID })
+	return allSettings, nil
+}
+
+func (m *MemoryStore) GetGuildSettings(guildID string) (GuildSettings, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	settings, exists := m.guilds[guildID]
+	if !exists {
+		return DefaultGuildSettings(guildID), nil
+	}
+	settings.MentionRoles = slices.Clone(settings.MentionRoles)
+	return settings, nil
+}
+
+func (m *MemoryStore) SaveGuildSettings(settings GuildSettings) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	settings.Defaults = types.FeatureFlags{
+		Silent:        settings.Defaults.Silent,
+		SummaryLevel:  settings.Defaults.SummaryLevel,
+		HistoryLevel:  settings.Defaults.HistoryLevel,
+		TimelineChart: settings.Defaults.TimelineChart,
+	}
+	settings.MentionRoles = slices.Clone(settings.MentionRoles)
+	m.guilds[settings.GuildID] = settings
+	return nil
+}
+
+func (m *MemoryStore) DeleteGuildSettings(guildID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.guilds, guildID)
+	return nil
+}
+
+func (m *MemoryStore) GetAllCommandRoles() ([]CommandRole, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	roles := []CommandRole{}
+	for key, roleIDs := range m.roles {
+		for _, roleID := range roleIDs {
+			roles = append(roles, CommandRole{GuildID: key[0], Action: key[1], RoleID: roleID})
+		}
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].GuildID != roles[j].GuildID {
+			return roles[i].GuildID < roles[j].GuildID
+		}
+		if roles[i].Action != roles[j].Action {
+			return roles[i].Action < roles[j].Action
+		}
+		return roles[i].RoleID < roles[j].RoleID
+	})
+	return roles, nil
+}
+
+func (m *MemoryStore) GetCommandRoles(guildID string) (map[string][]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	roles := make(map[string][]string)
+	for key, roleIDs := range m.roles {
+		if key[0] == guildID && len(roleIDs) > 0 {
+			roles[key[1]] = slices.Sorted(slices.Values(roleIDs))
+		}
+	}
+	return roles, nil
+}
+
+func (m *MemoryStore) AddCommandRole(guildID string, action string, roleID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]string{guildID, action}
+	if !slices.Contains(m.roles[key], roleID) {
+		m.roles[key] = append(m.roles[key], roleID)
+	}
+	return nil
+}
+
+func (m *MemoryStore) RemoveCommandRole(guildID string, action string, roleID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := [2]string{guildID, action}
+	m.roles[key] = slices.DeleteFunc(m.roles[key], func(r string) bool { return r == roleID })
+	return nil
+}
+
+func (m *MemoryStore) PushReplicationEvent(target string, event ReplicationEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if slices.ContainsFunc(m.queues[target], func(e ReplicationEvent) bool { return e.ID == event.ID }) {
+		return fmt.Errorf("could not queue replication event: %w", ErrDuplicateEvent)
+	}
+	event.Payload = slices.Clone(event.Payload)
+	m.queues[target] = append(m.queues[target], event)
+	return nil
+}
+
+func (m *MemoryStore) PeekReplicationEvent(target string) (ReplicationEvent, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.queues[target]) == 0 {
+		return ReplicationEvent{}, false, nil
+	}
+	event := m.queues[target][0]
+	event.Payload = slices.Clone(event.Payload)
+	return event, true, nil
+}
+
+func (m *MemoryStore) GetReplicationEvents(target string) ([]ReplicationEvent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	events := make([]ReplicationEvent, len(m.queues[target]))
+	for i, event := range m.queues[target] {
+		event.Payload = slices.Clone(event.Payload)
+		events[i] = event
+	}
+	return events, nil
+}
+
+func (m *MemoryStore) DeleteReplicationEvent(target string, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queues[target] = slices.DeleteFunc(m.queues[target], func(e ReplicationEvent) bool { return e.ID == id })
+	if len(m.queues[target]) == 0 {
+		delete(m.queues, target)
+	}
+	return nil
+}
+
+// Lists occurrences of a meeting that started within [from, to) in start order. Callers must hold the lock.
+func (m *MemoryStore) occurrences(meetingID string, from time.Time, to time.Time) []MeetingHistory {
+	from, to = toSecond(from), toSecond(to)
+
+	var matches []MeetingHistory
+	for _, stored := range m.history {
+		start := stored.StartTime
+		if stored.MeetingID == meetingID && !start.Before(from) && start.Before(to) {
+			matches = append(matches, stored)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].StartTime.Before(matches[j].StartTime)
+	})
+	return matches
+}
+
+// Drops anything finer than a second, matching how SQLite stores times
+func toSecond(t time.Time) time.Time {
+	return parseTime(formatTime(t))
+}
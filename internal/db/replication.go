@@ -0,0 +1,141 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// An event waiting in the outbound replication queue
+type ReplicationEvent struct {
+	ID         string
+	Kind       string // What the payload holds, e.g. a Zoom webhook or a watch change
+	Origin     string
+	ReceivedAt time.Time
+	Epoch      int64 // When the origin node started
+	Seq        int64 // Which of the origin node's changes this is since it started
+	Payload    []byte
+}
+
+// Adds an event to the end of the outbound replication queue for the given peer
+func (db DatabasePool) PushReplicationEvent(target string, event ReplicationEvent) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		INSERT INTO replication_queue (
+			id,
+			target,
+			kind,
+			origin,
+			received_at,
+			epoch,
+			origin_seq,
+			payload
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?
+		);`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				event.ID,
+				target,
+				event.Kind,
+				event.Origin,
+				event.ReceivedAt.UTC().Format(time.RFC3339Nano),
+				event.Epoch,
+				event.Seq,
+				event.Payload,
+			},
+		})
+	if sqlite.ErrCode(err) == sqlite.ResultConstraintUnique {
+		err = ErrDuplicateEvent
+	}
+	if err != nil {
+		return fmt.Errorf("could not queue replication event: %w", err)
+	}
+	return nil
+}
+
+// Returns the oldest event in the outbound replication queue for the given peer, if there is one
+func (db DatabasePool) PeekReplicationEvent(target string) (ReplicationEvent, bool, error) {
+	events, err := db.queuedEvents(target, 1)
+	if err != nil || len(events) == 0 {
+		return ReplicationEvent{}, false, err
+	}
+	return events[0], true, nil
+}
+
+// Lists every event in the outbound replication queue for the given peer, oldest first
+func (db DatabasePool) GetReplicationEvents(target string) ([]ReplicationEvent, error) {
+	return db.queuedEvents(target, -1)
+}
+
+// Lists up to limit events queued for the given peer, oldest first. A negative limit lists them all.
+func (db DatabasePool) queuedEvents(target string, limit int) ([]ReplicationEvent, error) {
+	conn, release, err := db.take()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var events []ReplicationEvent
+	err = sqlitex.Execute(conn, `
+		SELECT
+			id,
+			kind,
+			origin,
+			received_at,
+			epoch,
+			origin_seq,
+			payload
+		FROM replication_queue
+		WHERE target = ?
+		ORDER BY seq
+		LIMIT ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{target, limit},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				event := ReplicationEvent{
+					ID:     stmt.ColumnText(0),
+					Kind:   stmt.ColumnText(1),
+					Origin: stmt.ColumnText(2),
+					Epoch:  stmt.ColumnInt64(4),
+					Seq:    stmt.ColumnInt64(5),
+				}
+				event.ReceivedAt, _ = time.Parse(time.RFC3339Nano, stmt.ColumnText(3))
+				event.Payload = make([]byte, stmt.ColumnLen(6))
+				stmt.ColumnBytes(6, event.Payload)
+				events = append(events, event)
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not read replication queue: %w", err)
+	}
+	return events, nil
+}
+
+// Removes an event from the outbound replication queue once the given peer has accepted it
+func (db DatabasePool) DeleteReplicationEvent(target string, id string) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		DELETE FROM replication_queue
+		WHERE target = ? AND id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{target, id},
+		})
+	if err != nil {
+		return fmt.Errorf("could not remove replication event: %w", err)
+	}
+	return nil
+}
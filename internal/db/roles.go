@@ -0,0 +1,121 @@
+package db
+
+import (
+	"fmt"
+
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// A role allowed to take an action on a guild's watches
+type CommandRole struct {
+	GuildID string
+	Action  string
+	RoleID  string
+}
+
+func (db DatabasePool) GetAllCommandRoles() ([]CommandRole, error) {
+	conn, release, err := db.take()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	roles := []CommandRole{}
+	err = sqlitex.Execute(conn, `
+		SELECT
+			server_id,
+			action,
+			role_id
+		FROM command_roles
+		ORDER BY server_id, action, role_id;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				roles = append(roles, CommandRole{
+					GuildID: stmt.ColumnText(0),
+					Action:  stmt.ColumnText(1),
+					RoleID:  stmt.ColumnText(2),
+				})
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not get all command roles from database: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (db DatabasePool) GetCommandRoles(guildID string) (map[string][]string, error) {
+	conn, release, err := db.take()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	roles := make(map[string][]string)
+	err = sqlitex.Execute(conn, `
+		SELECT
+			action,
+			role_id
+		FROM command_roles
+		WHERE server_id = ?
+		ORDER BY action, role_id;`,
+		&sqlitex.ExecOptions{
+			Args: []any{guildID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				action := stmt.ColumnText(0)
+				roles[action] = append(roles[action], stmt.ColumnText(1))
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not get command roles from database: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (db DatabasePool) AddCommandRole(guildID string, action string, roleID string) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		INSERT INTO command_roles (
+			server_id,
+			action,
+			role_id
+		) VALUES (
+			?, ?, ?
+		)
+		ON CONFLICT DO NOTHING;`,
+		&sqlitex.ExecOptions{
+			Args: []any{guildID, action, roleID},
+		})
+	if err != nil {
+		return fmt.Errorf("could not save command role to database: %w", err)
+	}
+	return nil
+}
+
+func (db DatabasePool) RemoveCommandRole(guildID string, action string, roleID string) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		DELETE FROM command_roles
+		WHERE server_id = ? AND action = ? AND role_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{guildID, action, roleID},
+		})
+	if err != nil {
+		return fmt.Errorf("could not delete command role from database: %w", err)
+	}
+	return nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 
@@ -37,8 +36,8 @@ func InitializeDatabase(path string) error {
 	return nil
 }
 
-// Updates database schema as needed
-func MakeMigrations(path string) error {
+// Updates database schema as needed. Any migration that fails is passed to onError.
+func MakeMigrations(path string, onError func(error)) error {
 	schema := []string{`
 		CREATE TABLE IF NOT EXISTS history_types (
 			type TEXT PRIMARY KEY
@@ -64,6 +63,222 @@ func MakeMigrations(path string) error {
 			FOREIGN KEY (history_type)
 				REFERENCES history_types (type)
 		);
+	`, `
+		CREATE TABLE IF NOT EXISTS summary_types (
+			type TEXT PRIMARY KEY
+		);
+
+		INSERT INTO summary_types (type)
+		VALUES
+			('None'),
+			('Basic'),
+			('Detailed'),
+			('Full');
+
+		CREATE TABLE watches_new (
+			meeting_id TEXT NOT NULL,
+			server_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			meeting_topic TEXT,
+			silent BOOL DEFAULT 1,
+			summary_type TEXT NOT NULL DEFAULT 'Basic',
+			history_type TEXT NOT NULL DEFAULT 'Partial',
+			command TEXT NOT NULL,
+			link TEXT,
+			PRIMARY KEY(meeting_id, server_id),
+			FOREIGN KEY (summary_type)
+				REFERENCES summary_types (type),
+			FOREIGN KEY (history_type)
+				REFERENCES history_types (type)
+		);
+
+		INSERT INTO watches_new
+		SELECT
+			meeting_id,
+			server_id,
+			channel_id,
+			meeting_topic,
+			silent,
+			CASE WHEN summary THEN 'Basic' ELSE 'None' END,
+			history_type,
+			command,
+			link
+		FROM watches;
+
+		DROP TABLE watches;
+		ALTER TABLE watches_new RENAME TO watches;
+	`, `
+		CREATE TABLE IF NOT EXISTS meeting_history (
+			id INTEGER PRIMARY KEY,
+			meeting_id TEXT NOT NULL,
+			meeting_topic TEXT,
+			start_time TEXT NOT NULL,
+			end_time TEXT NOT NULL
+		);
+
+		CREATE INDEX IF NOT EXISTS meeting_history_by_meeting
+			ON meeting_history (meeting_id, start_time);
+
+		CREATE TABLE IF NOT EXISTS attendance (
+			history_id INTEGER NOT NULL,
+			participant_id TEXT NOT NULL,
+			participant_name TEXT NOT NULL,
+			participant_email TEXT,
+			join_time TEXT NOT NULL,
+			leave_time TEXT NOT NULL,
+			FOREIGN KEY (history_id)
+				REFERENCES meeting_history (id)
+				ON DELETE CASCADE
+		);
+	`, `
+		ALTER TABLE watches ADD COLUMN timeline BOOL NOT NULL DEFAULT 0;
+	`, `
+		CREATE TABLE IF NOT EXISTS rosters (
+			meeting_id TEXT NOT NULL,
+			server_id TEXT NOT NULL,
+			entry TEXT NOT NULL,
+			PRIMARY KEY(meeting_id, server_id, entry),
+			FOREIGN KEY (meeting_id, server_id)
+				REFERENCES watches (meeting_id, server_id)
+				ON DELETE CASCADE
+		);
+	`, `
+		CREATE TABLE IF NOT EXISTS digests (
+			server_id TEXT PRIMARY KEY,
+			channel_id TEXT NOT NULL,
+			weekday INTEGER NOT NULL CHECK (weekday BETWEEN 0 AND 6),
+			hour INTEGER NOT NULL CHECK (hour BETWEEN 0 AND 23),
+			last_sent TEXT
+		);
+	`, `
+		CREATE TABLE IF NOT EXISTS replication_queue (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			id TEXT NOT NULL UNIQUE,
+			origin TEXT NOT NULL,
+			received_at TEXT NOT NULL,
+			payload BLOB NOT NULL
+		);
+	`, `
+		-- Each peer in a cluster gets its own queue. Events left over from a two-node pair can't be attributed
+		-- to a peer, so they're dropped.
+		DROP TABLE replication_queue;
+		CREATE TABLE replication_queue (
+			seq INTEGER PRIMARY KEY AUTOINCREMENT,
+			id TEXT NOT NULL,
+			target TEXT NOT NULL,
+			origin TEXT NOT NULL,
+			received_at TEXT NOT NULL,
+			payload BLOB NOT NULL,
+			UNIQUE (id, target)
+		);
+	`, `
+		ALTER TABLE replication_queue ADD COLUMN kind TEXT NOT NULL DEFAULT 'zoom';
+	`, `
+		ALTER TABLE watches ADD COLUMN status_message_id TEXT NOT NULL DEFAULT '';
+	`, `
+		CREATE TABLE IF NOT EXISTS command_roles (
+			server_id TEXT NOT NULL,
+			action TEXT NOT NULL CHECK (action IN ('create', 'modify', 'cancel')),
+			role_id TEXT NOT NULL,
+			PRIMARY KEY (server_id, action, role_id)
+		);
+	`, `
+		CREATE TABLE IF NOT EXISTS guild_settings (
+			server_id TEXT PRIMARY KEY,
+			channel_id TEXT NOT NULL DEFAULT '',
+			silent BOOL NOT NULL DEFAULT 1,
+			summary_type TEXT NOT NULL DEFAULT 'Basic',
+			history_type TEXT NOT NULL DEFAULT 'Partial',
+			timeline BOOL NOT NULL DEFAULT 0,
+			timezone TEXT NOT NULL DEFAULT '',
+			locale TEXT NOT NULL DEFAULT '',
+			mention_roles TEXT NOT NULL DEFAULT '', -- Space-separated role IDs
+			FOREIGN KEY (summary_type)
+				REFERENCES summary_types (type),
+			FOREIGN KEY (history_type)
+				REFERENCES history_types (type)
+		);
+	`, `
+		-- A meeting can be watched from several channels in the same server, each with its own options and roster.
+		-- Tables are rebuilt in place of the old ones so the rosters' foreign key follows the renamed watches.
+		CREATE TABLE watches_new (
+			meeting_id TEXT NOT NULL,
+			server_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			meeting_topic TEXT,
+			silent BOOL DEFAULT 1,
+			summary_type TEXT NOT NULL DEFAULT 'Basic',
+			history_type TEXT NOT NULL DEFAULT 'Partial',
+			command TEXT NOT NULL,
+			link TEXT,
+			timeline BOOL NOT NULL DEFAULT 0,
+			status_message_id TEXT NOT NULL DEFAULT '',
+			PRIMARY KEY(meeting_id, server_id, channel_id),
+			FOREIGN KEY (summary_type)
+				REFERENCES summary_types (type),
+			FOREIGN KEY (history_type)
+				REFERENCES history_types (type)
+		);
+
+		INSERT INTO watches_new
+		SELECT
+			meeting_id,
+			server_id,
+			channel_id,
+			meeting_topic,
+			silent,
+			summary_type,
+			history_type,
+			command,
+			link,
+			timeline,
+			status_message_id
+		FROM watches;
+
+		CREATE TABLE rosters_new (
+			meeting_id TEXT NOT NULL,
+			server_id TEXT NOT NULL,
+			channel_id TEXT NOT NULL,
+			entry TEXT NOT NULL,
+			PRIMARY KEY(meeting_id, server_id, channel_id, entry),
+			FOREIGN KEY (meeting_id, server_id, channel_id)
+				REFERENCES watches_new (meeting_id, server_id, channel_id)
+				ON DELETE CASCADE
+		);
+
+		INSERT INTO rosters_new
+		SELECT
+			rosters.meeting_id,
+			rosters.server_id,
+			watches.channel_id,
+			rosters.entry
+		FROM rosters
+		JOIN watches USING (meeting_id, server_id)
+		ORDER BY rosters.rowid;
+
+		DROP TABLE rosters;
+		DROP TABLE watches;
+		ALTER TABLE watches_new RENAME TO watches;
+		ALTER TABLE rosters_new RENAME TO rosters;
+	`, `
+		-- Where each event falls among its origin's changes. Events queued before this are left unversioned.
+		ALTER TABLE replication_queue ADD COLUMN epoch INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE replication_queue ADD COLUMN origin_seq INTEGER NOT NULL DEFAULT 0;
+	`, `
+		CREATE TABLE IF NOT EXISTS meeting_history_guilds (
+			history_id INTEGER NOT NULL,
+			server_id TEXT NOT NULL,
+			PRIMARY KEY (history_id, server_id),
+			FOREIGN KEY (history_id)
+				REFERENCES meeting_history (id)
+				ON DELETE CASCADE
+		);
+
+		-- History recorded before servers were tagged goes to the servers watching the meeting now
+		INSERT OR IGNORE INTO meeting_history_guilds
+		SELECT meeting_history.id, watches.server_id
+		FROM meeting_history
+		JOIN watches USING (meeting_id);
 	`}
 
 	pool := sqlitemigration.NewPool(
@@ -74,6 +289,12 @@ func MakeMigrations(path string) error {
 				{
 					DisableForeignKeys: false,
 				},
+				nil,
+				nil,
+				{
+					// Rebuilding a table requires foreign keys be off while the old one is dropped
+					DisableForeignKeys: true,
+				},
 			},
 		},
 		sqlitemigration.Options{
@@ -82,9 +303,7 @@ func MakeMigrations(path string) error {
 				// Enable foreign keys
 				return sqlitex.ExecuteTransient(conn, "PRAGMA foreign_keys = ON;", nil)
 			},
-			OnError: func(e error) {
-				log.Println("could not make database migrations: ", e)
-			},
+			OnError: onError,
 		})
 	defer pool.Close()
 
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // Timezones shouldn't depend on the host having them installed
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+	"zombiezen.com/go/sqlite"
+	"zombiezen.com/go/sqlite/sqlitex"
+)
+
+// A guild's defaults for new watches and how the bot presents things there
+type GuildSettings struct {
+	GuildID      string
+	Defaults     types.FeatureFlags // Only Silent, SummaryLevel, HistoryLevel, and TimelineChart are used
+	ChannelID    string             // Where new watches post, or empty for wherever `/watch` was used
+	Timezone     string             // IANA name, or empty for UTC
+	Locale       string             // Discord locale, or empty for Discord's default
+	MentionRoles []string           // Pinged whenever a watched meeting starts
+}
+
+// Locales that write times of day on a 12-hour clock
+var twelveHourLocales = map[string]bool{"en-US": true, "hi": true, "ko": true, "zh-TW": true}
+
+// The settings of a guild that hasn't changed any
+func DefaultGuildSettings(guildID string) GuildSettings {
+	return GuildSettings{
+		GuildID: guildID,
+		Defaults: types.FeatureFlags{
+			Silent:       true,
+			SummaryLevel: types.BASIC_SUMMARY,
+			HistoryLevel: types.PARTIAL_HISTORY,
+		},
+	}
+}
+
+// The guild's timezone, falling back to UTC if it's unset or unknown
+func (gs GuildSettings) Location() *time.Location {
+	if gs.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(gs.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// The layout times of day are written in for the guild's locale
+func (gs GuildSettings) ClockFormat() string {
+	if twelveHourLocales[gs.Locale] {
+		return "3:04 PM"
+	}
+	return "15:04"
+}
+
+// Lists the settings of every guild that has changed any, ordered by guild
+func (db DatabasePool) GetAllGuildSettings() ([]GuildSettings, error) {
+	conn, release, err := db.take()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	allSettings := []GuildSettings{}
+	err = sqlitex.Execute(conn, `
+		SELECT
+			server_id,
+			channel_id,
+			silent,
+			summary_type,
+			history_type,
+			timeline,
+			timezone,
+			locale,
+			mention_roles
+		FROM guild_settings
+		ORDER BY server_id;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				allSettings = append(allSettings, GuildSettings{
+					GuildID:   stmt.ColumnText(0),
+					ChannelID: stmt.ColumnText(1),
+					Defaults: types.FeatureFlags{
+						Silent:        stmt.ColumnBool(2),
+						SummaryLevel:  stmt.ColumnText(3),
+						HistoryLevel:  stmt.ColumnText(4),
+						TimelineChart: stmt.ColumnBool(5),
+					},
+					Timezone:     stmt.ColumnText(6),
+					Locale:       stmt.ColumnText(7),
+					MentionRoles: strings.Fields(stmt.ColumnText(8)),
+				})
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not get all guild settings from database: %w", err)
+	}
+
+	return allSettings, nil
+}
+
+func (db DatabasePool) GetGuildSettings(guildID string) (GuildSettings, error) {
+	conn, release, err := db.take()
+	if err != nil {
+		return GuildSettings{}, err
+	}
+	defer release()
+
+	settings := DefaultGuildSettings(guildID)
+	err = sqlitex.Execute(conn, `
+		SELECT
+			channel_id,
+			silent,
+			summary_type,
+			history_type,
+			timeline,
+			timezone,
+			locale,
+			mention_roles
+		FROM guild_settings
+		WHERE server_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{guildID},
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				settings.ChannelID = stmt.ColumnText(0)
+				settings.Defaults.Silent = stmt.ColumnBool(1)
+				settings.Defaults.SummaryLevel = stmt.ColumnText(2)
+				settings.Defaults.HistoryLevel = stmt.ColumnText(3)
+				settings.Defaults.TimelineChart = stmt.ColumnBool(4)
+				settings.Timezone = stmt.ColumnText(5)
+				settings.Locale = stmt.ColumnText(6)
+				settings.MentionRoles = strings.Fields(stmt.ColumnText(7))
+				return nil
+			},
+		})
+	if err != nil {
+		return GuildSettings{}, fmt.Errorf("could not get guild settings from database: %w", err)
+	}
+
+	return settings, nil
+}
+
+// Creates or replaces a guild's settings
+func (db DatabasePool) SaveGuildSettings(settings GuildSettings) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		INSERT INTO guild_settings (
+			server_id,
+			channel_id,
+			silent,
+			summary_type,
+			history_type,
+			timeline,
+			timezone,
+			locale,
+			mention_roles
+		) VALUES (
+			?, ?, ?, ?, ?, ?, ?, ?, ?
+		)
+		ON CONFLICT (server_id) DO UPDATE SET
+			channel_id = excluded.channel_id,
+			silent = excluded.silent,
+			summary_type = excluded.summary_type,
+			history_type = excluded.history_type,
+			timeline = excluded.timeline,
+			timezone = excluded.timezone,
+			locale = excluded.locale,
+			mention_roles = excluded.mention_roles;`,
+		&sqlitex.ExecOptions{
+			Args: []any{
+				settings.GuildID,
+				settings.ChannelID,
+				settings.Defaults.Silent,
+				settings.Defaults.SummaryLevel,
+				settings.Defaults.HistoryLevel,
+				settings.Defaults.TimelineChart,
+				settings.Timezone,
+				settings.Locale,
+				strings.Join(settings.MentionRoles, " "),
+			},
+		})
+	if err != nil {
+		return fmt.Errorf("could not save guild settings to database: %w", err)
+	}
+	return nil
+}
+
+func (db DatabasePool) DeleteGuildSettings(guildID string) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = sqlitex.Execute(conn, `
+		DELETE FROM guild_settings
+		WHERE server_id = ?;`,
+		&sqlitex.ExecOptions{
+			Args: []any{guildID},
+		})
+	if err != nil {
+		return fmt.Errorf("could not delete guild settings from database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+var ErrUnknownWatch = errors.New("no such watch")
+
+// Everything Meeting Mate saves: watches, meeting history, settings, and events waiting to be replicated.
+// DatabasePool keeps it in SQLite; MemoryStore keeps it in memory, with the same behavior, for when the database
+// is disabled.
+type Store interface {
+	// Whether saved data survives a restart
+	Persistent() bool
+
+	// Lists every watch with its roster, ordered by guild, then meeting, then channel
+	GetAllWatches() ([]WatchData, error)
+	// Creates a watch or replaces its details, leaving its roster and status message as is
+	SaveWatch(watch WatchData) error
+	// Replaces a watch's options, leaving everything else as is. Returns ErrUnknownWatch if the watch doesn't exist.
+	SaveWatchOptions(guildID string, meetingID string, channelID string, options types.FeatureFlags) error
+	// Records which message shows a watch's status, or that none does if the ID is empty.
+	// Returns ErrUnknownWatch if the watch doesn't exist.
+	SaveStatusMessage(guildID string, meetingID string, channelID string, messageID string) error
+	// Removes a watch along with its roster. Removing a watch that doesn't exist is not an error.
+	DeleteWatch(guildID string, meetingID string, channelID string) error
+	// Replaces a watch's roster, dropping exact duplicates. Returns ErrUnknownWatch if the watch doesn't exist.
+	SaveRoster(guildID string, meetingID string, channelID string, roster []string) error
+
+	// Records a finished meeting and its attendance. Times are kept to the second.
+	SaveMeetingHistory(history MeetingHistory) error
+	// Lists every attendance session for occurrences of a meeting that started within [from, to),
+	// ordered by meeting start and then join time
+	GetAttendance(meetingID string, from time.Time, to time.Time) ([]AttendanceRow, error)
+	// Whether any occurrence of a meeting was recorded while the guild was watching it
+	HasMeetingHistory(guildID string, meetingID string) (bool, error)
+	// Totals up how often a meeting happened within [from, to), how long it ran, and the topN people who attended
+	// the most. The topic is the meeting's most recent, even if it falls outside the range.
+	GetMeetingStats(meetingID string, from time.Time, to time.Time, topN int) (MeetingStats, error)
+
+	// Lists every guild's digest schedule, ordered by guild
+	GetAllDigestSettings() ([]DigestSettings, error)
+	// Creates or replaces a guild's digest schedule, keeping when it was last sent
+	SaveDigestSettings(settings DigestSettings) error
+	DeleteDigestSettings(guildID string) error
+	// Records when a guild's digest was last posted. Does nothing if the guild has no schedule.
+	MarkDigestSent(guildID string, sent time.Time) error
+
+	// Lists the settings of every guild that has changed any, ordered by guild
+	GetAllGuildSettings() ([]GuildSettings, error)
+	// Returns a guild's settings, or the defaults if it hasn't changed any
+	GetGuildSettings(guildID string) (GuildSettings, error)
+	// Creates or replaces a guild's settings
+	SaveGuildSettings(settings GuildSettings) error
+	// Returns a guild to the default settings
+	DeleteGuildSettings(guildID string) error
+
+	// Lists every role allowed to take an action in any guild, ordered by guild, then action, then role
+	GetAllCommandRoles() ([]CommandRole, error)
+	// Lists the roles allowed to take each action on a guild's watches, ordered by role. An action with no roles
+	// is open to everyone who can use the command.
+	GetCommandRoles(guildID string) (map[string][]string, error)
+	// Allows a role to take an action on a guild's watches. Allowing it twice is not an error.
+	AddCommandRole(guildID string, action string, roleID string) error
+	// Removes a role's permission to take an action. Removing one that isn't allowed is not an error.
+	RemoveCommandRole(guildID string, action string, roleID string) error
+
+	// Adds an event to the end of the given peer's outbound queue. Each event can only be queued once per peer.
+	PushReplicationEvent(target string, event ReplicationEvent) error
+	// Returns the oldest event in the given peer's outbound queue, if there is one
+	PeekReplicationEvent(target string) (ReplicationEvent, bool, error)
+	// Lists every event in the given peer's outbound queue, oldest first
+	GetReplicationEvents(target string) ([]ReplicationEvent, error)
+	// Removes an event from the given peer's outbound queue once it has been accepted
+	DeleteReplicationEvent(target string, id string) error
+}
+
+var (
+	_ Store = DatabasePool{}
+	_ Store = (*MemoryStore)(nil)
+)
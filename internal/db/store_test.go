@@ -0,0 +1,637 @@
+package db
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+// Both backends must behave the same, so every test runs against each of them
+var backends = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"sqlite", openDatabasePool},
+	{"memory", func(*testing.T) Store { return NewMemoryStore() }},
+}
+
+func openDatabasePool(t *testing.T) Store {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "meeting-mate.db")
+	if err := InitializeDatabase(path); err != nil {
+		t.Fatalf("InitializeDatabase: %s", err)
+	}
+	if err := MakeMigrations(path, func(err error) { t.Errorf("migration: %s", err) }); err != nil {
+		t.Fatalf("MakeMigrations: %s", err)
+	}
+	pool, err := NewDatabasePool(path)
+	if err != nil {
+		t.Fatalf("NewDatabasePool: %s", err)
+	}
+	t.Cleanup(func() {
+		if err := pool.pool.Close(); err != nil {
+			t.Errorf("closing pool: %s", err)
+		}
+	})
+	return pool
+}
+
+func forEachBackend(t *testing.T, test func(t *testing.T, store Store)) {
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			test(t, backend.open(t))
+		})
+	}
+}
+
+func testWatch(guildID string, meetingID string, channelID string) WatchData {
+	return WatchData{
+		MeetingID:    meetingID,
+		GuildID:      guildID,
+		ChannelID:    channelID,
+		MeetingTopic: "Standup " + meetingID,
+		Options: types.FeatureFlags{
+			Silent:         true,
+			JoinLink:       "https://zoom.us/j/" + meetingID,
+			SummaryLevel:   types.DETAILED_SUMMARY,
+			HistoryLevel:   types.MINIMAL_HISTORY,
+			TimelineChart:  true,
+			RestartCommand: "/watch meeting_id: " + meetingID,
+		},
+	}
+}
+
+func getWatches(t *testing.T, store Store) []WatchData {
+	t.Helper()
+
+	watches, err := store.GetAllWatches()
+	if err != nil {
+		t.Fatalf("GetAllWatches: %s", err)
+	}
+	return watches
+}
+
+func TestWatchRoundTrip(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		if watches := getWatches(t, store); len(watches) != 0 {
+			t.Fatalf("new store has watches: %+v", watches)
+		}
+
+		// Saved out of order to check the ordering of GetAllWatches
+		saved := []WatchData{
+			testWatch("g2", "m1", "c1"),
+			testWatch("g1", "m2", "c1"),
+			testWatch("g1", "m1", "c2"),
+			testWatch("g1", "m1", "c1"),
+		}
+		for _, watch := range saved {
+			if err := store.SaveWatch(watch); err != nil {
+				t.Fatalf("SaveWatch: %s", err)
+			}
+		}
+		if err := store.SaveRoster("g1", "m1", "c1", []string{"Ada", "grace@example.com"}); err != nil {
+			t.Fatalf("SaveRoster: %s", err)
+		}
+		if err := store.SaveStatusMessage("g1", "m1", "c1", "msg1"); err != nil {
+			t.Fatalf("SaveStatusMessage: %s", err)
+		}
+
+		// Saving again replaces the details but keeps the roster and status message
+		replaced := testWatch("g1", "m1", "c1")
+		replaced.MeetingTopic = "Renamed"
+		replaced.Options.Silent = false
+		if err := store.SaveWatch(replaced); err != nil {
+			t.Fatalf("SaveWatch: %s", err)
+		}
+		replaced.Roster = []string{"Ada", "grace@example.com"}
+		replaced.StatusMessageID = "msg1"
+
+		want := []WatchData{replaced, saved[2], saved[1], saved[0]}
+		if got := getWatches(t, store); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAllWatches:\n got %+v\nwant %+v", got, want)
+		}
+	})
+}
+
+func TestMissingWatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		if err := store.SaveWatch(testWatch("g1", "m1", "c1")); err != nil {
+			t.Fatalf("SaveWatch: %s", err)
+		}
+
+		// Each of these names a watch that differs from the saved one in a single part of its key
+		for _, key := range [][3]string{{"g2", "m1", "c1"}, {"g1", "m2", "c1"}, {"g1", "m1", "c2"}} {
+			guildID, meetingID, channelID := key[0], key[1], key[2]
+
+			err := store.SaveWatchOptions(guildID, meetingID, channelID, types.FeatureFlags{})
+			if !errors.Is(err, ErrUnknownWatch) {
+				t.Errorf("SaveWatchOptions%v: got %v, want ErrUnknownWatch", key, err)
+			}
+			err = store.SaveStatusMessage(guildID, meetingID, channelID, "msg1")
+			if !errors.Is(err, ErrUnknownWatch) {
+				t.Errorf("SaveStatusMessage%v: got %v, want ErrUnknownWatch", key, err)
+			}
+			err = store.SaveRoster(guildID, meetingID, channelID, []string{"Ada"})
+			if !errors.Is(err, ErrUnknownWatch) {
+				t.Errorf("SaveRoster%v: got %v, want ErrUnknownWatch", key, err)
+			}
+			if err = store.DeleteWatch(guildID, meetingID, channelID); err != nil {
+				t.Errorf("DeleteWatch%v: %s", key, err)
+			}
+		}
+
+		want := []WatchData{testWatch("g1", "m1", "c1")}
+		if got := getWatches(t, store); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAllWatches:\n got %+v\nwant %+v", got, want)
+		}
+	})
+}
+
+func TestSaveWatchOptions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		watch := testWatch("g1", "m1", "c1")
+		if err := store.SaveWatch(watch); err != nil {
+			t.Fatalf("SaveWatch: %s", err)
+		}
+		if err := store.SaveRoster("g1", "m1", "c1", []string{"Ada"}); err != nil {
+			t.Fatalf("SaveRoster: %s", err)
+		}
+
+		watch.Options = types.FeatureFlags{
+			SummaryLevel: types.NO_SUMMARY,
+			HistoryLevel: types.FULL_HISTORY,
+		}
+		if err := store.SaveWatchOptions("g1", "m1", "c1", watch.Options); err != nil {
+			t.Fatalf("SaveWatchOptions: %s", err)
+		}
+		watch.Roster = []string{"Ada"}
+
+		want := []WatchData{watch}
+		if got := getWatches(t, store); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAllWatches:\n got %+v\nwant %+v", got, want)
+		}
+	})
+}
+
+func TestStatusMessage(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		if err := store.SaveWatch(testWatch("g1", "m1", "c1")); err != nil {
+			t.Fatalf("SaveWatch: %s", err)
+		}
+
+		for _, messageID := range []string{"msg1", "msg2", ""} {
+			if err := store.SaveStatusMessage("g1", "m1", "c1", messageID); err != nil {
+				t.Fatalf("SaveStatusMessage(%q): %s", messageID, err)
+			}
+			if got := getWatches(t, store)[0].StatusMessageID; got != messageID {
+				t.Errorf("StatusMessageID = %q, want %q", got, messageID)
+			}
+		}
+	})
+}
+
+func TestDeleteWatch(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		for _, channelID := range []string{"c1", "c2"} {
+			if err := store.SaveWatch(testWatch("g1", "m1", channelID)); err != nil {
+				t.Fatalf("SaveWatch: %s", err)
+			}
+			if err := store.SaveRoster("g1", "m1", channelID, []string{"Ada"}); err != nil {
+				t.Fatalf("SaveRoster: %s", err)
+			}
+		}
+
+		if err := store.DeleteWatch("g1", "m1", "c1"); err != nil {
+			t.Fatalf("DeleteWatch: %s", err)
+		}
+		watches := getWatches(t, store)
+		if len(watches) != 1 || watches[0].ChannelID != "c2" {
+			t.Fatalf("after deleting c1, GetAllWatches = %+v", watches)
+		}
+
+		// The roster goes with the watch, so a watch saved in its place starts without one
+		if err := store.SaveWatch(testWatch("g1", "m1", "c1")); err != nil {
+			t.Fatalf("SaveWatch: %s", err)
+		}
+		for _, watch := range getWatches(t, store) {
+			if watch.ChannelID == "c1" && len(watch.Roster) != 0 {
+				t.Errorf("recreated watch kept its old roster: %v", watch.Roster)
+			}
+			if watch.ChannelID == "c2" && !reflect.DeepEqual(watch.Roster, []string{"Ada"}) {
+				t.Errorf("other channel's roster = %v, want [Ada]", watch.Roster)
+			}
+		}
+	})
+}
+
+func TestSaveRoster(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		if err := store.SaveWatch(testWatch("g1", "m1", "c1")); err != nil {
+			t.Fatalf("SaveWatch: %s", err)
+		}
+
+		tests := []struct {
+			roster []string
+			want   []string
+		}{
+			{[]string{"Ada", "grace@example.com", "Ada", "ada"}, []string{"Ada", "grace@example.com", "ada"}},
+			{[]string{"Linus"}, []string{"Linus"}},
+			{nil, nil},
+		}
+		for _, test := range tests {
+			if err := store.SaveRoster("g1", "m1", "c1", test.roster); err != nil {
+				t.Fatalf("SaveRoster(%v): %s", test.roster, err)
+			}
+			if got := getWatches(t, store)[0].Roster; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("SaveRoster(%v): roster = %v, want %v", test.roster, got, test.want)
+			}
+		}
+	})
+}
+
+func TestMeetingHistory(t *testing.T) {
+	day := time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return day.Add(time.Duration(minutes) * time.Minute) }
+
+	forEachBackend(t, func(t *testing.T, store Store) {
+		histories := []MeetingHistory{
+			{
+				MeetingID:    "m1",
+				MeetingTopic: "Standup",
+				GuildIDs:     []string{"g1", "g2"},
+				// Sub-second precision is dropped by both backends
+				StartTime: at(0).Add(300 * time.Millisecond),
+				EndTime:   at(30),
+				Attendance: []types.AttendanceRecord{
+					{ID: "p2", Name: "Grace", Email: "grace@example.com", Sessions: []types.Session{
+						{Join: at(5), Leave: at(30)},
+					}},
+					{ID: "p1", Name: "Ada", Sessions: []types.Session{
+						{Join: at(0), Leave: at(10)},
+						{Join: at(20), Leave: at(30)},
+					}},
+				},
+			},
+			{
+				MeetingID:    "m1",
+				MeetingTopic: "Renamed standup",
+				StartTime:    at(7 * 24 * 60),
+				EndTime:      at(7*24*60 + 60),
+				Attendance: []types.AttendanceRecord{
+					{ID: "p1", Name: "Ada", Sessions: []types.Session{{Join: at(7 * 24 * 60), Leave: at(7*24*60 + 60)}}},
+				},
+			},
+			{
+				MeetingID:    "m2",
+				MeetingTopic: "Other meeting",
+				GuildIDs:     []string{"g3"},
+				StartTime:    at(0),
+				EndTime:      at(90),
+				Attendance: []types.AttendanceRecord{
+					{ID: "p3", Name: "Linus", Sessions: []types.Session{{Join: at(0), Leave: at(90)}}},
+				},
+			},
+		}
+		for _, history := range histories {
+			if err := store.SaveMeetingHistory(history); err != nil {
+				t.Fatalf("SaveMeetingHistory: %s", err)
+			}
+		}
+
+		// Only the first week falls in range
+		rows, err := store.GetAttendance("m1", day, at(24*60))
+		if err != nil {
+			t.Fatalf("GetAttendance: %s", err)
+		}
+		row := func(id string, name string, email string, join int, leave int) AttendanceRow {
+			return AttendanceRow{
+				MeetingID:        "m1",
+				MeetingTopic:     "Standup",
+				MeetingStart:     at(0),
+				ParticipantID:    id,
+				ParticipantName:  name,
+				ParticipantEmail: email,
+				JoinTime:         at(join),
+				LeaveTime:        at(leave),
+			}
+		}
+		wantRows := []AttendanceRow{
+			row("p1", "Ada", "", 0, 10),
+			row("p2", "Grace", "grace@example.com", 5, 30),
+			row("p1", "Ada", "", 20, 30),
+		}
+		if !reflect.DeepEqual(rows, wantRows) {
+			t.Errorf("GetAttendance:\n got %+v\nwant %+v", rows, wantRows)
+		}
+
+		rows, err = store.GetAttendance("m1", at(-60), day)
+		if err != nil {
+			t.Fatalf("GetAttendance: %s", err)
+		}
+		if len(rows) != 0 {
+			t.Errorf("GetAttendance before any meeting = %+v, want none", rows)
+		}
+
+		stats, err := store.GetMeetingStats("m1", day, at(24*60), 1)
+		if err != nil {
+			t.Fatalf("GetMeetingStats: %s", err)
+		}
+		wantStats := MeetingStats{
+			MeetingID:    "m1",
+			MeetingTopic: "Renamed standup", // The latest topic, even though it's out of range
+			Occurrences:  1,
+			TotalTime:    30 * time.Minute,
+			TopAttendees: []AttendeeTotal{{Name: "Grace", TotalTime: 25 * time.Minute}},
+		}
+		if !reflect.DeepEqual(stats, wantStats) {
+			t.Errorf("GetMeetingStats:\n got %+v\nwant %+v", stats, wantStats)
+		}
+
+		stats, err = store.GetMeetingStats("m1", day, at(14*24*60), 5)
+		if err != nil {
+			t.Fatalf("GetMeetingStats: %s", err)
+		}
+		wantStats.Occurrences = 2
+		wantStats.TotalTime = 90 * time.Minute
+		wantStats.TopAttendees = []AttendeeTotal{
+			{Name: "Ada", TotalTime: 80 * time.Minute},
+			{Name: "Grace", TotalTime: 25 * time.Minute},
+		}
+		if !reflect.DeepEqual(stats, wantStats) {
+			t.Errorf("GetMeetingStats:\n got %+v\nwant %+v", stats, wantStats)
+		}
+
+		// Only servers watching an occurrence when it was recorded have history for the meeting
+		tagged := []struct {
+			guildID   string
+			meetingID string
+			want      bool
+		}{
+			{"g1", "m1", true},
+			{"g2", "m1", true},
+			{"g3", "m1", false},
+			{"g3", "m2", true},
+			{"g1", "m2", false},
+		}
+		for _, test := range tagged {
+			got, err := store.HasMeetingHistory(test.guildID, test.meetingID)
+			if err != nil {
+				t.Fatalf("HasMeetingHistory: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("HasMeetingHistory(%s, %s) = %t, want %t", test.guildID, test.meetingID, got, test.want)
+			}
+		}
+	})
+}
+
+func TestDigestSettings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		g2 := DigestSettings{GuildID: "g2", ChannelID: "c2", Weekday: time.Friday, Hour: 17}
+		g1 := DigestSettings{GuildID: "g1", ChannelID: "c1", Weekday: time.Monday, Hour: 9}
+		for _, settings := range []DigestSettings{g2, g1} {
+			if err := store.SaveDigestSettings(settings); err != nil {
+				t.Fatalf("SaveDigestSettings: %s", err)
+			}
+		}
+
+		sent := time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC)
+		if err := store.MarkDigestSent("g1", sent); err != nil {
+			t.Fatalf("MarkDigestSent: %s", err)
+		}
+		// Marking a guild without a schedule does nothing
+		if err := store.MarkDigestSent("g3", sent); err != nil {
+			t.Fatalf("MarkDigestSent: %s", err)
+		}
+
+		// Rescheduling keeps when the digest was last sent
+		g1.Hour = 10
+		if err := store.SaveDigestSettings(g1); err != nil {
+			t.Fatalf("SaveDigestSettings: %s", err)
+		}
+		g1.LastSent = sent
+
+		got, err := store.GetAllDigestSettings()
+		if err != nil {
+			t.Fatalf("GetAllDigestSettings: %s", err)
+		}
+		if want := []DigestSettings{g1, g2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("GetAllDigestSettings:\n got %+v\nwant %+v", got, want)
+		}
+
+		if err = store.DeleteDigestSettings("g1"); err != nil {
+			t.Fatalf("DeleteDigestSettings: %s", err)
+		}
+		got, err = store.GetAllDigestSettings()
+		if err != nil {
+			t.Fatalf("GetAllDigestSettings: %s", err)
+		}
+		if want := []DigestSettings{g2}; !reflect.DeepEqual(got, want) {
+			t.Errorf("after deleting g1, GetAllDigestSettings:\n got %+v\nwant %+v", got, want)
+		}
+	})
+}
+
+func TestReplicationQueue(t *testing.T) {
+	received := time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+	seq := map[string]int64{"e1": 1, "e2": 2}
+	event := func(id string) ReplicationEvent {
+		return ReplicationEvent{
+			ID: id, Kind: "zoom", Origin: "n1", ReceivedAt: received, Epoch: 42, Seq: seq[id], Payload: []byte(id),
+		}
+	}
+
+	forEachBackend(t, func(t *testing.T, store Store) {
+		if _, ok, err := store.PeekReplicationEvent("n2"); err != nil || ok {
+			t.Fatalf("PeekReplicationEvent on an empty queue = %t, %v", ok, err)
+		}
+
+		for _, id := range []string{"e1", "e2"} {
+			if err := store.PushReplicationEvent("n2", event(id)); err != nil {
+				t.Fatalf("PushReplicationEvent: %s", err)
+			}
+		}
+		if err := store.PushReplicationEvent("n3", event("e1")); err != nil {
+			t.Fatalf("PushReplicationEvent to another peer: %s", err)
+		}
+		if err := store.PushReplicationEvent("n2", event("e1")); !errors.Is(err, ErrDuplicateEvent) {
+			t.Errorf("pushing e1 twice: got %v, want ErrDuplicateEvent", err)
+		}
+
+		queued, err := store.GetReplicationEvents("n2")
+		if err != nil {
+			t.Fatalf("GetReplicationEvents: %s", err)
+		}
+		if want := []ReplicationEvent{event("e1"), event("e2")}; !reflect.DeepEqual(queued, want) {
+			t.Errorf("GetReplicationEvents:\n got %+v\nwant %+v", queued, want)
+		}
+
+		for _, id := range []string{"e1", "e2"} {
+			got, ok, err := store.PeekReplicationEvent("n2")
+			if err != nil || !ok {
+				t.Fatalf("PeekReplicationEvent = %t, %v", ok, err)
+			}
+			if want := event(id); !reflect.DeepEqual(got, want) {
+				t.Errorf("PeekReplicationEvent:\n got %+v\nwant %+v", got, want)
+			}
+			if err = store.DeleteReplicationEvent("n2", id); err != nil {
+				t.Fatalf("DeleteReplicationEvent: %s", err)
+			}
+		}
+		if _, ok, err := store.PeekReplicationEvent("n2"); err != nil || ok {
+			t.Errorf("PeekReplicationEvent after emptying the queue = %t, %v", ok, err)
+		}
+		if queued, err = store.GetReplicationEvents("n2"); err != nil || len(queued) != 0 {
+			t.Errorf("GetReplicationEvents after emptying the queue = %+v, %v", queued, err)
+		}
+		// Each peer's queue is separate
+		if got, ok, err := store.PeekReplicationEvent("n3"); err != nil || !ok || got.ID != "e1" {
+			t.Errorf("PeekReplicationEvent for n3 = %+v, %t, %v", got, ok, err)
+		}
+	})
+}
+
+func TestGuildSettings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		got, err := store.GetGuildSettings("g1")
+		if err != nil {
+			t.Fatalf("GetGuildSettings: %s", err)
+		}
+		if want := DefaultGuildSettings("g1"); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetGuildSettings before saving:\n got %+v\nwant %+v", got, want)
+		}
+
+		settings := GuildSettings{
+			GuildID: "g1",
+			Defaults: types.FeatureFlags{
+				Silent:        false,
+				SummaryLevel:  types.FULL_SUMMARY,
+				HistoryLevel:  types.MINIMAL_HISTORY,
+				TimelineChart: true,
+				// Not a guild default, so it isn't kept
+				JoinLink: "https://zoom.us/j/m1",
+			},
+			ChannelID:    "c1",
+			Timezone:     "America/Chicago",
+			Locale:       "en-US",
+			MentionRoles: []string{"r1", "r2"},
+		}
+		if err = store.SaveGuildSettings(settings); err != nil {
+			t.Fatalf("SaveGuildSettings: %s", err)
+		}
+		settings.Defaults.JoinLink = ""
+
+		got, err = store.GetGuildSettings("g1")
+		if err != nil {
+			t.Fatalf("GetGuildSettings: %s", err)
+		}
+		if !reflect.DeepEqual(got, settings) {
+			t.Errorf("GetGuildSettings:\n got %+v\nwant %+v", got, settings)
+		}
+		got, err = store.GetGuildSettings("g2")
+		if err != nil {
+			t.Fatalf("GetGuildSettings: %s", err)
+		}
+		if want := DefaultGuildSettings("g2"); !reflect.DeepEqual(got, want) {
+			t.Errorf("other guild's settings:\n got %+v\nwant %+v", got, want)
+		}
+
+		// Only guilds that saved settings are listed
+		all, err := store.GetAllGuildSettings()
+		if err != nil {
+			t.Fatalf("GetAllGuildSettings: %s", err)
+		}
+		if want := []GuildSettings{settings}; !reflect.DeepEqual(all, want) {
+			t.Errorf("GetAllGuildSettings:\n got %+v\nwant %+v", all, want)
+		}
+
+		if err = store.DeleteGuildSettings("g1"); err != nil {
+			t.Fatalf("DeleteGuildSettings: %s", err)
+		}
+		got, err = store.GetGuildSettings("g1")
+		if err != nil {
+			t.Fatalf("GetGuildSettings: %s", err)
+		}
+		if want := DefaultGuildSettings("g1"); !reflect.DeepEqual(got, want) {
+			t.Errorf("GetGuildSettings after deleting:\n got %+v\nwant %+v", got, want)
+		}
+		if all, err = store.GetAllGuildSettings(); err != nil || len(all) != 0 {
+			t.Errorf("GetAllGuildSettings after deleting = %+v, %v; want none", all, err)
+		}
+	})
+}
+
+func TestCommandRoles(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, store Store) {
+		adds := [][3]string{
+			{"g1", types.CREATE_ACTION, "r2"},
+			{"g1", types.CREATE_ACTION, "r1"},
+			{"g1", types.CREATE_ACTION, "r2"}, // Allowing a role twice is not an error
+			{"g1", types.CANCEL_ACTION, "r3"},
+			{"g2", types.CREATE_ACTION, "r4"},
+		}
+		for _, add := range adds {
+			if err := store.AddCommandRole(add[0], add[1], add[2]); err != nil {
+				t.Fatalf("AddCommandRole%v: %s", add, err)
+			}
+		}
+
+		roles, err := store.GetCommandRoles("g1")
+		if err != nil {
+			t.Fatalf("GetCommandRoles: %s", err)
+		}
+		want := map[string][]string{
+			types.CREATE_ACTION: {"r1", "r2"},
+			types.CANCEL_ACTION: {"r3"},
+		}
+		if !reflect.DeepEqual(roles, want) {
+			t.Errorf("GetCommandRoles:\n got %v\nwant %v", roles, want)
+		}
+
+		removes := [][3]string{
+			{"g1", types.CREATE_ACTION, "r2"},
+			{"g1", types.CANCEL_ACTION, "r3"},
+			{"g1", types.MODIFY_ACTION, "r1"}, // Removing a role that isn't allowed is not an error
+		}
+		for _, remove := range removes {
+			if err = store.RemoveCommandRole(remove[0], remove[1], remove[2]); err != nil {
+				t.Fatalf("RemoveCommandRole%v: %s", remove, err)
+			}
+		}
+
+		roles, err = store.GetCommandRoles("g1")
+		if err != nil {
+			t.Fatalf("GetCommandRoles: %s", err)
+		}
+		// Actions left with no roles are open to everyone, so they aren't listed
+		want = map[string][]string{types.CREATE_ACTION: {"r1"}}
+		if !reflect.DeepEqual(roles, want) {
+			t.Errorf("GetCommandRoles after removing:\n got %v\nwant %v", roles, want)
+		}
+
+		all, err := store.GetAllCommandRoles()
+		if err != nil {
+			t.Fatalf("GetAllCommandRoles: %s", err)
+		}
+		wantAll := []CommandRole{
+			{GuildID: "g1", Action: types.CREATE_ACTION, RoleID: "r1"},
+			{GuildID: "g2", Action: types.CREATE_ACTION, RoleID: "r4"},
+		}
+		if !reflect.DeepEqual(all, wantAll) {
+			t.Errorf("GetAllCommandRoles:\n got %+v\nwant %+v", all, wantAll)
+		}
+
+		roles, err = store.GetCommandRoles("g3")
+		if err != nil {
+			t.Fatalf("GetCommandRoles: %s", err)
+		}
+		if len(roles) != 0 {
+			t.Errorf("GetCommandRoles for a guild with none = %v, want none", roles)
+		}
+	})
+}
@@ -1,8 +1,7 @@
 package db
 
 import (
-	"context"
-	"log"
+	"fmt"
 
 	"github.com/angelajfisher/meeting-mate/internal/types"
 	"zombiezen.com/go/sqlite"
@@ -15,22 +14,19 @@ type WatchData struct {
 	ChannelID    string
 	MeetingTopic string
 	Options      types.FeatureFlags
-}
+	Roster       []string // Names or emails of the people expected to attend
 
-func (db DatabasePool) GetAllWatches() []WatchData {
-	if !db.Enabled {
-		return []WatchData{}
-	}
+	StatusMessageID string // The message in ChannelID kept up to date with the meeting's status, if there is one
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), connTimeout)
-	defer cancel()
-	conn, err := db.pool.Take(ctx)
+func (db DatabasePool) GetAllWatches() ([]WatchData, error) {
+	conn, release, err := db.take()
 	if err != nil {
-		log.Println("error: could not get new connection from database: %w", err)
+		return nil, err
 	}
-	defer db.pool.Put(conn)
+	defer release()
 
-	var watches []WatchData
+	watches := []WatchData{}
 	err = sqlitex.Execute(conn, `
 		SELECT
 			meeting_id,
@@ -38,11 +34,14 @@ func (db DatabasePool) GetAllWatches() []WatchData {
 			channel_id,
 			meeting_topic,
 			silent,
-			summary,
+			summary_type,
 			history_type,
 			command,
-			link
-		FROM watches;`,
+			link,
+			timeline,
+			status_message_id
+		FROM watches
+		ORDER BY server_id, meeting_id, channel_id;`,
 		&sqlitex.ExecOptions{
 			ResultFunc: func(stmt *sqlite.Stmt) error {
 				watchData := WatchData{
@@ -52,35 +51,54 @@ func (db DatabasePool) GetAllWatches() []WatchData {
 					MeetingTopic: stmt.ColumnText(3),
 					Options: types.FeatureFlags{
 						Silent:         stmt.ColumnBool(4),
-						Summaries:      stmt.ColumnBool(5),
+						SummaryLevel:   stmt.ColumnText(5),
 						HistoryLevel:   stmt.ColumnText(6),
 						RestartCommand: stmt.ColumnText(7),
 						JoinLink:       stmt.ColumnText(8),
+						TimelineChart:  stmt.ColumnBool(9),
 					},
+					StatusMessageID: stmt.ColumnText(10),
 				}
 				watches = append(watches, watchData)
 				return nil
 			},
 		})
 	if err != nil {
-		log.Println("error: could not get all watches from database: %w", err)
+		return nil, fmt.Errorf("could not get all watches from database: %w", err)
 	}
 
-	return watches
-}
-
-func (db DatabasePool) SaveWatch(watch WatchData) {
-	if !db.Enabled {
-		return
+	rosters := make(map[[3]string][]string) // map[{meetingID, guildID, channelID}]entries
+	err = sqlitex.Execute(conn, `
+		SELECT
+			meeting_id,
+			server_id,
+			channel_id,
+			entry
+		FROM rosters
+		ORDER BY rowid;`,
+		&sqlitex.ExecOptions{
+			ResultFunc: func(stmt *sqlite.Stmt) error {
+				key := [3]string{stmt.ColumnText(0), stmt.ColumnText(1), stmt.ColumnText(2)}
+				rosters[key] = append(rosters[key], stmt.ColumnText(3))
+				return nil
+			},
+		})
+	if err != nil {
+		return nil, fmt.Errorf("could not get rosters from database: %w", err)
+	}
+	for i, watch := range watches {
+		watches[i].Roster = rosters[[3]string{watch.MeetingID, watch.GuildID, watch.ChannelID}]
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), connTimeout)
-	defer cancel()
-	conn, err := db.pool.Take(ctx)
+	return watches, nil
+}
+
+func (db DatabasePool) SaveWatch(watch WatchData) error {
+	conn, release, err := db.take()
 	if err != nil {
-		log.Println("error: could not get new connection from database: %w", err)
+		return err
 	}
-	defer db.pool.Put(conn)
+	defer release()
 
 	err = sqlitex.Execute(conn, `
 		INSERT INTO watches (
@@ -89,13 +107,22 @@ func (db DatabasePool) SaveWatch(watch WatchData) {
 			channel_id,
 			meeting_topic,
 			silent,
-			summary,
+			summary_type,
 			history_type,
 			command,
-			link
+			link,
+			timeline
 		) VALUES (
-			?, ?, ?, ?, ?, ?, ?, ?, ?
-		);`,
+			?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		)
+		ON CONFLICT (meeting_id, server_id, channel_id) DO UPDATE SET
+			meeting_topic = excluded.meeting_topic,
+			silent = excluded.silent,
+			summary_type = excluded.summary_type,
+			history_type = excluded.history_type,
+			command = excluded.command,
+			link = excluded.link,
+			timeline = excluded.timeline;`,
 		&sqlitex.ExecOptions{
 			Args: []any{
 				watch.MeetingID,
@@ -103,38 +130,192 @@ func (db DatabasePool) SaveWatch(watch WatchData) {
 				watch.ChannelID,
 				watch.MeetingTopic,
 				watch.Options.Silent,
-				watch.Options.Summaries,
+				watch.Options.SummaryLevel,
 				watch.Options.HistoryLevel,
 				watch.Options.RestartCommand,
 				watch.Options.JoinLink,
+				watch.Options.TimelineChart,
 			},
 		})
 	if err != nil {
-		log.Println("error: could not save watch to database: %w", err)
+		return fmt.Errorf("could not save watch to database: %w", err)
 	}
+	return nil
 }
 
-func (db DatabasePool) DeleteWatch(guildID string, meetingID string) {
-	if !db.Enabled {
-		return
+func (db DatabasePool) SaveWatchOptions(
+	guildID string,
+	meetingID string,
+	channelID string,
+	options types.FeatureFlags,
+) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
 	}
+	defer release()
 
-	ctx, cancel := context.WithTimeout(context.Background(), connTimeout)
-	defer cancel()
-	conn, err := db.pool.Take(ctx)
+	err = func() (err error) {
+		defer sqlitex.Save(conn)(&err)
+
+		err = sqlitex.Execute(conn, `
+			UPDATE watches
+			SET
+				silent = ?,
+				summary_type = ?,
+				history_type = ?,
+				command = ?,
+				link = ?,
+				timeline = ?
+			WHERE meeting_id = ?
+				AND server_id = ?
+				AND channel_id = ?;`,
+			&sqlitex.ExecOptions{
+				Args: []any{
+					options.Silent,
+					options.SummaryLevel,
+					options.HistoryLevel,
+					options.RestartCommand,
+					options.JoinLink,
+					options.TimelineChart,
+					meetingID,
+					guildID,
+					channelID,
+				},
+			})
+		if err != nil {
+			return err
+		}
+		if conn.Changes() == 0 {
+			return ErrUnknownWatch
+		}
+		return nil
+	}()
 	if err != nil {
-		log.Println("error: could not get new connection from database: %w", err)
+		return fmt.Errorf("could not save watch options to database: %w", err)
 	}
-	defer db.pool.Put(conn)
+	return nil
+}
+
+func (db DatabasePool) SaveStatusMessage(guildID string, meetingID string, channelID string, messageID string) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = func() (err error) {
+		defer sqlitex.Save(conn)(&err)
+
+		err = sqlitex.Execute(conn, `
+			UPDATE watches
+			SET status_message_id = ?
+			WHERE meeting_id = ?
+				AND server_id = ?
+				AND channel_id = ?;`,
+			&sqlitex.ExecOptions{
+				Args: []any{messageID, meetingID, guildID, channelID},
+			})
+		if err != nil {
+			return err
+		}
+		if conn.Changes() == 0 {
+			return ErrUnknownWatch
+		}
+		return nil
+	}()
+	if err != nil {
+		return fmt.Errorf("could not save status message to database: %w", err)
+	}
+	return nil
+}
+
+func (db DatabasePool) DeleteWatch(guildID string, meetingID string, channelID string) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
 
 	err = sqlitex.Execute(conn, `
 		DELETE FROM watches
 		WHERE meeting_id = ?
-			AND server_id = ?;`,
+			AND server_id = ?
+			AND channel_id = ?;`,
 		&sqlitex.ExecOptions{
-			Args: []any{meetingID, guildID},
+			Args: []any{meetingID, guildID, channelID},
 		})
 	if err != nil {
-		log.Println("error: could not delete watch from database: %w", err)
+		return fmt.Errorf("could not delete watch from database: %w", err)
+	}
+	return nil
+}
+
+// Replaces the saved roster for a watch
+func (db DatabasePool) SaveRoster(guildID string, meetingID string, channelID string, roster []string) error {
+	conn, release, err := db.take()
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = func() (err error) {
+		defer sqlitex.Save(conn)(&err)
+
+		exists := false
+		err = sqlitex.Execute(conn, `
+			SELECT 1
+			FROM watches
+			WHERE meeting_id = ?
+				AND server_id = ?
+				AND channel_id = ?;`,
+			&sqlitex.ExecOptions{
+				Args: []any{meetingID, guildID, channelID},
+				ResultFunc: func(*sqlite.Stmt) error {
+					exists = true
+					return nil
+				},
+			})
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUnknownWatch
+		}
+
+		err = sqlitex.Execute(conn, `
+			DELETE FROM rosters
+			WHERE meeting_id = ?
+				AND server_id = ?
+				AND channel_id = ?;`,
+			&sqlitex.ExecOptions{
+				Args: []any{meetingID, guildID, channelID},
+			})
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range roster {
+			err = sqlitex.Execute(conn, `
+				INSERT OR IGNORE INTO rosters (
+					meeting_id,
+					server_id,
+					channel_id,
+					entry
+				) VALUES (
+					?, ?, ?, ?
+				);`,
+				&sqlitex.ExecOptions{
+					Args: []any{meetingID, guildID, channelID, entry},
+				})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return fmt.Errorf("could not save roster to database: %w", err)
 	}
+	return nil
 }
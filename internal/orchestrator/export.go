@@ -0,0 +1,155 @@
+package orchestrator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+// A single row of an attendance export
+type attendanceEntry struct {
+	MeetingID    string  `json:"meeting_id"`
+	MeetingTopic string  `json:"meeting_topic"`
+	MeetingStart string  `json:"meeting_start"`
+	Name         string  `json:"name"`
+	Email        string  `json:"email,omitempty"`
+	Join         string  `json:"join"`
+	Leave        string  `json:"leave"`
+	TotalMinutes float64 `json:"total_minutes"`
+}
+
+// How far back exports reach when no start date is given
+const defaultExportDays = 30
+
+// Converts inclusive YYYY-MM-DD dates into the [from, to) range used by ExportAttendance.
+// A missing end date means today, and a missing start date means defaultExportDays before the end.
+func ParseExportRange(fromDate string, toDate string) (time.Time, time.Time, error) {
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if toDate != "" {
+		parsed, err := time.Parse(time.DateOnly, toDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end date %q: expected YYYY-MM-DD", toDate)
+		}
+		to = parsed
+	}
+	to = to.AddDate(0, 0, 1)
+
+	from := to.AddDate(0, 0, -defaultExportDays)
+	if fromDate != "" {
+		parsed, err := time.Parse(time.DateOnly, fromDate)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start date %q: expected YYYY-MM-DD", fromDate)
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("start date must not be after end date")
+	}
+
+	return from, to, nil
+}
+
+// Whether a guild may export a meeting's attendance. Only meetings it's watching, or has watched an occurrence of,
+// can be exported so attendance isn't exposed to other servers.
+func (o *Orchestrator) CanExport(guildID string, meetingID string) (bool, error) {
+	if len(o.GetWatchChannels(guildID, meetingID)) > 0 {
+		return true, nil
+	}
+	return o.Database.HasMeetingHistory(guildID, meetingID)
+}
+
+// Builds an attendance file in the given format for occurrences of a meeting that started within [from, to).
+// Returns the file contents along with a suggested file name.
+func (o *Orchestrator) ExportAttendance(
+	meetingID string,
+	from time.Time,
+	to time.Time,
+	format string,
+) ([]byte, string, error) {
+	rows, err := o.Database.GetAttendance(meetingID, from, to)
+	if err != nil {
+		return nil, "", err
+	}
+	entries := make([]attendanceEntry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, attendanceEntry{
+			MeetingID:    row.MeetingID,
+			MeetingTopic: row.MeetingTopic,
+			MeetingStart: row.MeetingStart.Format(time.RFC3339),
+			Name:         row.ParticipantName,
+			Email:        row.ParticipantEmail,
+			Join:         row.JoinTime.Format(time.RFC3339),
+			Leave:        row.LeaveTime.Format(time.RFC3339),
+			TotalMinutes: roundMinutes(row.LeaveTime.Sub(row.JoinTime)),
+		})
+	}
+
+	fileName := fmt.Sprintf(
+		"attendance_%s_%s_%s.%s",
+		meetingID,
+		from.Format(time.DateOnly),
+		to.AddDate(0, 0, -1).Format(time.DateOnly),
+		format,
+	)
+
+	var file []byte
+	switch format {
+	case types.EXPORT_CSV:
+		file, err = attendanceCSV(entries)
+	case types.EXPORT_JSON:
+		file, err = json.MarshalIndent(entries, "", "  ")
+	default:
+		err = fmt.Errorf("unsupported export format %q", format)
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("could not export attendance: %w", err)
+	}
+
+	return file, fileName, nil
+}
+
+func attendanceCSV(entries []attendanceEntry) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := csv.NewWriter(buf)
+
+	err := w.Write([]string{
+		"meeting_id", "meeting_topic", "meeting_start", "name", "email", "join", "leave", "total_minutes",
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		err = w.Write([]string{
+			entry.MeetingID,
+			entry.MeetingTopic,
+			entry.MeetingStart,
+			entry.Name,
+			entry.Email,
+			entry.Join,
+			entry.Leave,
+			strconv.FormatFloat(entry.TotalMinutes, 'f', -1, 64),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Converts a duration to minutes, rounded to two decimal places
+func roundMinutes(d time.Duration) float64 {
+	if d < 0 {
+		return 0
+	}
+	return math.Round(d.Minutes()*100) / 100
+}
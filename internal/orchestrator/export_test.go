@@ -0,0 +1,44 @@
+package orchestrator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+// A server can export a meeting it's watching, and keeps access to the history recorded while it was
+func TestCanExport(t *testing.T) {
+	o := newTestOrchestrator(t, db.NewMemoryStore())
+	expect := func(when string, want map[string]bool) {
+		t.Helper()
+		for guildID, allowed := range want {
+			got, err := o.CanExport(guildID, "m1")
+			if err != nil {
+				t.Fatalf("CanExport: %s", err)
+			}
+			if got != allowed {
+				t.Errorf("%s, CanExport(%s, m1) = %t, want %t", when, guildID, got, allowed)
+			}
+		}
+	}
+
+	expect("before any watch", map[string]bool{"g1": false, "g2": false})
+
+	if _, started := o.StartWatch("g1", "m1", "c1", "Standup"); !started {
+		t.Fatal("StartWatch reported the watch was already running")
+	}
+	expect("while g1 watches", map[string]bool{"g1": true, "g2": false})
+
+	o.UpdateMeeting("m1", types.MeetingData{
+		EventType:   types.ZOOM_MEETING_END,
+		MeetingName: "Standup",
+		Timestamp:   time.Now(),
+		Silent:      true,
+	})
+	if err := o.CancelWatch("g1", "m1", "c1"); err != nil {
+		t.Fatalf("CancelWatch: %s", err)
+	}
+	expect("after g1 stops watching", map[string]bool{"g1": true, "g2": false})
+}
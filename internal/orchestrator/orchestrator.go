@@ -1,63 +1,135 @@
 package orchestrator
 
 import (
-	"context"
 	"log"
-	"net/http"
+	"slices"
 	"time"
 
+	"github.com/angelajfisher/meeting-mate/internal/alert"
+	"github.com/angelajfisher/meeting-mate/internal/bus"
+	"github.com/angelajfisher/meeting-mate/internal/cluster"
 	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/replication"
 	"github.com/angelajfisher/meeting-mate/internal/types"
 )
 
+// How long shutdown waits for every watch to hear about it
+const shutdownGrace = 5 * time.Second
+
 type Orchestrator struct {
-	SisterAddress  string // Address of the other half of the HA pair
-	Database       db.DatabasePool
+	Cluster        *cluster.Cluster          // Decides whether this node posts to Discord or follows silently
+	Replicators    []*replication.Replicator // Forward changes to each peer in the cluster
+	Database       db.Store
+	Alerts         *alert.Alerter
 	ShutdownNotif  chan struct{} // Notifier for the health check endpoint
 	meetingWatches *types.Bimap  // Bidirectional map tracking ongoing watches categorized by meetingID and by guildID
-	dataListeners  *types.DataListeners
+	updates        *bus.Bus
 	allMeetings    *types.MeetingStore
+	rosters        *types.Rosters            // Expected attendees for each watch
+	watches        *watchRegistry            // Details of every watch, kept in sync across the cluster
+	versions       *replication.VersionClock // Orders the changes this node replicates and tracks those it applies
 }
 
-// Creates a new orchestrator to manage data across the program.
-func NewOrchestrator(sisterAddress string, dbPool db.DatabasePool) Orchestrator {
-	return Orchestrator{
+// Creates a new orchestrator to manage data across the program, starting with any watches saved in the database.
+func NewOrchestrator(
+	c *cluster.Cluster,
+	replicators []*replication.Replicator,
+	store db.Store,
+	alerts *alert.Alerter,
+) *Orchestrator {
+	o := &Orchestrator{
 		meetingWatches: types.NewBimap(),
-		dataListeners:  types.NewDataListeners(),
+		updates:        bus.New(),
 		allMeetings:    types.NewMeetingStore(),
+		rosters:        types.NewRosters(),
+		watches:        newWatchRegistry(),
+		versions:       replication.NewVersionClock(c.NodeID),
 		ShutdownNotif:  make(chan struct{}, 1),
-		Database:       dbPool,
-		SisterAddress:  sisterAddress,
+		Database:       store,
+		Alerts:         alerts,
+		Cluster:        c,
+		Replicators:    replicators,
+	}
+
+	saved, err := store.GetAllWatches()
+	if err != nil {
+		log.Printf("could not load saved watches: %s", err)
+	}
+	for _, watch := range saved {
+		o.watches.put(watch)
+		o.rosters.Set(watch.GuildID, watch.MeetingID, watch.ChannelID, watch.Roster)
 	}
+
+	return o
+}
+
+// Whether this node is the one talking to Discord. Followers apply every update silently.
+func (o *Orchestrator) IsLeader() bool {
+	return o.Cluster.IsLeader()
 }
 
 // Whether the given meeting is being monitored by the system
-func (o Orchestrator) IsWatchedMeeting(meetingID string) bool {
+func (o *Orchestrator) IsWatchedMeeting(meetingID string) bool {
 	return o.meetingWatches.ActiveMeeting(meetingID)
 }
 
-// Whether the given meeting has an ongoing watch in the given guild
-func (o Orchestrator) IsOngoingWatch(guildID string, meetingID string) bool {
-	return o.meetingWatches.Exists(guildID, meetingID)
+// Whether the given meeting has an ongoing watch in the given channel of a guild
+func (o *Orchestrator) IsOngoingWatch(guildID string, meetingID string, channelID string) bool {
+	return o.meetingWatches.Exists(guildID, meetingID, channelID)
 }
 
 // Lists all meetings being watched by a given guild
-func (o Orchestrator) GetGuildMeetings(guildID string) []string {
+func (o *Orchestrator) GetGuildMeetings(guildID string) []string {
 	return o.meetingWatches.GetMeetings(guildID)
 }
 
+// Lists the channels a guild is watching a meeting from, in a stable order
+func (o *Orchestrator) GetWatchChannels(guildID string, meetingID string) []string {
+	channels := o.meetingWatches.GetChannels(guildID, meetingID)
+	slices.Sort(channels)
+	return channels
+}
+
 // Returns the "topic" of a given Zoom meeting if the data is available
-func (o Orchestrator) GetMeetingName(meetingID string) string {
+func (o *Orchestrator) GetMeetingName(meetingID string) string {
 	return o.allMeetings.GetName(meetingID)
 }
 
-func (o Orchestrator) StartWatch(guildID string, meetingID string, meetingName string) <-chan types.UpdateData {
+// How many watched meetings have someone in them right now
+func (o *Orchestrator) LiveMeetings() int {
+	return o.allMeetings.Live()
+}
+
+// How well watch processes are keeping up with meeting updates
+func (o *Orchestrator) UpdateStats() bus.Stats {
+	return o.updates.Stats()
+}
+
+// Subscribes a watch process to a meeting's updates. Reports false if the meeting is already being watched in the
+// channel, in which case the caller must not start another process.
+func (o *Orchestrator) StartWatch(
+	guildID string,
+	meetingID string,
+	channelID string,
+	meetingName string,
+) (<-chan types.UpdateData, bool) {
+	if !o.meetingWatches.Add(guildID, meetingID, channelID) {
+		return nil, false
+	}
 	o.allMeetings.NewMeeting(meetingID, meetingName)
-	o.meetingWatches.Add(guildID, meetingID)
-	return o.dataListeners.Listen(guildID, meetingID)
+	return o.updates.Subscribe(guildID, meetingID, channelID), true
 }
 
-func (o Orchestrator) UpdateMeeting(meetingID string, data types.MeetingData) {
+// Hands a restarted watch process the subscription its predecessor left behind. Reports false if the watch has
+// since stopped, in which case there's nothing to restart.
+func (o *Orchestrator) ResumeWatch(guildID string, meetingID string, channelID string) (<-chan types.UpdateData, bool) {
+	if !o.meetingWatches.Exists(guildID, meetingID, channelID) {
+		return nil, false
+	}
+	return o.updates.Resubscribe(guildID, meetingID, channelID)
+}
+
+func (o *Orchestrator) UpdateMeeting(meetingID string, data types.MeetingData) {
 	update := types.UpdateData{
 		EventType:   data.EventType,
 		MeetingName: data.MeetingName,
@@ -65,12 +137,29 @@ func (o Orchestrator) UpdateMeeting(meetingID string, data types.MeetingData) {
 
 	switch data.EventType {
 	case types.ZOOM_PARTICIPANT_JOIN:
-		update.Participants = o.allMeetings.AddParticipant(meetingID, data.ParticipantID, data.ParticipantName)
+		update.Participants, update.Present = o.allMeetings.AddParticipant(
+			meetingID, data.ParticipantID, data.ParticipantName, data.ParticipantEmail, data.Timestamp,
+		)
 	case types.ZOOM_PARTICIPANT_LEAVE:
-		update.Participants = o.allMeetings.RemoveParticipant(meetingID, data.ParticipantID, data.ParticipantName)
+		update.Participants, update.Present = o.allMeetings.RemoveParticipant(
+			meetingID, data.ParticipantID, data.ParticipantName, data.Timestamp,
+		)
 	case types.ZOOM_MEETING_END:
 		update.MeetingDuration = calcMeetingDuration(data.StartTime, data.EndTime)
-		update.TotalParticipants = o.allMeetings.EndMeeting(meetingID)
+		update.Summary = o.allMeetings.EndMeeting(meetingID, data.Timestamp)
+		update.Summary.Start = parseZoomTime(data.StartTime, data.Timestamp)
+		update.Summary.End = parseZoomTime(data.EndTime, data.Timestamp)
+		err := o.Database.SaveMeetingHistory(db.MeetingHistory{
+			MeetingID:    meetingID,
+			MeetingTopic: data.MeetingName,
+			StartTime:    update.Summary.Start,
+			EndTime:      update.Summary.End,
+			Attendance:   update.Summary.Attendance,
+			GuildIDs:     o.meetingWatches.GetGuilds(meetingID),
+		})
+		if err != nil {
+			log.Println(err)
+		}
 	default:
 		log.Println("Unimplemented event type received:", data.EventType)
 		return
@@ -80,40 +169,132 @@ func (o Orchestrator) UpdateMeeting(meetingID string, data types.MeetingData) {
 
 	// Unless this is a silent update, push this new data to Discord
 	if !data.Silent {
-		for _, dataChannel := range o.dataListeners.GetMeetingListeners(meetingID) {
-			dataChannel <- update
-		}
+		o.updates.Publish(meetingID, update)
 	}
 }
 
-// Changes the selected options for a given watch
-func (o Orchestrator) UpdateFlags(guildID string, meetingID string, flags types.FeatureFlags) {
-	update := types.UpdateData{
+// Records a new watch so it survives a restart and is known to every node in the cluster
+func (o *Orchestrator) SaveWatch(watch db.WatchData) error {
+	if err := o.Database.SaveWatch(watch); err != nil {
+		return err
+	}
+	o.watches.put(watch)
+	o.replicateWatch(watch, false)
+	return nil
+}
+
+// Changes the selected options for a given watch and saves them so they survive a restart.
+// Returns db.ErrUnknownWatch if the meeting isn't being watched in the channel.
+func (o *Orchestrator) UpdateFlags(guildID string, meetingID string, channelID string, flags types.FeatureFlags) error {
+	if _, exists := o.watches.get(guildID, meetingID, channelID); !exists {
+		return db.ErrUnknownWatch
+	}
+	if err := o.Database.SaveWatchOptions(guildID, meetingID, channelID, flags); err != nil {
+		return err
+	}
+	// The status message may have changed in the meantime, so only the options are replaced
+	watch, updated := o.watches.update(guildID, meetingID, channelID, func(w *db.WatchData) { w.Options = flags })
+	if !updated {
+		// Canceled while the options were being saved
+		return db.ErrUnknownWatch
+	}
+	o.replicateWatch(watch, false)
+
+	o.updates.Send(guildID, meetingID, channelID, types.UpdateData{
 		EventType: types.UPDATE_FLAGS,
 		Flags:     flags,
+	})
+	return nil
+}
+
+// Records which message a watch keeps up to date so it can be picked up again after a restart or by another node.
+// An empty ID means the watch has no current status message.
+func (o *Orchestrator) SetStatusMessage(guildID string, meetingID string, channelID string, messageID string) error {
+	if err := o.Database.SaveStatusMessage(guildID, meetingID, channelID, messageID); err != nil {
+		return err
+	}
+	watch, exists := o.watches.update(
+		guildID, meetingID, channelID, func(w *db.WatchData) { w.StatusMessageID = messageID },
+	)
+	if exists {
+		o.replicateWatch(watch, false)
 	}
-	o.dataListeners.GetListener(guildID, meetingID) <- update
+	return nil
+}
+
+// Returns the details of a watch, if there is one on the meeting in the channel
+func (o *Orchestrator) GetWatch(guildID string, meetingID string, channelID string) (db.WatchData, bool) {
+	return o.watches.get(guildID, meetingID, channelID)
 }
 
-// Informs a watch process of a cancellation request so it can gracefully stop
-func (o Orchestrator) CancelWatch(guildID string, meetingID string) {
-	o.Database.DeleteWatch(guildID, meetingID)
-	o.dataListeners.Remove(guildID, meetingID, types.UpdateData{EventType: types.WATCH_CANCELED})
-	o.meetingWatches.Remove(guildID, meetingID)
+// Lists the people expected to attend a watched meeting
+func (o *Orchestrator) GetRoster(guildID string, meetingID string, channelID string) []string {
+	return o.rosters.Get(guildID, meetingID, channelID)
 }
 
-// Informs all watch processes of impeding shutdown so they can act accordingly
-func (o Orchestrator) Shutdown() {
+// Replaces the people expected to attend a watched meeting and saves the change
+func (o *Orchestrator) SetRoster(guildID string, meetingID string, channelID string, roster []string) error {
+	if err := o.Database.SaveRoster(guildID, meetingID, channelID, roster); err != nil {
+		return err
+	}
+	o.rosters.Set(guildID, meetingID, channelID, roster)
+
+	if watch, exists := o.watches.get(guildID, meetingID, channelID); exists {
+		o.replicateWatch(watch, false)
+	}
+	return nil
+}
+
+// Informs a watch process of a cancellation request so it can gracefully stop.
+// If the watch couldn't be removed from the database, it keeps running so it doesn't come back after a restart
+// without anyone noticing, and the error is returned.
+func (o *Orchestrator) CancelWatch(guildID string, meetingID string, channelID string) error {
+	watch, exists := o.watches.get(guildID, meetingID, channelID)
+	if err := o.cancelWatch(guildID, meetingID, channelID); err != nil {
+		return err
+	}
+	if exists {
+		o.replicateWatch(watch, true)
+	}
+	return nil
+}
+
+func (o *Orchestrator) cancelWatch(guildID string, meetingID string, channelID string) error {
+	if err := o.Database.DeleteWatch(guildID, meetingID, channelID); err != nil {
+		return err
+	}
+	o.watches.remove(guildID, meetingID, channelID)
+	o.rosters.Set(guildID, meetingID, channelID, nil)
+	o.updates.Close(guildID, meetingID, channelID, types.UpdateData{EventType: types.WATCH_CANCELED})
+	o.meetingWatches.Remove(guildID, meetingID, channelID)
+	return nil
+}
+
+// Informs all watch processes of impeding shutdown so they can act accordingly, reporting whether they were told
+// to stop
+func (o *Orchestrator) Shutdown() bool {
 	defer func() { o.ShutdownNotif <- struct{}{} }()
 
-	// Only notify listeners if the other server is unavailable
-	if checkServerHealth(o.SisterAddress) {
-		return
+	// Only the leader has anything posted to update, and only if no other node is ready to take over. The node
+	// has already stepped down by the time watches receive the notice, so receiving it is what tells them to post.
+	wasLeader := o.Cluster.IsLeader()
+	if failover := o.Cluster.StepDown(); !wasLeader || failover {
+		return false
+	}
+
+	if !o.updates.CloseAll(types.UpdateData{EventType: types.SYSTEM_SHUTDOWN}, shutdownGrace) {
+		log.Println("not every watch received the shutdown notice in time")
 	}
+	return true
+}
 
-	for _, watch := range o.dataListeners.GetAllListeners() {
-		watch <- types.UpdateData{EventType: types.SYSTEM_SHUTDOWN}
+// Parses a time sent by Zoom, falling back to the given time if it's missing or malformed
+func parseZoomTime(zoomTime string, fallback time.Time) time.Time {
+	t, err := time.Parse(types.ZOOM_TIME_FORMAT, zoomTime)
+	if err != nil {
+		return fallback
 	}
+	return t
 }
 
 func calcMeetingDuration(start string, end string) string {
@@ -135,27 +316,3 @@ func calcMeetingDuration(start string, end string) string {
 	}
 	return "Unknown"
 }
-
-// Returns true if requested server is healthy
-func checkServerHealth(address string) bool {
-	if address == "" {
-		return false
-	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, address+"/health", nil)
-	if err != nil {
-		return false
-	}
-
-	req.Header.Add("content-type", "application/json")
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return false
-	}
-	resp.Body.Close()
-
-	return resp.StatusCode == http.StatusOK
-}
@@ -0,0 +1,85 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/replication"
+)
+
+// A role allowed or denied an action on one node, replicated so every node enforces the same permissions
+type RoleChange struct {
+	Role    db.CommandRole `json:"role"`
+	Removed bool           `json:"removed"`
+}
+
+// Allows a role to take an action on a guild's watches, on every node
+func (o *Orchestrator) AddCommandRole(guildID string, action string, roleID string) error {
+	if err := o.Database.AddCommandRole(guildID, action, roleID); err != nil {
+		return err
+	}
+	o.replicateRole(RoleChange{Role: db.CommandRole{GuildID: guildID, Action: action, RoleID: roleID}})
+	return nil
+}
+
+// Removes a role's permission to take an action, on every node
+func (o *Orchestrator) RemoveCommandRole(guildID string, action string, roleID string) error {
+	if err := o.Database.RemoveCommandRole(guildID, action, roleID); err != nil {
+		return err
+	}
+	o.replicateRole(RoleChange{Role: db.CommandRole{GuildID: guildID, Action: action, RoleID: roleID}, Removed: true})
+	return nil
+}
+
+func (o *Orchestrator) replicateRole(change RoleChange) {
+	if len(o.Replicators) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(change)
+	if err != nil {
+		log.Printf("could not encode role change for replication: %s", err)
+		return
+	}
+	o.Replicate(replication.ROLE_EVENT, payload)
+}
+
+// Applies a role change replicated from another node without replicating it again
+func (o *Orchestrator) ApplyRoleChange(change RoleChange) {
+	role := change.Role
+	var err error
+	if change.Removed {
+		err = o.Database.RemoveCommandRole(role.GuildID, role.Action, role.RoleID)
+	} else {
+		err = o.Database.AddCommandRole(role.GuildID, role.Action, role.RoleID)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Replaces this node's roles with a peer's
+func (o *Orchestrator) restoreRoles(roles []db.CommandRole) {
+	kept := make(map[db.CommandRole]bool, len(roles))
+	for _, role := range roles {
+		kept[role] = true
+	}
+
+	existing, err := o.Database.GetAllCommandRoles()
+	if err != nil {
+		log.Println(err)
+	}
+	for _, role := range existing {
+		if !kept[role] {
+			if err = o.Database.RemoveCommandRole(role.GuildID, role.Action, role.RoleID); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	for _, role := range roles {
+		if err = o.Database.AddCommandRole(role.GuildID, role.Action, role.RoleID); err != nil {
+			log.Println(err)
+		}
+	}
+}
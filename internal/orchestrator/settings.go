@@ -0,0 +1,169 @@
+package orchestrator
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/replication"
+)
+
+// A guild's settings saved or reset on one node, replicated so every node starts watches the same way
+type SettingsChange struct {
+	Settings db.GuildSettings `json:"settings"`
+	Reset    bool             `json:"reset"`
+}
+
+// A guild's digest schedule changed on one node, replicated so whichever node leads posts it on time, and only once
+type DigestChange struct {
+	Settings db.DigestSettings `json:"settings"` // Only GuildID and LastSent are set when Sent is
+	Disabled bool              `json:"disabled"`
+	Sent     bool              `json:"sent"` // The digest was posted at Settings.LastSent; the schedule is unchanged
+}
+
+// Creates or replaces a guild's settings on every node
+func (o *Orchestrator) SaveGuildSettings(settings db.GuildSettings) error {
+	if err := o.Database.SaveGuildSettings(settings); err != nil {
+		return err
+	}
+	o.replicateSettings(replication.SETTINGS_EVENT, SettingsChange{Settings: settings})
+	return nil
+}
+
+// Returns a guild to the default settings on every node
+func (o *Orchestrator) DeleteGuildSettings(guildID string) error {
+	if err := o.Database.DeleteGuildSettings(guildID); err != nil {
+		return err
+	}
+	o.replicateSettings(replication.SETTINGS_EVENT, SettingsChange{
+		Settings: db.GuildSettings{GuildID: guildID},
+		Reset:    true,
+	})
+	return nil
+}
+
+// Creates or replaces a guild's digest schedule on every node, keeping when it was last sent
+func (o *Orchestrator) SaveDigestSettings(settings db.DigestSettings) error {
+	if err := o.Database.SaveDigestSettings(settings); err != nil {
+		return err
+	}
+	settings.LastSent = time.Time{}
+	o.replicateSettings(replication.DIGEST_EVENT, DigestChange{Settings: settings})
+	return nil
+}
+
+// Turns off a guild's digest on every node
+func (o *Orchestrator) DeleteDigestSettings(guildID string) error {
+	if err := o.Database.DeleteDigestSettings(guildID); err != nil {
+		return err
+	}
+	o.replicateSettings(replication.DIGEST_EVENT, DigestChange{
+		Settings: db.DigestSettings{GuildID: guildID},
+		Disabled: true,
+	})
+	return nil
+}
+
+// Records when a guild's digest was last posted on every node, so a new leader doesn't post it again
+func (o *Orchestrator) MarkDigestSent(guildID string, sent time.Time) error {
+	if err := o.Database.MarkDigestSent(guildID, sent); err != nil {
+		return err
+	}
+	o.replicateSettings(replication.DIGEST_EVENT, DigestChange{
+		Settings: db.DigestSettings{GuildID: guildID, LastSent: sent},
+		Sent:     true,
+	})
+	return nil
+}
+
+func (o *Orchestrator) replicateSettings(kind string, change any) {
+	if len(o.Replicators) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(change)
+	if err != nil {
+		log.Printf("could not encode %s change for replication: %s", kind, err)
+		return
+	}
+	o.Replicate(kind, payload)
+}
+
+// Applies a settings change replicated from another node without replicating it again
+func (o *Orchestrator) ApplySettingsChange(change SettingsChange) {
+	var err error
+	if change.Reset {
+		err = o.Database.DeleteGuildSettings(change.Settings.GuildID)
+	} else {
+		err = o.Database.SaveGuildSettings(change.Settings)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Applies a digest change replicated from another node without replicating it again
+func (o *Orchestrator) ApplyDigestChange(change DigestChange) {
+	var err error
+	switch {
+	case change.Disabled:
+		err = o.Database.DeleteDigestSettings(change.Settings.GuildID)
+	case change.Sent:
+		err = o.Database.MarkDigestSent(change.Settings.GuildID, change.Settings.LastSent)
+	default:
+		err = o.Database.SaveDigestSettings(change.Settings)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
+
+// Replaces this node's guild settings and digest schedules with a peer's
+func (o *Orchestrator) restoreSettings(allSettings []db.GuildSettings, digests []db.DigestSettings) {
+	keptSettings := make(map[string]bool, len(allSettings))
+	for _, settings := range allSettings {
+		keptSettings[settings.GuildID] = true
+	}
+	existingSettings, err := o.Database.GetAllGuildSettings()
+	if err != nil {
+		log.Println(err)
+	}
+	for _, settings := range existingSettings {
+		if !keptSettings[settings.GuildID] {
+			if err = o.Database.DeleteGuildSettings(settings.GuildID); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	for _, settings := range allSettings {
+		if err = o.Database.SaveGuildSettings(settings); err != nil {
+			log.Println(err)
+		}
+	}
+
+	keptDigests := make(map[string]bool, len(digests))
+	for _, digest := range digests {
+		keptDigests[digest.GuildID] = true
+	}
+	existingDigests, err := o.Database.GetAllDigestSettings()
+	if err != nil {
+		log.Println(err)
+	}
+	for _, digest := range existingDigests {
+		if !keptDigests[digest.GuildID] {
+			if err = o.Database.DeleteDigestSettings(digest.GuildID); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	for _, digest := range digests {
+		err = o.Database.SaveDigestSettings(digest)
+		if err == nil && !digest.LastSent.IsZero() {
+			err = o.Database.MarkDigestSent(digest.GuildID, digest.LastSent)
+		}
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/peer"
+	"github.com/angelajfisher/meeting-mate/internal/replication"
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+const SNAPSHOT_SLUG = "/peer/snapshot"
+
+var ErrNoPeers = errors.New("no peers available to sync from")
+
+// Everything a node needs to pick up where another left off
+type Snapshot struct {
+	TakenAt  time.Time                 `json:"taken_at"`
+	Versions replication.VersionVector `json:"versions"` // The latest change from each node the snapshot reflects
+	Watches  []db.WatchData            `json:"watches"`
+	Meetings []types.MeetingSnapshot   `json:"meetings"`
+	Roles    []db.CommandRole          `json:"roles"`
+	Settings []db.GuildSettings        `json:"settings"`
+	Digests  []db.DigestSettings       `json:"digests"`
+}
+
+// A watch created, changed, or canceled on one node, replicated so every node knows about it
+type WatchChange struct {
+	Watch    db.WatchData `json:"watch"`
+	Canceled bool         `json:"canceled"`
+}
+
+// Every watch this node knows about, with enough detail to recreate it on another node
+type watchRegistry struct {
+	watches map[[3]string]db.WatchData // map[{guildID, meetingID, channelID}]watch
+	synced  replication.VersionVector  // The changes reflected in the snapshot this node started from
+	onAdded func(db.WatchData)         // Starts a watch created on another node
+	mu      sync.RWMutex
+}
+
+func newWatchRegistry() *watchRegistry {
+	return &watchRegistry{
+		watches: make(map[[3]string]db.WatchData),
+	}
+}
+
+func watchKey(watch db.WatchData) [3]string {
+	return [3]string{watch.GuildID, watch.MeetingID, watch.ChannelID}
+}
+
+func (r *watchRegistry) get(guildID string, meetingID string, channelID string) (db.WatchData, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	watch, exists := r.watches[[3]string{guildID, meetingID, channelID}]
+	return watch, exists
+}
+
+func (r *watchRegistry) put(watch db.WatchData) {
+	r.swap(watch)
+}
+
+// Stores a watch and returns the one it replaced, if any, in a single step
+func (r *watchRegistry) swap(watch db.WatchData) (db.WatchData, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, exists := r.watches[watchKey(watch)]
+	watch.Roster = nil // Rosters are tracked separately so they can change without touching the watch
+	r.watches[watchKey(watch)] = watch
+	return existing, exists
+}
+
+// Changes a watch in place, returning the result. Does nothing if the watch doesn't exist.
+func (r *watchRegistry) update(
+	guildID string,
+	meetingID string,
+	channelID string,
+	change func(*db.WatchData),
+) (db.WatchData, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [3]string{guildID, meetingID, channelID}
+	watch, exists := r.watches[key]
+	if !exists {
+		return watch, false
+	}
+	change(&watch)
+	r.watches[key] = watch
+	return watch, true
+}
+
+func (r *watchRegistry) remove(guildID string, meetingID string, channelID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.watches, [3]string{guildID, meetingID, channelID})
+}
+
+func (r *watchRegistry) all() []db.WatchData {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	watches := make([]db.WatchData, 0, len(r.watches))
+	for _, watch := range r.watches {
+		watches = append(watches, watch)
+	}
+	return watches
+}
+
+// Lists every watch known to this node, including those saved before a restart or learned from a peer
+func (o *Orchestrator) GetAllWatches() []db.WatchData {
+	watches := o.watches.all()
+	for i := range watches {
+		watches[i].Roster = o.rosters.Get(watches[i].GuildID, watches[i].MeetingID, watches[i].ChannelID)
+	}
+	return watches
+}
+
+// Registers the function that starts a watch process for a watch created on another node
+func (o *Orchestrator) OnRemoteWatch(start func(db.WatchData)) {
+	o.watches.mu.Lock()
+	defer o.watches.mu.Unlock()
+
+	o.watches.onAdded = start
+}
+
+// Whether a change replicated from another node was already reflected in the snapshot this node caught up from
+func (o *Orchestrator) AlreadySynced(origin string, version replication.Version) bool {
+	o.watches.mu.RLock()
+	defer o.watches.mu.RUnlock()
+
+	return o.watches.synced.Covers(origin, version)
+}
+
+// Records that a change replicated from another node has been applied, so snapshots taken from now on reflect it
+func (o *Orchestrator) MarkApplied(origin string, version replication.Version) {
+	o.versions.Applied(origin, version)
+}
+
+// Sends a change to every peer
+func (o *Orchestrator) Replicate(kind string, payload []byte) {
+	o.versions.Next(func(version replication.Version) {
+		for _, replicator := range o.Replicators {
+			replicator.Enqueue(kind, version, payload)
+		}
+	})
+}
+
+func (o *Orchestrator) replicateWatch(watch db.WatchData, canceled bool) {
+	if len(o.Replicators) == 0 {
+		return
+	}
+
+	watch.Roster = o.rosters.Get(watch.GuildID, watch.MeetingID, watch.ChannelID)
+	payload, err := json.Marshal(WatchChange{Watch: watch, Canceled: canceled})
+	if err != nil {
+		log.Printf("could not encode watch change for replication: %s", err)
+		return
+	}
+	o.Replicate(replication.WATCH_EVENT, payload)
+}
+
+// Applies a watch change replicated from another node without replicating it again
+func (o *Orchestrator) ApplyWatchChange(change WatchChange) {
+	watch := change.Watch
+	if change.Canceled {
+		if _, exists := o.watches.get(watch.GuildID, watch.MeetingID, watch.ChannelID); exists {
+			if err := o.cancelWatch(watch.GuildID, watch.MeetingID, watch.ChannelID); err != nil {
+				log.Println(err)
+			}
+		}
+		return
+	}
+
+	existing, exists := o.watches.swap(watch)
+	o.rosters.Set(watch.GuildID, watch.MeetingID, watch.ChannelID, watch.Roster)
+	o.persistWatch(watch)
+
+	if !exists {
+		o.watches.mu.RLock()
+		start := o.watches.onAdded
+		o.watches.mu.RUnlock()
+		if start != nil {
+			start(watch)
+		}
+		return
+	}
+	ongoing := o.IsOngoingWatch(watch.GuildID, watch.MeetingID, watch.ChannelID)
+	if existing.Options != watch.Options && ongoing {
+		o.updates.Send(watch.GuildID, watch.MeetingID, watch.ChannelID, types.UpdateData{
+			EventType: types.UPDATE_FLAGS,
+			Flags:     watch.Options,
+		})
+	}
+	// Lets this node pick up the same message if it takes over as leader
+	if existing.StatusMessageID != watch.StatusMessageID && ongoing {
+		o.updates.Send(watch.GuildID, watch.MeetingID, watch.ChannelID, types.UpdateData{
+			EventType:       types.STATUS_MESSAGE,
+			StatusMessageID: watch.StatusMessageID,
+		})
+	}
+}
+
+// Copies this node's watches, live meeting state, permissions, and settings for a peer that's catching up
+func (o *Orchestrator) Snapshot() (Snapshot, error) {
+	// Versions are read first so a change applied meanwhile is sent again rather than missed
+	snapshot := Snapshot{
+		Versions: o.versions.Vector(),
+		TakenAt:  time.Now().UTC(),
+		Watches:  o.GetAllWatches(),
+		Meetings: o.allMeetings.Snapshot(),
+	}
+
+	// A peer adopts the snapshot wholesale, so a partial one would wipe out what's missing
+	var err error
+	if snapshot.Roles, err = o.Database.GetAllCommandRoles(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Settings, err = o.Database.GetAllGuildSettings(); err != nil {
+		return snapshot, err
+	}
+	if snapshot.Digests, err = o.Database.GetAllDigestSettings(); err != nil {
+		return snapshot, err
+	}
+	return snapshot, nil
+}
+
+// Pulls a snapshot from the first peer that answers, preferring the leader, and adopts it. Peers keep sending
+// changes through replication from then on, so anything they queued before the snapshot is skipped.
+// Must be called before any watch processes are started.
+func (o *Orchestrator) CatchUp() error {
+	peers := o.Cluster.ReachablePeers()
+	if len(peers) == 0 {
+		return ErrNoPeers
+	}
+
+	var errs []error
+	for _, p := range peers {
+		snapshot, err := fetchSnapshot(p)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.Address, err))
+			continue
+		}
+		o.restoreSnapshot(snapshot)
+		log.Printf(
+			"Caught up from %s: %d watches, %d meetings",
+			p.Address, len(snapshot.Watches), len(snapshot.Meetings),
+		)
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+func fetchSnapshot(p *peer.Client) (Snapshot, error) {
+	var snapshot Snapshot
+
+	resp, err := p.Do(context.Background(), http.MethodGet, SNAPSHOT_SLUG, nil)
+	if err != nil {
+		return snapshot, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return snapshot, fmt.Errorf("peer responded with %s", resp.Status)
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&snapshot); err != nil {
+		return snapshot, fmt.Errorf("could not decode snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// Replaces this node's watches, meeting state, permissions, and settings with a peer's. The peer has been running
+// while this node was away, so watches only known here were canceled in the meantime, unless this node changed
+// them and hasn't sent the change yet.
+func (o *Orchestrator) restoreSnapshot(snapshot Snapshot) {
+	unsent := o.unsentWatchChanges()
+	kept := make(map[[3]string]bool, len(snapshot.Watches))
+	for _, watch := range snapshot.Watches {
+		kept[watchKey(watch)] = true
+	}
+	for _, watch := range o.watches.all() {
+		if _, changed := unsent[watchKey(watch)]; !kept[watchKey(watch)] && !changed {
+			if err := o.Database.DeleteWatch(watch.GuildID, watch.MeetingID, watch.ChannelID); err != nil {
+				log.Println(err)
+			}
+			o.rosters.Set(watch.GuildID, watch.MeetingID, watch.ChannelID, nil)
+			o.watches.remove(watch.GuildID, watch.MeetingID, watch.ChannelID)
+		}
+	}
+
+	for _, watch := range snapshot.Watches {
+		// The peer will hear about this node's newer change, or cancellation, once it's sent
+		if _, changed := unsent[watchKey(watch)]; changed {
+			continue
+		}
+		o.watches.put(watch)
+		o.rosters.Set(watch.GuildID, watch.MeetingID, watch.ChannelID, watch.Roster)
+		o.persistWatch(watch)
+	}
+
+	o.allMeetings.Restore(snapshot.Meetings)
+	o.restoreRoles(snapshot.Roles)
+	o.restoreSettings(snapshot.Settings, snapshot.Digests)
+
+	o.versions.Merge(snapshot.Versions)
+	o.watches.mu.Lock()
+	o.watches.synced = snapshot.Versions
+	o.watches.mu.Unlock()
+}
+
+// Finds the latest change to each watch still waiting to be sent to a peer, which no snapshot taken by that peer
+// reflects yet
+func (o *Orchestrator) unsentWatchChanges() map[[3]string]WatchChange {
+	latest := make(map[[3]string]WatchChange)
+	versions := make(map[[3]string]replication.Version)
+	for _, replicator := range o.Replicators {
+		events, err := replicator.Pending()
+		if err != nil {
+			log.Printf("could not check for unsent watch changes: %s", err)
+			continue
+		}
+		for _, event := range events {
+			if event.Kind != replication.WATCH_EVENT {
+				continue
+			}
+			var change WatchChange
+			if err = json.Unmarshal(event.Payload, &change); err != nil {
+				continue
+			}
+			key := watchKey(change.Watch)
+			if version, seen := versions[key]; seen && version.After(event.Version) {
+				continue
+			}
+			versions[key] = event.Version
+			latest[key] = change
+		}
+	}
+	return latest
+}
+
+// Saves a watch learned from a peer. The peer already has it, so a local failure is only logged.
+func (o *Orchestrator) persistWatch(watch db.WatchData) {
+	err := o.Database.SaveWatch(watch)
+	if err == nil {
+		err = o.Database.SaveStatusMessage(watch.GuildID, watch.MeetingID, watch.ChannelID, watch.StatusMessageID)
+	}
+	if err == nil {
+		err = o.Database.SaveRoster(watch.GuildID, watch.MeetingID, watch.ChannelID, watch.Roster)
+	}
+	if err != nil {
+		log.Println(err)
+	}
+}
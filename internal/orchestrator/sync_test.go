@@ -0,0 +1,115 @@
+package orchestrator
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/cluster"
+	"github.com/angelajfisher/meeting-mate/internal/db"
+	"github.com/angelajfisher/meeting-mate/internal/peer"
+	"github.com/angelajfisher/meeting-mate/internal/replication"
+)
+
+// An orchestrator for node n1 whose changes for n2 stay queued, as if n2 were unreachable
+func newTestOrchestrator(t *testing.T, store db.Store) *Orchestrator {
+	t.Helper()
+
+	client, err := peer.NewClient("http://n2.invalid", peer.Config{Secret: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	replicators := []*replication.Replicator{
+		replication.NewReplicator("n1", client, replication.NewQueue(store, "n2")),
+	}
+	return NewOrchestrator(cluster.New("n1", nil, time.Second, nil), replicators, store, nil)
+}
+
+func testWatch(meetingID string) db.WatchData {
+	return db.WatchData{GuildID: "g1", MeetingID: meetingID, ChannelID: "c1", MeetingTopic: "Meeting " + meetingID}
+}
+
+// Watches changed here but not yet sent to the peer survive catching up from it, while the rest follow the peer
+func TestRestoreSnapshotKeepsUnsentChanges(t *testing.T) {
+	store := db.NewMemoryStore()
+	for _, meetingID := range []string{"stale", "canceled"} {
+		if err := store.SaveWatch(testWatch(meetingID)); err != nil {
+			t.Fatalf("SaveWatch: %s", err)
+		}
+	}
+	o := newTestOrchestrator(t, store)
+	if err := o.SaveWatch(testWatch("created")); err != nil {
+		t.Fatalf("SaveWatch: %s", err)
+	}
+	if err := o.CancelWatch("g1", "canceled", "c1"); err != nil {
+		t.Fatalf("CancelWatch: %s", err)
+	}
+
+	// Taken by n2 before either change reached it, and after it canceled the stale watch
+	o.restoreSnapshot(Snapshot{
+		Versions: replication.VersionVector{"n2": {Epoch: 1, Seq: 10}},
+		Watches:  []db.WatchData{testWatch("canceled"), testWatch("peer")},
+	})
+
+	want := []string{"created", "peer"}
+	var got []string
+	for _, watch := range o.GetAllWatches() {
+		got = append(got, watch.MeetingID)
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("watches after catching up = %v, want %v", got, want)
+	}
+
+	saved, err := store.GetAllWatches()
+	if err != nil {
+		t.Fatalf("GetAllWatches: %s", err)
+	}
+	got = nil
+	for _, watch := range saved {
+		got = append(got, watch.MeetingID)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("saved watches after catching up = %v, want %v", got, want)
+	}
+
+	// Only changes from n2 that the snapshot reflects are skipped when they arrive
+	tests := []struct {
+		origin  string
+		version replication.Version
+		synced  bool
+	}{
+		{"n2", replication.Version{Epoch: 1, Seq: 10}, true},
+		{"n2", replication.Version{Epoch: 1, Seq: 11}, false},
+		{"n3", replication.Version{Epoch: 1, Seq: 1}, false},
+	}
+	for _, test := range tests {
+		if got := o.AlreadySynced(test.origin, test.version); got != test.synced {
+			t.Errorf("AlreadySynced(%s, %+v) = %t, want %t", test.origin, test.version, got, test.synced)
+		}
+	}
+}
+
+// A snapshot reflects every change made or applied on the node that took it
+func TestSnapshotVersions(t *testing.T) {
+	o := newTestOrchestrator(t, db.NewMemoryStore())
+	if err := o.SaveWatch(testWatch("m1")); err != nil {
+		t.Fatalf("SaveWatch: %s", err)
+	}
+	o.MarkApplied("n2", replication.Version{Epoch: 1, Seq: 3})
+
+	snapshot, err := o.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %s", err)
+	}
+	events, err := o.Replicators[0].Pending()
+	if err != nil || len(events) != 1 {
+		t.Fatalf("Pending() = %+v, %v", events, err)
+	}
+	if !snapshot.Versions.Covers("n1", events[0].Version) {
+		t.Errorf("snapshot versions %+v don't cover the watch's change %+v", snapshot.Versions, events[0].Version)
+	}
+	if !snapshot.Versions.Covers("n2", replication.Version{Epoch: 1, Seq: 3}) {
+		t.Errorf("snapshot versions %+v don't cover the change applied from n2", snapshot.Versions)
+	}
+}
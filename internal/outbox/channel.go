@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	opSend = iota
+	opEdit
+	opDelete
+)
+
+type op struct {
+	kind      int
+	send      *message
+	edit      *message
+	messageID string
+
+	due      time.Time // Not tried before this
+	attempts int
+	sending  bool // Being sent right now, so it can't be replaced
+
+	result chan result // For sends and deletes, whose callers wait on the outcome
+	onFail func(error) // For edits, which nobody waits on
+}
+
+type result struct {
+	message *discordgo.Message
+	err     error
+}
+
+// The requests waiting to go to a single channel, sent one at a time in order
+type channel struct {
+	id      string
+	outbox  *Outbox
+	ops     []*op
+	stopped bool // Set once the outbox has closed and the queue has emptied
+	mu      sync.Mutex
+	wake    chan struct{}
+}
+
+func (c *channel) push(request *op) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopped {
+		request.result <- result{err: ErrClosed}
+		return
+	}
+
+	request.due = time.Now()
+	kept := c.ops[:0]
+	for _, queued := range c.ops {
+		if queued.kind == opEdit && !queued.sending {
+			// Nothing may jump the queue, so edits ahead of this request stop waiting on newer versions
+			queued.due = request.due
+			// An edit to a message about to be deleted would only fail
+			if request.kind == opDelete && queued.edit.messageID() == request.messageID {
+				continue
+			}
+		}
+		kept = append(kept, queued)
+	}
+	c.ops = append(kept, request)
+	c.signal()
+}
+
+func (c *channel) edit(edit *message, due time.Time, onFail func(error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopped {
+		if onFail != nil {
+			go onFail(ErrClosed)
+		}
+		return
+	}
+
+	for _, queued := range c.ops {
+		if queued.kind == opEdit && !queued.sending && queued.edit.messageID() == edit.messageID() {
+			queued.edit = queued.edit.replacedBy(edit)
+			queued.onFail = onFail
+			return
+		}
+	}
+	c.ops = append(c.ops, &op{kind: opEdit, edit: edit, due: due, onFail: onFail})
+	c.signal()
+}
+
+// Works through the queue until the outbox closes and everything queued has been sent
+func (c *channel) run() {
+	defer c.outbox.wg.Done()
+
+	for {
+		c.mu.Lock()
+		closing := c.closing()
+		if len(c.ops) == 0 {
+			c.stopped = closing
+			c.mu.Unlock()
+			if closing {
+				return
+			}
+			select {
+			case <-c.wake:
+			case <-c.outbox.closing:
+			}
+			continue
+		}
+
+		head := c.ops[0]
+		wait := time.Until(head.due)
+		if closing && head.attempts == 0 {
+			wait = 0 // Edits being held for newer versions go out right away on shutdown
+		}
+		if wait > 0 {
+			c.mu.Unlock()
+			interrupt := c.outbox.closing
+			if closing {
+				interrupt = nil // Already closing, so only a retry's backoff is left to wait out
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-c.wake:
+			case <-interrupt:
+			}
+			timer.Stop()
+			continue
+		}
+		head.sending = true
+		c.mu.Unlock()
+
+		sent, err := c.outbox.perform(c.id, head)
+
+		c.mu.Lock()
+		head.sending = false
+		head.attempts++
+		if err != nil && transient(err) && head.attempts < maxAttempts {
+			head.due = time.Now().Add(backoff(err, head.attempts))
+			c.mu.Unlock()
+			continue
+		}
+		c.ops = c.ops[1:]
+		c.mu.Unlock()
+
+		c.finish(head, sent, err)
+	}
+}
+
+func (c *channel) finish(request *op, sent *discordgo.Message, err error) {
+	if request.result != nil {
+		request.result <- result{message: sent, err: err}
+		return
+	}
+	if err != nil {
+		log.Printf(
+			"outbox: giving up on edit to message ID %s in channel ID %s after %d tries: %s",
+			request.edit.messageID(), c.id, request.attempts, err,
+		)
+		if request.onFail != nil {
+			request.onFail(err)
+		}
+	}
+}
+
+func (c *channel) closing() bool {
+	select {
+	case <-c.outbox.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *channel) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
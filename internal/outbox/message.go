@@ -0,0 +1,119 @@
+package outbox
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// A copy of a message taken when it's queued, so the caller can keep changing its own and each try can re-read
+// the attached files
+type message struct {
+	send  *discordgo.MessageSend
+	edit  *discordgo.MessageEdit
+	files []file
+}
+
+type file struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+func cloneSend(original *discordgo.MessageSend) *message {
+	send := *original
+	send.Embeds = cloneEmbeds(original.Embeds)
+	send.Components = slices.Clone(original.Components)
+	files := readFiles(original.Files)
+	send.Files = nil
+	return &message{send: &send, files: files}
+}
+
+func cloneEdit(original *discordgo.MessageEdit) *message {
+	edit := *original
+	if original.Embeds != nil {
+		embeds := cloneEmbeds(*original.Embeds)
+		edit.Embeds = &embeds
+	}
+	if original.Components != nil {
+		components := slices.Clone(*original.Components)
+		edit.Components = &components
+	}
+	files := readFiles(original.Files)
+	edit.Files = nil
+	return &message{edit: &edit, files: files}
+}
+
+func (m *message) messageID() string {
+	return m.edit.ID
+}
+
+// Combines a queued edit with a newer one to the same message. Files attached to the older edit are kept unless
+// the newer one brings its own, since they'd otherwise never be uploaded.
+func (m *message) replacedBy(newer *message) *message {
+	if len(newer.files) == 0 {
+		newer.files = m.files
+	}
+	return newer
+}
+
+// Builds the request for a single try
+func (m *message) build() *discordgo.MessageSend {
+	send := *m.send
+	send.Files = m.discordFiles()
+	return &send
+}
+
+func (m *message) buildEdit() *discordgo.MessageEdit {
+	edit := *m.edit
+	edit.Files = m.discordFiles()
+	return &edit
+}
+
+func (m *message) discordFiles() []*discordgo.File {
+	if len(m.files) == 0 {
+		return nil
+	}
+	files := make([]*discordgo.File, 0, len(m.files))
+	for _, f := range m.files {
+		files = append(files, &discordgo.File{
+			Name:        f.name,
+			ContentType: f.contentType,
+			Reader:      bytes.NewReader(f.data),
+		})
+	}
+	return files
+}
+
+func readFiles(files []*discordgo.File) []file {
+	read := make([]file, 0, len(files))
+	for _, f := range files {
+		data, err := io.ReadAll(f.Reader)
+		if err != nil {
+			log.Printf("outbox: could not read attachment %s: %s", f.Name, err)
+			continue
+		}
+		read = append(read, file{name: f.Name, contentType: f.ContentType, data: data})
+	}
+	return read
+}
+
+// Deep copies embeds so later changes by the caller don't leak into a queued message
+func cloneEmbeds(embeds []*discordgo.MessageEmbed) []*discordgo.MessageEmbed {
+	if embeds == nil {
+		return nil
+	}
+	encoded, err := json.Marshal(embeds)
+	if err == nil {
+		var cloned []*discordgo.MessageEmbed
+		if err = json.Unmarshal(encoded, &cloned); err == nil {
+			return cloned
+		}
+	}
+	log.Printf("outbox: could not copy embeds: %s", err)
+	return slices.Clone(embeds)
+}
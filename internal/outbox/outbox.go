@@ -0,0 +1,172 @@
+// Queues messages to Discord per channel so bursts of edits collapse into one and failures are retried
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// How many times a request is tried before it's reported as failing
+	maxAttempts = 5
+	// Longest wait between tries of a failing request
+	maxBackoff = 30 * time.Second
+)
+
+var ErrClosed = errors.New("outbox is closed")
+
+type Outbox struct {
+	session  *discordgo.Session
+	debounce time.Duration // How long an edit waits for newer edits to the same message before it's sent
+
+	channels map[string]*channel // map[channelID]queue
+	closed   bool                // Set under mu once Close starts, so no worker can be added while it waits
+	mu       sync.Mutex
+
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+// Creates an outbox that holds each edit for the debounce period so only the latest state of a message is sent
+func New(session *discordgo.Session, debounce time.Duration) *Outbox {
+	return &Outbox{
+		session:  session,
+		debounce: debounce,
+		channels: make(map[string]*channel),
+		closing:  make(chan struct{}),
+	}
+}
+
+// Sends a new message once everything queued for the channel before it has gone out
+func (o *Outbox) Send(channelID string, message *discordgo.MessageSend) (*discordgo.Message, error) {
+	res, err := o.wait(channelID, &op{kind: opSend, send: cloneSend(message)})
+	if err != nil {
+		return nil, fmt.Errorf("could not send message to channel ID %s: %w", channelID, err)
+	}
+	return res, nil
+}
+
+// Queues an edit without waiting for it. Any edit to the same message still waiting is replaced, so only the latest
+// content is sent. If the edit fails for good, onFail is called with the error from its final try.
+func (o *Outbox) Edit(edit *discordgo.MessageEdit, onFail func(error)) {
+	c := o.channel(edit.Channel)
+	if c == nil {
+		if onFail != nil {
+			onFail(ErrClosed)
+		}
+		return
+	}
+
+	c.edit(cloneEdit(edit), time.Now().Add(o.debounce), onFail)
+}
+
+// Deletes a message once everything queued for the channel before it has gone out. Edits to the message that are
+// still waiting are dropped.
+func (o *Outbox) Delete(channelID string, messageID string) error {
+	if _, err := o.wait(channelID, &op{kind: opDelete, messageID: messageID}); err != nil {
+		return fmt.Errorf("could not delete message ID %s: %w", messageID, err)
+	}
+	return nil
+}
+
+// Sends everything still queued right away, waiting up to the timeout for it to go out.
+// Nothing can be queued afterwards.
+func (o *Outbox) Close(timeout time.Duration) {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.closing)
+	}
+	o.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		o.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Println("outbox: gave up waiting for queued messages to be sent")
+	}
+}
+
+func (o *Outbox) wait(channelID string, request *op) (*discordgo.Message, error) {
+	c := o.channel(channelID)
+	if c == nil {
+		return nil, ErrClosed
+	}
+
+	request.result = make(chan result, 1)
+	c.push(request)
+	res := <-request.result
+	return res.message, res.err
+}
+
+// Finds a channel's queue, starting its worker if it's the first message for the channel.
+// Returns nil once the outbox has started closing.
+func (o *Outbox) channel(channelID string) *channel {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return nil
+	}
+
+	c, exists := o.channels[channelID]
+	if !exists {
+		c = &channel{id: channelID, outbox: o, wake: make(chan struct{}, 1)}
+		o.channels[channelID] = c
+		o.wg.Add(1)
+		go c.run()
+	}
+	return c
+}
+
+// Makes a single request to Discord. Rate limits are handed back as errors rather than waited out so newer edits
+// can replace the one being held back.
+func (o *Outbox) perform(channelID string, request *op) (*discordgo.Message, error) {
+	noRetry := discordgo.WithRetryOnRatelimit(false)
+	switch request.kind {
+	case opSend:
+		return o.session.ChannelMessageSendComplex(channelID, request.send.build(), noRetry)
+	case opEdit:
+		return o.session.ChannelMessageEditComplex(request.edit.buildEdit(), noRetry)
+	default:
+		return nil, o.session.ChannelMessageDelete(channelID, request.messageID, noRetry)
+	}
+}
+
+// The JSON error code Discord responded with, or 0 if the error didn't come from Discord
+func ErrorCode(err error) int {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Message != nil {
+		return restErr.Message.Code
+	}
+	return 0
+}
+
+// Whether a failed request is worth trying again
+func transient(err error) bool {
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) {
+		status := restErr.Response.StatusCode
+		return status == 429 || status >= 500
+	}
+	// Rate limits and network trouble both clear up on their own
+	return true
+}
+
+// How long to wait before the given try of a failing request
+func backoff(err error, attempt int) time.Duration {
+	var rateLimit *discordgo.RateLimitError
+	if errors.As(err, &rateLimit) && rateLimit.TooManyRequests != nil {
+		return rateLimit.RetryAfter
+	}
+	return min(time.Second<<(attempt-1), maxBackoff)
+}
@@ -0,0 +1,178 @@
+// Authenticated communication between Meeting Mate nodes
+package peer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	TIMESTAMP_HEADER = "X-Meeting-Mate-Timestamp"
+	SIGNATURE_HEADER = "X-Meeting-Mate-Signature"
+
+	// How far a signed request's timestamp may drift from our clock before it's rejected as a replay
+	maxClockSkew = 5 * time.Minute
+)
+
+var ErrUnauthenticated = errors.New("peer request is not authenticated")
+
+// How nodes prove their identity to each other. At least one of Secret or CABundle must be set.
+type Config struct {
+	Secret   string        // Shared secret used to sign every request with HMAC-SHA256
+	CABundle string        // Path to the CA bundle that issued peer certificates; enables mutual TLS
+	CertFile string        // Path to this node's client certificate for mutual TLS
+	KeyFile  string        // Path to this node's client key for mutual TLS
+	Timeout  time.Duration // How long a single request to a peer may take
+}
+
+func (c Config) Enabled() bool {
+	return c.Secret != "" || c.CABundle != ""
+}
+
+// Loads the CA bundle used to verify peer certificates, if one is configured
+func (c Config) CAPool() (*x509.CertPool, error) {
+	if c.CABundle == "" {
+		return nil, nil
+	}
+
+	bundle, err := os.ReadFile(c.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("could not read peer CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.New("peer CA bundle contains no certificates")
+	}
+	return pool, nil
+}
+
+// Makes every request from this node to another. Requests are signed when a secret is configured and
+// present a client certificate when mutual TLS is configured.
+type Client struct {
+	Address string // Base URL of the peer, including the site's base path
+	secret  []byte
+	http    *http.Client
+}
+
+func NewClient(address string, conf Config) (*Client, error) {
+	if !conf.Enabled() {
+		return nil, errors.New("peer authentication requires a shared secret or a CA bundle")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if conf.CABundle != "" {
+		pool, err := conf.CAPool()
+		if err != nil {
+			return nil, err
+		}
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load peer client certificate: %w", err)
+		}
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:      pool,
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	return &Client{
+		Address: address,
+		secret:  []byte(conf.Secret),
+		http:    &http.Client{Transport: transport, Timeout: conf.Timeout},
+	}, nil
+}
+
+// Sends a request to the given path on the peer. The caller must close the response body.
+func (c *Client) Do(ctx context.Context, method string, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.Address+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if len(c.secret) > 0 {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TIMESTAMP_HEADER, timestamp)
+		req.Header.Set(SIGNATURE_HEADER, sign(c.secret, method, req.URL.Path, timestamp, body))
+	}
+
+	return c.http.Do(req)
+}
+
+// Checks that requests come from a trusted peer
+type Verifier struct {
+	secret     []byte
+	requireTLS bool
+}
+
+func NewVerifier(conf Config) Verifier {
+	return Verifier{
+		secret:     []byte(conf.Secret),
+		requireTLS: conf.CABundle != "",
+	}
+}
+
+// Rejects any request that doesn't carry a valid signature and, with mutual TLS, a verified client certificate.
+// The request body is read in full and replaced so the next handler can still read it.
+func (v Verifier) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := v.verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (v Verifier) verify(r *http.Request) error {
+	if v.requireTLS && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+		return ErrUnauthenticated
+	}
+	if len(v.secret) == 0 {
+		return nil
+	}
+
+	timestamp := r.Header.Get(TIMESTAMP_HEADER)
+	sentAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrUnauthenticated
+	}
+	if skew := time.Since(time.Unix(sentAt, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return ErrUnauthenticated
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	expected := sign(v.secret, r.Method, r.URL.Path, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(SIGNATURE_HEADER))) {
+		return ErrUnauthenticated
+	}
+	return nil
+}
+
+// Signs the parts of a request that matter so none can be altered or replayed elsewhere
+func sign(secret []byte, method string, path string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(method + "\n" + path + "\n" + timestamp + "\n"))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,167 @@
+package peer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSecret = "correct horse battery staple"
+
+// Builds a request to /replicate signed the way Client.Do signs it
+func signedRequest(secret string, sentAt time.Time, body string) *http.Request {
+	timestamp := strconv.FormatInt(sentAt.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, "/replicate", bytes.NewReader([]byte(body)))
+	r.Header.Set(TIMESTAMP_HEADER, timestamp)
+	r.Header.Set(SIGNATURE_HEADER, sign([]byte(secret), http.MethodPost, "/replicate", timestamp, []byte(body)))
+	return r
+}
+
+func TestVerifySignature(t *testing.T) {
+	const body = `{"id":"e1"}`
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		valid   bool
+	}{
+		{"valid signature", func() *http.Request {
+			return signedRequest(testSecret, now, body)
+		}, true},
+		{"valid signature with no body", func() *http.Request {
+			return signedRequest(testSecret, now, "")
+		}, true},
+		{"timestamp within the allowed skew", func() *http.Request {
+			return signedRequest(testSecret, now.Add(-maxClockSkew+time.Minute), body)
+		}, true},
+		{"tampered body", func() *http.Request {
+			r := signedRequest(testSecret, now, body)
+			r.Body = io.NopCloser(bytes.NewReader([]byte(`{"id":"e2"}`)))
+			return r
+		}, false},
+		{"wrong key", func() *http.Request {
+			return signedRequest("some other secret", now, body)
+		}, false},
+		{"expired timestamp", func() *http.Request {
+			return signedRequest(testSecret, now.Add(-maxClockSkew-time.Minute), body)
+		}, false},
+		{"timestamp too far in the future", func() *http.Request {
+			return signedRequest(testSecret, now.Add(maxClockSkew+time.Minute), body)
+		}, false},
+		{"timestamp changed after signing", func() *http.Request {
+			r := signedRequest(testSecret, now, body)
+			r.Header.Set(TIMESTAMP_HEADER, strconv.FormatInt(now.Add(time.Second).Unix(), 10))
+			return r
+		}, false},
+		{"replayed to another path", func() *http.Request {
+			r := signedRequest(testSecret, now, body)
+			r.URL.Path = "/peer/snapshot"
+			return r
+		}, false},
+		{"replayed with another method", func() *http.Request {
+			r := signedRequest(testSecret, now, body)
+			r.Method = http.MethodPut
+			return r
+		}, false},
+		{"missing signature header", func() *http.Request {
+			r := signedRequest(testSecret, now, body)
+			r.Header.Del(SIGNATURE_HEADER)
+			return r
+		}, false},
+		{"missing timestamp header", func() *http.Request {
+			r := signedRequest(testSecret, now, body)
+			r.Header.Del(TIMESTAMP_HEADER)
+			return r
+		}, false},
+		{"malformed timestamp", func() *http.Request {
+			r := signedRequest(testSecret, now, body)
+			r.Header.Set(TIMESTAMP_HEADER, "yesterday")
+			return r
+		}, false},
+	}
+
+	verifier := NewVerifier(Config{Secret: testSecret})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received []byte
+			handler := verifier.Middleware(func(w http.ResponseWriter, r *http.Request) {
+				received, _ = io.ReadAll(r.Body)
+			})
+
+			r := test.request()
+			sent, _ := io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(sent))
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if !test.valid {
+				if w.Code != http.StatusUnauthorized {
+					t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+				}
+				if received != nil {
+					t.Error("rejected request reached the handler")
+				}
+				return
+			}
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+			// The body is read to check it, so the handler must still get all of it
+			if !bytes.Equal(received, sent) {
+				t.Errorf("handler received body %q, want %q", received, sent)
+			}
+		})
+	}
+}
+
+// Requests from a client sharing the secret must pass a verifier, and requests from one that doesn't must not
+func TestClientSignsRequests(t *testing.T) {
+	server := httptest.NewServer(NewVerifier(Config{Secret: testSecret}).Middleware(
+		func(w http.ResponseWriter, r *http.Request) {},
+	))
+	defer server.Close()
+
+	tests := []struct {
+		secret string
+		want   int
+	}{
+		{testSecret, http.StatusOK},
+		{"some other secret", http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		client, err := NewClient(server.URL, Config{Secret: test.secret, Timeout: 5 * time.Second})
+		if err != nil {
+			t.Fatalf("NewClient: %s", err)
+		}
+		for _, body := range [][]byte{nil, []byte(`{"id":"e1"}`)} {
+			resp, err := client.Do(context.Background(), http.MethodPost, "/replicate", body)
+			if err != nil {
+				t.Fatalf("Do: %s", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.want {
+				t.Errorf("secret %q, body %q: status = %d, want %d", test.secret, body, resp.StatusCode, test.want)
+			}
+		}
+	}
+}
+
+func TestNewClientRequiresAuthentication(t *testing.T) {
+	if _, err := NewClient("https://peer.example.com", Config{}); err == nil {
+		t.Error("NewClient without a secret or CA bundle succeeded")
+	}
+}
+
+// With mutual TLS configured, a valid signature alone isn't enough
+func TestVerifyRequiresClientCertificate(t *testing.T) {
+	verifier := NewVerifier(Config{Secret: testSecret, CABundle: "ca.pem"})
+	if err := verifier.verify(signedRequest(testSecret, time.Now(), "")); err != ErrUnauthenticated {
+		t.Errorf("verify without a client certificate = %v, want ErrUnauthenticated", err)
+	}
+}
@@ -0,0 +1,37 @@
+package replication
+
+import "sync"
+
+// How many recently applied event IDs are remembered to catch redelivered events
+const dedupeWindow = 4096
+
+// Remembers recently applied events so a retried delivery isn't applied twice
+type Deduper struct {
+	seen  map[string]struct{}
+	order []string
+	mu    sync.Mutex
+}
+
+func NewDeduper() *Deduper {
+	return &Deduper{
+		seen: make(map[string]struct{}),
+	}
+}
+
+// Records the event ID, returning false if it had already been seen
+func (d *Deduper) FirstSeen(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if _, exists := d.seen[id]; exists {
+		return false
+	}
+
+	d.seen[id] = struct{}{}
+	d.order = append(d.order, id)
+	if len(d.order) > dedupeWindow {
+		delete(d.seen, d.order[0])
+		d.order = d.order[1:]
+	}
+	return true
+}
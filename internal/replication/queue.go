@@ -0,0 +1,60 @@
+package replication
+
+import (
+	"github.com/angelajfisher/meeting-mate/internal/db"
+)
+
+// Keeps queued events in the store so they survive a restart when it's persistent
+type storeQueue struct {
+	store  db.Store
+	target string // Address of the peer the events are for
+}
+
+// Returns a queue of events for the peer at the given address
+func NewQueue(store db.Store, target string) Queue {
+	return storeQueue{store: store, target: target}
+}
+
+func (q storeQueue) Push(event Event) error {
+	return q.store.PushReplicationEvent(q.target, db.ReplicationEvent{
+		ID:         event.ID,
+		Kind:       event.Kind,
+		Origin:     event.Origin,
+		ReceivedAt: event.ReceivedAt,
+		Epoch:      event.Version.Epoch,
+		Seq:        event.Version.Seq,
+		Payload:    event.Payload,
+	})
+}
+
+func (q storeQueue) Peek() (Event, bool, error) {
+	event, ok, err := q.store.PeekReplicationEvent(q.target)
+	return fromStore(event), ok, err
+}
+
+func (q storeQueue) Remove(id string) error {
+	return q.store.DeleteReplicationEvent(q.target, id)
+}
+
+func (q storeQueue) All() ([]Event, error) {
+	stored, err := q.store.GetReplicationEvents(q.target)
+	if err != nil {
+		return nil, err
+	}
+	events := make([]Event, len(stored))
+	for i, event := range stored {
+		events[i] = fromStore(event)
+	}
+	return events, nil
+}
+
+func fromStore(event db.ReplicationEvent) Event {
+	return Event{
+		ID:         event.ID,
+		Kind:       event.Kind,
+		Origin:     event.Origin,
+		ReceivedAt: event.ReceivedAt,
+		Version:    Version{Epoch: event.Epoch, Seq: event.Seq},
+		Payload:    event.Payload,
+	}
+}
@@ -0,0 +1,196 @@
+// Keeps the nodes of a high-availability cluster in sync by forwarding every Zoom event between them
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/peer"
+)
+
+const (
+	REPLICATION_SLUG = "/replicate"
+
+	// Kinds of events nodes replicate to each other
+	ZOOM_EVENT     = "zoom"     // A webhook exactly as Zoom sent it
+	WATCH_EVENT    = "watch"    // A watch created, changed, or canceled through Discord
+	ROLE_EVENT     = "role"     // A role allowed or denied an action on a guild's watches
+	SETTINGS_EVENT = "settings" // A guild's settings saved or reset with `/config`
+	DIGEST_EVENT   = "digest"   // A guild's digest scheduled, turned off, or sent
+
+	minBackoff  = 500 * time.Millisecond
+	maxBackoff  = 30 * time.Second
+	maxEventAge = 24 * time.Hour // Events older than this are dropped rather than replayed
+)
+
+// Returned by send when the peer refused an event in a way retrying won't fix
+var errRejected = errors.New("peer rejected the event")
+
+// A change received by one node, wrapped with the metadata its peer needs to apply it exactly once
+type Event struct {
+	ID         string          `json:"id"`
+	Kind       string          `json:"kind"`        // One of the kinds above
+	Origin     string          `json:"origin"`      // ID of the node the change happened on
+	ReceivedAt time.Time       `json:"received_at"` // When the origin node received the change
+	Version    Version         `json:"version"`     // Where the change falls among the origin node's changes
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// First-in, first-out storage for events waiting to be sent to the peer
+type Queue interface {
+	Push(event Event) error
+	Peek() (Event, bool, error)
+	Remove(id string) error
+	All() ([]Event, error) // Every event still waiting, oldest first
+}
+
+// Sends events to a single peer. Each node runs one per peer.
+type Replicator struct {
+	NodeID string // ID of the node the events originate from
+	peer   *peer.Client
+	queue  Queue
+	wake   chan struct{}
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// Creates a replicator that sends events queued by the given node to one of its peers
+func NewReplicator(nodeID string, client *peer.Client, queue Queue) *Replicator {
+	return &Replicator{
+		NodeID: nodeID,
+		peer:   client,
+		queue:  queue,
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+	}
+}
+
+// Queues a change of the given kind to be sent to the peer
+func (r *Replicator) Enqueue(kind string, version Version, payload []byte) {
+	event := Event{
+		ID:         newID(),
+		Kind:       kind,
+		Origin:     r.NodeID,
+		ReceivedAt: time.Now().UTC(),
+		Version:    version,
+		Payload:    json.RawMessage(bytes.Clone(payload)),
+	}
+	if err := r.queue.Push(event); err != nil {
+		log.Printf("could not queue event for replication: %s", err)
+		return
+	}
+
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Sends queued events to the peer in order, retrying with backoff until each is accepted. An event the peer
+// rejects outright is dropped so it doesn't hold up the rest of the queue.
+// Blocks until Stop is called and always returns nil so it can be used in a run.Group.
+func (r *Replicator) Run() error {
+	backoff := time.Duration(0)
+	for {
+		if backoff > 0 {
+			select {
+			case <-r.stop:
+				return nil
+			case <-time.After(backoff):
+			}
+		}
+
+		event, ok, err := r.queue.Peek()
+		if err != nil {
+			log.Printf("could not read replication queue: %s", err)
+			backoff = nextBackoff(backoff)
+			continue
+		}
+		if !ok {
+			// Nothing to send; wait for something new
+			backoff = 0
+			select {
+			case <-r.stop:
+				return nil
+			case <-r.wake:
+			}
+			continue
+		}
+
+		if time.Since(event.ReceivedAt) > maxEventAge {
+			log.Printf("dropping replication event %s: older than %s", event.ID, maxEventAge)
+		} else if err = r.send(event); errors.Is(err, errRejected) {
+			log.Printf("dropping %s replication event %s from %s: %s", event.Kind, event.ID, event.Origin, err)
+		} else if err != nil {
+			log.Printf("could not replicate event %s to peer: %s", event.ID, err)
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		backoff = 0
+		if err = r.queue.Remove(event.ID); err != nil {
+			log.Printf("could not remove replicated event %s from queue: %s", event.ID, err)
+		}
+	}
+}
+
+// Lists the events the peer hasn't accepted yet, oldest first
+func (r *Replicator) Pending() ([]Event, error) {
+	return r.queue.All()
+}
+
+func (r *Replicator) Stop() {
+	r.once.Do(func() { close(r.stop) })
+}
+
+func (r *Replicator) send(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("could not encode event: %w", err)
+	}
+
+	resp, err := r.peer.Do(context.Background(), http.MethodPost, REPLICATION_SLUG, body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if permanent(resp.StatusCode) {
+		return fmt.Errorf("%w: peer responded with %s", errRejected, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("peer responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Whether a response means the peer will never accept the event. Client errors are, apart from timeouts and rate
+// limits, which clear up on their own.
+func permanent(status int) bool {
+	if status == http.StatusRequestTimeout || status == http.StatusTooManyRequests {
+		return false
+	}
+	return status >= 400 && status < 500
+}
+
+func nextBackoff(current time.Duration) time.Duration {
+	if current == 0 {
+		return minBackoff
+	}
+	return min(current*2, maxBackoff)
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
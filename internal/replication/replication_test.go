@@ -0,0 +1,149 @@
+package replication
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/peer"
+)
+
+// Keeps events in memory, in order
+type sliceQueue struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (q *sliceQueue) Push(event Event) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.events = append(q.events, event)
+	return nil
+}
+
+func (q *sliceQueue) Peek() (Event, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.events) == 0 {
+		return Event{}, false, nil
+	}
+	return q.events[0], true, nil
+}
+
+func (q *sliceQueue) Remove(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.events = slices.DeleteFunc(q.events, func(e Event) bool { return e.ID == id })
+	return nil
+}
+
+func (q *sliceQueue) All() ([]Event, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return slices.Clone(q.events), nil
+}
+
+func (q *sliceQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.events)
+}
+
+// Events the peer refuses for good are dropped, while ones it might take later are retried until they're accepted
+func TestRunResponses(t *testing.T) {
+	tests := []struct {
+		status    int
+		delivered bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusRequestEntityTooLarge, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+
+	var (
+		mu        sync.Mutex
+		refused   = make(map[string]bool) // IDs of events already refused once
+		delivered []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("peer could not decode event: %s", err)
+			return
+		}
+		var status int
+		_ = json.Unmarshal(event.Payload, &status)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if status != http.StatusOK && !refused[event.ID] {
+			refused[event.ID] = true
+			w.WriteHeader(status)
+			return
+		}
+		delivered = append(delivered, http.StatusText(status))
+	}))
+	defer server.Close()
+
+	client, err := peer.NewClient(server.URL, peer.Config{Secret: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient: %s", err)
+	}
+	queue := new(sliceQueue)
+	r := NewReplicator("n1", client, queue)
+
+	clock := NewVersionClock("n1")
+	enqueue := func(payload []byte) {
+		clock.Next(func(version Version) { r.Enqueue(ZOOM_EVENT, version, payload) })
+	}
+
+	var want []string
+	for _, test := range tests {
+		payload, _ := json.Marshal(test.status)
+		enqueue(payload)
+		if test.delivered {
+			want = append(want, http.StatusText(test.status))
+		}
+	}
+	// A rejected event must not hold up the ones behind it
+	payload, _ := json.Marshal(http.StatusOK)
+	enqueue(payload)
+	want = append(want, http.StatusText(http.StatusOK))
+
+	done := make(chan struct{})
+	go func() {
+		_ = r.Run()
+		close(done)
+	}()
+	defer func() {
+		r.Stop()
+		<-done
+	}()
+
+	deadline := time.Now().Add(30 * time.Second)
+	for queue.len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d events still queued", queue.len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Equal(delivered, want) {
+		t.Errorf("delivered %v, want %v", delivered, want)
+	}
+}
@@ -0,0 +1,89 @@
+package replication
+
+import (
+	"maps"
+	"sync"
+	"time"
+)
+
+// Where an event falls among the changes made on the node it came from. Versions are only ever compared with
+// others from the same node, so nodes' clocks never need to agree.
+type Version struct {
+	Epoch int64 `json:"epoch"` // When the origin node started, so changes made after a restart sort after earlier ones
+	Seq   int64 `json:"seq"`   // Counts the changes the origin node has made since it started
+}
+
+// Whether the version belongs to an event queued before events were versioned
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
+// Whether the version comes after another from the same node
+func (v Version) After(other Version) bool {
+	if v.Epoch != other.Epoch {
+		return v.Epoch > other.Epoch
+	}
+	return v.Seq > other.Seq
+}
+
+// The latest change from each node, by node ID
+type VersionVector map[string]Version
+
+// Whether the given change from a node is already reflected. Unversioned events never are.
+func (vv VersionVector) Covers(origin string, version Version) bool {
+	latest, known := vv[origin]
+	return known && !version.IsZero() && !version.After(latest)
+}
+
+// Tracks the changes this node has made and how far it has gotten through every other node's
+type VersionClock struct {
+	nodeID  string
+	own     Version
+	applied VersionVector
+	mu      sync.Mutex
+}
+
+func NewVersionClock(nodeID string) *VersionClock {
+	return &VersionClock{
+		nodeID:  nodeID,
+		own:     Version{Epoch: time.Now().UnixNano()},
+		applied: make(VersionVector),
+	}
+}
+
+// Versions a new change made on this node and queues it for every peer before another can be versioned, so each
+// peer receives changes in order
+func (c *VersionClock) Next(queue func(Version)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.own.Seq++
+	queue(c.own)
+}
+
+// Records that a change from another node has been applied here
+func (c *VersionClock) Applied(origin string, version Version) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if origin != c.nodeID && version.After(c.applied[origin]) {
+		c.applied[origin] = version
+	}
+}
+
+// Records every change reflected in a snapshot taken by another node
+func (c *VersionClock) Merge(vv VersionVector) {
+	for origin, version := range vv {
+		c.Applied(origin, version)
+	}
+}
+
+// Copies the latest change made here and applied from every other node
+func (c *VersionClock) Vector() VersionVector {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	vv := maps.Clone(c.applied)
+	vv[c.nodeID] = c.own
+	return vv
+}
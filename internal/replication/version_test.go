@@ -0,0 +1,61 @@
+package replication
+
+import (
+	"testing"
+)
+
+func TestVersionVectorCovers(t *testing.T) {
+	vv := VersionVector{"n1": {Epoch: 2, Seq: 5}}
+
+	tests := []struct {
+		name    string
+		origin  string
+		version Version
+		covered bool
+	}{
+		{"earlier change", "n1", Version{Epoch: 2, Seq: 4}, true},
+		{"latest change", "n1", Version{Epoch: 2, Seq: 5}, true},
+		{"later change", "n1", Version{Epoch: 2, Seq: 6}, false},
+		{"change from before a restart", "n1", Version{Epoch: 1, Seq: 99}, true},
+		{"change from after a restart", "n1", Version{Epoch: 3, Seq: 1}, false},
+		{"unversioned change", "n1", Version{}, false},
+		{"change from an unknown node", "n2", Version{Epoch: 1, Seq: 1}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := vv.Covers(test.origin, test.version); got != test.covered {
+				t.Errorf("Covers(%s, %+v) = %t, want %t", test.origin, test.version, got, test.covered)
+			}
+		})
+	}
+}
+
+func TestVersionClock(t *testing.T) {
+	clock := NewVersionClock("n1")
+
+	var versions []Version
+	for range 3 {
+		clock.Next(func(version Version) { versions = append(versions, version) })
+	}
+	for i := 1; i < len(versions); i++ {
+		if !versions[i].After(versions[i-1]) {
+			t.Errorf("change %d has version %+v, not after %+v", i, versions[i], versions[i-1])
+		}
+	}
+
+	// Only ever moves forward, and never takes another node's word for this node's own changes
+	clock.Applied("n2", Version{Epoch: 1, Seq: 7})
+	clock.Applied("n2", Version{Epoch: 1, Seq: 3})
+	clock.Merge(VersionVector{"n1": {Epoch: 1, Seq: 1}, "n3": {Epoch: 4, Seq: 2}})
+
+	vv := clock.Vector()
+	want := VersionVector{"n1": versions[len(versions)-1], "n2": {Epoch: 1, Seq: 7}, "n3": {Epoch: 4, Seq: 2}}
+	if len(vv) != len(want) {
+		t.Fatalf("Vector() = %+v, want %+v", vv, want)
+	}
+	for origin, version := range want {
+		if vv[origin] != version {
+			t.Errorf("Vector()[%s] = %+v, want %+v", origin, vv[origin], version)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Runs recurring background jobs on a cron-like schedule
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// A task checked once per tick. Jobs decide for themselves whether they're due at the given time.
+type Job struct {
+	Name string
+	Run  func(now time.Time)
+}
+
+type Scheduler struct {
+	tick time.Duration
+	jobs []Job
+	stop chan struct{}
+	once sync.Once
+}
+
+// Creates a scheduler that wakes at the start of every tick (e.g. each minute on the minute)
+func New(tick time.Duration) *Scheduler {
+	return &Scheduler{
+		tick: tick,
+		stop: make(chan struct{}),
+	}
+}
+
+// Registers a job. Jobs must be added before calling Run.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Runs every job once per tick until Stop is called. Always returns nil so it can be used in a run.Group.
+func (s *Scheduler) Run() error {
+	for {
+		now := time.Now()
+		timer := time.NewTimer(now.Truncate(s.tick).Add(s.tick).Sub(now))
+
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return nil
+		case now = <-timer.C:
+		}
+
+		for _, job := range s.jobs {
+			s.runJob(job, now.Truncate(s.tick))
+		}
+	}
+}
+
+func (s *Scheduler) Stop() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// Keeps one misbehaving job from taking down the scheduler
+func (s *Scheduler) runJob(job Job, now time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("scheduled job %s panicked: %v", job.Name, r)
+		}
+	}()
+	job.Run(now)
+}
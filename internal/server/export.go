@@ -0,0 +1,70 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+const EXPORT_SLUG = "/export/"
+
+// Serves attendance exports; only registered behind the export bearer token
+func (s Config) handleExport(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	defer func() {
+		log.Printf("%s '%s' in %s\n", r.Method, r.URL.Path[len(s.BaseURL):], time.Since(startTime))
+	}()
+
+	query := r.URL.Query()
+	meetingID, guildID := r.PathValue("meetingID"), query.Get("guild")
+	if guildID == "" {
+		http.Error(w, "guild is required", http.StatusBadRequest)
+		return
+	}
+	// The same rule as /export, so the endpoint can't reach attendance a server couldn't export itself
+	allowed, err := s.Orchestrator.CanExport(guildID, meetingID)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "could not check meeting history", http.StatusInternalServerError)
+		return
+	}
+	if !allowed {
+		http.Error(w, "meeting isn't watched in that guild and has no history there", http.StatusNotFound)
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = types.EXPORT_CSV
+	}
+	if format != types.EXPORT_CSV && format != types.EXPORT_JSON {
+		http.Error(w, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	from, to, err := orchestrator.ParseExportRange(query.Get("from"), query.Get("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, fileName, err := s.Orchestrator.ExportAttendance(meetingID, from, to, format)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "could not build export", http.StatusInternalServerError)
+		return
+	}
+
+	if format == types.EXPORT_JSON {
+		w.Header().Set("Content-Type", "application/json")
+	} else {
+		w.Header().Set("Content-Type", "text/csv")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	if _, err = w.Write(file); err != nil {
+		log.Println(err)
+	}
+}
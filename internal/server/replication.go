@@ -0,0 +1,117 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/replication"
+	"github.com/angelajfisher/meeting-mate/internal/types"
+)
+
+// Applies a Zoom event forwarded by a peer, notifying Discord only if this node is the leader
+func (s Config) handleReplication(w http.ResponseWriter, r *http.Request) {
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, "could not read body", http.StatusBadRequest)
+		return
+	}
+
+	var event replication.Event
+	if err = json.Unmarshal(reqBody, &event); err != nil || event.ID == "" {
+		http.Error(w, "malformed replication event", http.StatusBadRequest)
+		return
+	}
+
+	// Events that loop back to us, that we've already applied on an earlier delivery, or that were already
+	// reflected in the snapshot we started from are acknowledged and skipped
+	if event.Origin == s.Orchestrator.Cluster.NodeID || !s.replicated.FirstSeen(event.ID) ||
+		s.Orchestrator.AlreadySynced(event.Origin, event.Version) {
+		return
+	}
+	defer s.Orchestrator.MarkApplied(event.Origin, event.Version)
+
+	// Nodes that predate watch replication only ever sent Zoom events, without a kind
+	switch event.Kind {
+	case replication.WATCH_EVENT:
+		var change orchestrator.WatchChange
+		if err = json.Unmarshal(event.Payload, &change); err != nil {
+			log.Printf("could not parse replicated watch change %s: %s", event.ID, err)
+			return
+		}
+		log.Printf(
+			"Received replicated watch change from node %s: meeting ID %s in %s",
+			event.Origin, change.Watch.MeetingID, change.Watch.GuildID,
+		)
+		s.Orchestrator.ApplyWatchChange(change)
+		return
+	case replication.ROLE_EVENT:
+		var change orchestrator.RoleChange
+		if err = json.Unmarshal(event.Payload, &change); err != nil {
+			log.Printf("could not parse replicated role change %s: %s", event.ID, err)
+			return
+		}
+		log.Printf("Received replicated role change from node %s: %s permissions in %s",
+			event.Origin, change.Role.Action, change.Role.GuildID)
+		s.Orchestrator.ApplyRoleChange(change)
+		return
+	case replication.SETTINGS_EVENT:
+		var change orchestrator.SettingsChange
+		if err = json.Unmarshal(event.Payload, &change); err != nil {
+			log.Printf("could not parse replicated settings change %s: %s", event.ID, err)
+			return
+		}
+		log.Printf("Received replicated settings change from node %s for %s", event.Origin, change.Settings.GuildID)
+		s.Orchestrator.ApplySettingsChange(change)
+		return
+	case replication.DIGEST_EVENT:
+		var change orchestrator.DigestChange
+		if err = json.Unmarshal(event.Payload, &change); err != nil {
+			log.Printf("could not parse replicated digest change %s: %s", event.ID, err)
+			return
+		}
+		log.Printf("Received replicated digest change from node %s for %s", event.Origin, change.Settings.GuildID)
+		s.Orchestrator.ApplyDigestChange(change)
+		return
+	}
+
+	var payload json.RawMessage
+	zoomData := ZoomData{Payload: &payload}
+	if err = json.Unmarshal(event.Payload, &zoomData); err != nil {
+		// Nothing will make this event valid, so accept it rather than have it retried forever
+		log.Printf("could not parse replicated event %s: %s", event.ID, err)
+		return
+	}
+	if zoomData.Event == types.ZOOM_ENDPOINT_VALIDATION {
+		return
+	}
+
+	log.Println("Received replicated event from node", event.Origin+": updating applicable watched meetings")
+	s.applyZoomEvent(zoomData, payload)
+}
+
+// Sends this node's watches, live meeting state, permissions, and settings to a peer that's catching up
+func (s Config) handleSnapshot(w http.ResponseWriter, _ *http.Request) {
+	snapshot, err := s.Orchestrator.Snapshot()
+	if err != nil {
+		log.Printf("could not take snapshot: %s", err)
+		http.Error(w, "could not take snapshot", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(snapshot); err != nil {
+		log.Printf("could not send snapshot: %s", err)
+	}
+}
+
+// Reports this node's view of the cluster to a peer
+func (s Config) handleClusterStatus(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Orchestrator.Cluster.Status()); err != nil {
+		log.Printf("could not send cluster status: %s", err)
+	}
+}
@@ -2,23 +2,36 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/angelajfisher/meeting-mate/internal/cluster"
 	"github.com/angelajfisher/meeting-mate/internal/orchestrator"
+	"github.com/angelajfisher/meeting-mate/internal/peer"
+	"github.com/angelajfisher/meeting-mate/internal/replication"
 )
 
 type Config struct {
 	DevMode      bool
-	Orchestrator orchestrator.Orchestrator
+	Orchestrator *orchestrator.Orchestrator
 	Port         string
 	BaseURL      string
 	StaticDir    string
 	Secret       string
+	ExportToken  string               // Bearer token required by the attendance export endpoint; disabled when empty
+	MetricsToken string               // Bearer token required by the metrics endpoint; disabled when empty
+	PeerAuth     peer.Config          // How requests from peers are authenticated
+	replicated   *replication.Deduper // Events already applied from peers
+	badWebhooks  *atomic.Int32        // Webhooks in a row that couldn't be parsed
 	server       *http.Server
 	shuttingDown bool
 }
@@ -26,13 +39,28 @@ type Config struct {
 const WEBHOOK_SLUG = "/webhooks/"
 
 func Start(ss *Config) error {
+	ss.replicated = replication.NewDeduper()
+	ss.badWebhooks = new(atomic.Int32)
+
 	router := http.NewServeMux()
 	fs := http.FileServer(http.Dir(ss.StaticDir))
 
 	router.Handle("GET "+ss.BaseURL+"/static/", http.StripPrefix(ss.BaseURL+"/static/", fs))
 	router.HandleFunc("GET "+ss.BaseURL+"/health", ss.handleHealth)
+	if ss.MetricsToken != "" {
+		router.HandleFunc("GET "+ss.BaseURL+"/metrics", requireBearer(ss.MetricsToken, ss.handleMetrics))
+	}
 	router.HandleFunc("POST "+ss.BaseURL+WEBHOOK_SLUG, ss.handleWebhooks)
+	if ss.PeerAuth.Enabled() {
+		verifier := peer.NewVerifier(ss.PeerAuth)
+		router.HandleFunc("GET "+ss.BaseURL+cluster.STATUS_SLUG, verifier.Middleware(ss.handleClusterStatus))
+		router.HandleFunc("POST "+ss.BaseURL+replication.REPLICATION_SLUG, verifier.Middleware(ss.handleReplication))
+		router.HandleFunc("GET "+ss.BaseURL+orchestrator.SNAPSHOT_SLUG, verifier.Middleware(ss.handleSnapshot))
+	}
 	router.HandleFunc("GET "+ss.BaseURL+"/docs", ss.handleDocs)
+	if ss.ExportToken != "" {
+		router.HandleFunc("GET "+ss.BaseURL+EXPORT_SLUG+"{meetingID}", requireBearer(ss.ExportToken, ss.handleExport))
+	}
 	router.HandleFunc("GET "+ss.BaseURL+"/", ss.handleIndex)
 
 	ss.server = &http.Server{
@@ -44,9 +72,22 @@ func Start(ss *Config) error {
 		ReadHeaderTimeout: 2 * time.Second,
 	}
 
+	// With mutual TLS, ask for client certificates so peer routes can verify them. Zoom won't present one,
+	// so certificates are only checked when given.
+	clientCAs, err := ss.PeerAuth.CAPool()
+	if err != nil {
+		return fmt.Errorf("could not start Zoom webhook listener: %w", err)
+	}
+	if clientCAs != nil {
+		ss.server.TLSConfig = &tls.Config{
+			ClientAuth: tls.VerifyClientCertIfGiven,
+			ClientCAs:  clientCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
 	log.Println("Starting Zoom webhook listener on", ss.Port)
 
-	var err error
 	if ss.DevMode {
 		err = ss.server.ListenAndServe()
 	} else {
@@ -91,6 +132,37 @@ func (s *Config) handleHealth(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// Only lets through requests presenting the given bearer token
+func requireBearer(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Reports how well watches are keeping up with meeting updates
+func (s Config) handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	stats := s.Orchestrator.UpdateStats()
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(map[string]any{
+		"subscribers":         stats.Subscribers,
+		"updates_published":   stats.Published,
+		"updates_delivered":   stats.Delivered,
+		"updates_dropped":     stats.Dropped,
+		"updates_pending":     stats.Pending,
+		"max_lag_seconds":     stats.MaxLag.Seconds(),
+		"current_lag_seconds": stats.CurrentLag.Seconds(),
+	})
+	if err != nil {
+		log.Printf("could not write metrics: %s", err)
+	}
+}
+
 func (s Config) handleIndex(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 
@@ -1,7 +1,6 @@
 package server
 
 import (
-	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
@@ -12,9 +11,13 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/angelajfisher/meeting-mate/internal/replication"
 	"github.com/angelajfisher/meeting-mate/internal/types"
 )
 
+// How many webhooks in a row can fail to parse before the operator is alerted
+const badWebhookLimit = 5
+
 type ZoomData struct {
 	Payload interface{} `json:"payload"`
 	EventTS int64       `json:"event_ts"`
@@ -69,6 +72,7 @@ func (s Config) handleWebhooks(w http.ResponseWriter, r *http.Request) {
 	err = json.Unmarshal(reqBody, &zoomData)
 	if err != nil {
 		log.Println(err)
+		s.recordBadWebhook(err)
 		return
 	}
 
@@ -92,58 +96,75 @@ func (s Config) handleWebhooks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Println("Received webhook from " + r.Host + ": updating applicable watched meetings")
+	log.Println("Received webhook from Zoom: updating applicable watched meetings")
+
+	s.applyZoomEvent(zoomData, payload)
 
+	// Queue the event for every peer whether or not it applied here, since they may be watching meetings we
+	// aren't. It's applied first so any snapshot that counts it as sent also reflects it.
+	s.Orchestrator.Replicate(replication.ZOOM_EVENT, reqBody)
+}
+
+// Updates any watches on the meeting in a Zoom event. Followers in a cluster apply it silently, updating
+// their view of the meeting without notifying Discord, so they're ready to take over from the leader.
+func (s Config) applyZoomEvent(zoomData ZoomData, payload json.RawMessage) {
 	var payloadData Meeting
-	err = json.Unmarshal(payload, &ObjectWrapper{&payloadData})
+	err := json.Unmarshal(payload, &ObjectWrapper{&payloadData})
 	if err != nil {
 		log.Println(err)
+		s.recordBadWebhook(err)
+	} else {
+		s.badWebhooks.Store(0)
 	}
 
 	if !s.Orchestrator.IsWatchedMeeting(payloadData.ID) {
 		return
 	}
 
-	// Will determine how we handle this data: do we forward it to the sister server, or were we sent this to sync up?
-	synchronizing := r.Host == s.Orchestrator.SisterAddress
-
 	updatedMeetingData := types.MeetingData{
 		EventType:   zoomData.Event,
 		MeetingName: payloadData.Topic,
-		Silent:      synchronizing,
+		Silent:      !s.Orchestrator.IsLeader(),
 	}
 
 	if zoomData.Event == types.ZOOM_PARTICIPANT_JOIN || zoomData.Event == types.ZOOM_PARTICIPANT_LEAVE {
 		updatedMeetingData.ParticipantName = payloadData.Participant.UserName
 		updatedMeetingData.ParticipantID = payloadData.Participant.UserID
+		updatedMeetingData.ParticipantEmail = payloadData.Participant.Email
+		if zoomData.Event == types.ZOOM_PARTICIPANT_JOIN {
+			updatedMeetingData.Timestamp = eventTime(payloadData.Participant.JoinTime, zoomData.EventTS)
+		} else {
+			updatedMeetingData.Timestamp = eventTime(payloadData.Participant.LeaveTime, zoomData.EventTS)
+		}
 	} else if zoomData.Event == types.ZOOM_MEETING_END {
 		updatedMeetingData.StartTime = payloadData.StartTime
 		updatedMeetingData.EndTime = payloadData.EndTime
+		updatedMeetingData.Timestamp = eventTime(payloadData.EndTime, zoomData.EventTS)
 	}
 
 	s.Orchestrator.UpdateMeeting(payloadData.ID, updatedMeetingData)
+}
 
-	// Quietly forward this data to our sister server if applicable
-	if !synchronizing && s.Orchestrator.SisterAddress != "" {
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
-		defer cancel()
-
-		var req *http.Request
-		req, err = http.NewRequestWithContext(ctx, http.MethodPost, s.Orchestrator.SisterAddress+WEBHOOK_SLUG, r.Body)
-		if err != nil {
-			fmt.Println("could not forward update:", err)
-			return
-		}
+// Counts a webhook that couldn't be parsed, alerting the operator once several arrive in a row, since Zoom may
+// have changed its payloads
+func (s Config) recordBadWebhook(err error) {
+	if failures := s.badWebhooks.Add(1); failures >= badWebhookLimit {
+		s.Orchestrator.Alerts.Raise(
+			"bad-webhooks", "Zoom Webhooks Failing to Parse",
+			"The last %d webhooks from Zoom couldn't be parsed. Latest error: %s", failures, err,
+		)
+	}
+}
 
-		req.Header.Add("content-type", "application/json")
-		resp, reqErr := http.DefaultClient.Do(req)
-		resp.Body.Close()
-		if reqErr != nil {
-			fmt.Println("Could not forward update data to sister server:", reqErr)
-		} else {
-			fmt.Println("Successfully forwarded update data to sister server")
-		}
+// Determines when an event happened, preferring the time reported in the payload over the webhook's send time
+func eventTime(payloadTime string, eventTS int64) time.Time {
+	if t, err := time.Parse(types.ZOOM_TIME_FORMAT, payloadTime); err == nil {
+		return t
+	}
+	if eventTS != 0 {
+		return time.UnixMilli(eventTS).UTC()
 	}
+	return time.Now().UTC()
 }
 
 func validateEndpoint(payload json.RawMessage, secret string) ([]byte, error) {
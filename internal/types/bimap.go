@@ -3,60 +3,41 @@ package types
 import "sync"
 
 type Bimap struct {
-	guildMeetings map[string]map[string]struct{} // map[guildID]map[meetingID] - the meetings being watched by a guild
-	meetingGuilds map[string]map[string]struct{} // map[meetingID]map[guildID] - the guilds watching a meeting
+	// map[guildID]map[meetingID]map[channelID] - the meetings being watched by a guild, and from where
+	guildMeetings map[string]map[string]map[string]struct{}
+	// map[meetingID]map[guildID]map[channelID] - the guilds watching a meeting, and from where
+	meetingGuilds map[string]map[string]map[string]struct{}
 	mu            sync.RWMutex
 }
 
 func NewBimap() *Bimap {
 	return &Bimap{
-		guildMeetings: make(map[string]map[string]struct{}),
-		meetingGuilds: make(map[string]map[string]struct{}),
+		guildMeetings: make(map[string]map[string]map[string]struct{}),
+		meetingGuilds: make(map[string]map[string]map[string]struct{}),
 	}
 }
 
-func (b *Bimap) Add(guildID string, meetingID string) {
+// Records that a guild is watching a meeting from a channel. Reports false if it already was.
+func (b *Bimap) Add(guildID string, meetingID string, channelID string) bool {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Add to guildMeetings
-	if meetingList, exists := b.guildMeetings[guildID]; exists {
-		if _, present := meetingList[meetingID]; !present {
-			b.guildMeetings[guildID][meetingID] = struct{}{}
-		}
-	} else {
-		b.guildMeetings[guildID] = make(map[string]struct{})
-		b.guildMeetings[guildID][meetingID] = struct{}{}
+	if _, present := b.guildMeetings[guildID][meetingID][channelID]; present {
+		return false
 	}
 
-	// Add to meetingGuilds
-	if guildList, exists := b.meetingGuilds[guildID]; exists {
-		if _, present := guildList[guildID]; !present {
-			b.meetingGuilds[meetingID][guildID] = struct{}{}
-		}
-	} else {
-		b.meetingGuilds[meetingID] = make(map[string]struct{})
-		b.meetingGuilds[meetingID][guildID] = struct{}{}
-	}
+	addNested(b.guildMeetings, guildID, meetingID, channelID)
+	addNested(b.meetingGuilds, meetingID, guildID, channelID)
+
+	return true
 }
 
-func (b *Bimap) Remove(guildID string, meetingID string) {
+func (b *Bimap) Remove(guildID string, meetingID string, channelID string) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Remove from guildMeetings
-	if meetingList, exists := b.guildMeetings[guildID]; exists {
-		if _, present := meetingList[meetingID]; present {
-			delete(b.guildMeetings[guildID], meetingID)
-		}
-	}
-
-	// Remove from meetingGuilds
-	if guildList, exists := b.meetingGuilds[meetingID]; exists {
-		if _, present := guildList[guildID]; present {
-			delete(b.meetingGuilds[meetingID], guildID)
-		}
-	}
+	removeNested(b.guildMeetings, guildID, meetingID, channelID)
+	removeNested(b.meetingGuilds, meetingID, guildID, channelID)
 }
 
 func (b *Bimap) GetGuilds(meetingID string) []string {
@@ -73,6 +54,7 @@ func (b *Bimap) GetGuilds(meetingID string) []string {
 	return []string{}
 }
 
+// Lists each meeting watched by a guild once, however many channels it's watched from
 func (b *Bimap) GetMeetings(guildID string) []string {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
@@ -87,23 +69,50 @@ func (b *Bimap) GetMeetings(guildID string) []string {
 	return []string{}
 }
 
-func (b *Bimap) Exists(guildID string, meetingID string) bool {
+// Lists the channels a guild is watching a meeting from
+func (b *Bimap) GetChannels(guildID string, meetingID string) []string {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	if guildList, exists := b.guildMeetings[guildID]; exists {
-		_, present := guildList[meetingID]
-		return present
+	allChannels := make([]string, 0, len(b.guildMeetings[guildID][meetingID]))
+	for channelID := range b.guildMeetings[guildID][meetingID] {
+		allChannels = append(allChannels, channelID)
 	}
-	return false
+	return allChannels
+}
+
+func (b *Bimap) Exists(guildID string, meetingID string, channelID string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, present := b.guildMeetings[guildID][meetingID][channelID]
+	return present
 }
 
 func (b *Bimap) ActiveMeeting(meetingID string) bool {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	if _, exists := b.meetingGuilds[meetingID]; exists && len(b.meetingGuilds[meetingID]) != 0 {
-		return true
+	return len(b.meetingGuilds[meetingID]) != 0
+}
+
+func addNested(m map[string]map[string]map[string]struct{}, outer string, middle string, inner string) {
+	if _, exists := m[outer]; !exists {
+		m[outer] = make(map[string]map[string]struct{})
+	}
+	if _, exists := m[outer][middle]; !exists {
+		m[outer][middle] = make(map[string]struct{})
+	}
+	m[outer][middle][inner] = struct{}{}
+}
+
+// Removes an entry, along with any maps it leaves empty
+func removeNested(m map[string]map[string]map[string]struct{}, outer string, middle string, inner string) {
+	delete(m[outer][middle], inner)
+	if len(m[outer][middle]) == 0 {
+		delete(m[outer], middle)
+	}
+	if len(m[outer]) == 0 {
+		delete(m, outer)
 	}
-	return false
 }
@@ -0,0 +1,107 @@
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Guilds adding and removing watches at once must leave both directions of the map agreeing with each other
+func TestBimapConcurrentWatches(t *testing.T) {
+	const (
+		guilds   = 32
+		meetings = 10
+		channels = 6
+	)
+	b := NewBimap()
+
+	var wg sync.WaitGroup
+	for g := range guilds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			guildID := fmt.Sprintf("g%d", g)
+			for m := range meetings {
+				meetingID := fmt.Sprintf("m%d", m)
+				for c := range channels {
+					if !b.Add(guildID, meetingID, fmt.Sprintf("c%d", c)) {
+						t.Errorf("Add(%s, %s, c%d) reported an existing watch", guildID, meetingID, c)
+					}
+					b.GetChannels(guildID, meetingID)
+					b.GetGuilds(meetingID)
+				}
+				// Only even channels are kept
+				for c := 1; c < channels; c += 2 {
+					b.Remove(guildID, meetingID, fmt.Sprintf("c%d", c))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	want := []string{"c0", "c2", "c4"}
+	for g := range guilds {
+		guildID := fmt.Sprintf("g%d", g)
+		if got := len(b.GetMeetings(guildID)); got != meetings {
+			t.Errorf("%s watches %d meetings, want %d", guildID, got, meetings)
+		}
+		for m := range meetings {
+			meetingID := fmt.Sprintf("m%d", m)
+			if got := slices.Sorted(slices.Values(b.GetChannels(guildID, meetingID))); !reflect.DeepEqual(got, want) {
+				t.Errorf("GetChannels(%s, %s) = %v, want %v", guildID, meetingID, got, want)
+			}
+		}
+	}
+	for m := range meetings {
+		meetingID := fmt.Sprintf("m%d", m)
+		if got := len(b.GetGuilds(meetingID)); got != guilds {
+			t.Errorf("%s is watched by %d guilds, want %d", meetingID, got, guilds)
+		}
+	}
+}
+
+// Racing to add the same watch must let exactly one caller through, and racing to remove it must leave nothing
+func TestBimapConcurrentSameWatch(t *testing.T) {
+	const (
+		callers = 64
+		rounds  = 100
+	)
+	b := NewBimap()
+
+	for r := range rounds {
+		var added atomic.Int32
+		var wg sync.WaitGroup
+		for range callers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if b.Add("g1", "m1", "c1") {
+					added.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := added.Load(); n != 1 {
+			t.Fatalf("round %d: %d callers added the watch, want 1", r, n)
+		}
+
+		for range callers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				b.Remove("g1", "m1", "c1")
+			}()
+		}
+		wg.Wait()
+		if b.Exists("g1", "m1", "c1") || b.ActiveMeeting("m1") {
+			t.Fatalf("round %d: watch still exists after being removed", r)
+		}
+	}
+
+	if len(b.guildMeetings) != 0 || len(b.meetingGuilds) != 0 {
+		t.Errorf("empty maps left behind: %v, %v", b.guildMeetings, b.meetingGuilds)
+	}
+}
@@ -3,56 +3,57 @@ package types
 import (
 	"log"
 	"sync"
+	"time"
 )
 
-type Meeting struct {
-	Participants *ParticipantList
+type meeting struct {
 	name         string
-	id           string
+	participants *ParticipantList
 }
 
+// A copy of a meeting's live state, used to bring another node up to date
+type MeetingSnapshot struct {
+	ID           string                `json:"id"`
+	Name         string                `json:"name"`
+	Participants []ParticipantSnapshot `json:"participants"`
+}
+
+type ParticipantSnapshot struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Email    string    `json:"email"`
+	Present  bool      `json:"present"`
+	Sessions []Session `json:"sessions"`
+}
+
+// Owns the live state of every meeting. Participant lists are only ever touched while holding the store's lock,
+// so each method below is a single atomic step.
 type MeetingStore struct {
-	meetings map[string]Meeting // map[meetingID]Meeting
+	meetings map[string]*meeting // map[meetingID]meeting
 	mu       sync.RWMutex
 }
 
 func NewMeetingStore() *MeetingStore {
 	return &MeetingStore{
-		meetings: make(map[string]Meeting),
+		meetings: make(map[string]*meeting),
 	}
 }
 
-func (ms *MeetingStore) NewMeeting(id string, meetingName string) Meeting {
-	if ms.exists(id) {
-		return ms.meetings[id]
-	}
-
-	newMeeting := Meeting{
-		id:           id,
-		name:         meetingName,
-		Participants: newParticipantList(),
-	}
-
+// Starts tracking a meeting, keeping any state already collected for it
+func (ms *MeetingStore) NewMeeting(id string, meetingName string) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	ms.meetings[id] = newMeeting
-	return newMeeting
+	ms.meeting(id, meetingName)
 }
 
 // Stores changes to meeting data. Currently only meeting "topics" (names) are tracked
 func (ms *MeetingStore) UpdateMeeting(id string, updatedName string) {
-	ms.mu.RLock()
-	meeting, exists := ms.meetings[id]
-	ms.mu.RUnlock()
-
-	if exists {
-		if meeting.name != updatedName {
-			meeting.name = updatedName
-			ms.mu.Lock()
-			ms.meetings[id] = meeting
-			ms.mu.Unlock()
-		}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if m, exists := ms.meetings[id]; exists {
+		m.name = updatedName
 	} else {
 		log.Println("could not update meeting: meeting id " + id + " doesn't exist")
 	}
@@ -62,38 +63,108 @@ func (ms *MeetingStore) GetName(id string) string {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	return ms.meetings[id].name
+	if m, exists := ms.meetings[id]; exists {
+		return m.name
+	}
+	return ""
 }
 
-func (ms *MeetingStore) AddParticipant(meetingID string, participantID string, participantName string) string {
-	ms.mu.RLock()
-	defer ms.mu.RUnlock()
+// Records someone joining and returns the participant list along with everyone present afterwards
+func (ms *MeetingStore) AddParticipant(
+	meetingID string,
+	participantID string,
+	participantName string,
+	participantEmail string,
+	timestamp time.Time,
+) (string, []AttendanceRecord) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	participants := ms.meeting(meetingID, "").participants
+	participants.Add(participantID, participantName, participantEmail, true, timestamp)
+	return participants.Stringify(), participants.Present()
+}
 
-	ms.meetings[meetingID].Participants.Add(participantID, participantName, true)
-	return ms.meetings[meetingID].Participants.Stringify()
+// Records someone leaving and returns the participant list along with everyone present afterwards
+func (ms *MeetingStore) RemoveParticipant(
+	meetingID string,
+	participantID string,
+	participantName string,
+	timestamp time.Time,
+) (string, []AttendanceRecord) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	participants := ms.meeting(meetingID, "").participants
+	participants.Remove(participantID, participantName, timestamp)
+	return participants.Stringify(), participants.Present()
 }
 
-func (ms *MeetingStore) RemoveParticipant(meetingID string, participantID string, participantName string) string {
+func (ms *MeetingStore) GetPresent(meetingID string) []AttendanceRecord {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	ms.meetings[meetingID].Participants.Remove(participantID, participantName)
-	return ms.meetings[meetingID].Participants.Stringify()
+	if m, exists := ms.meetings[meetingID]; exists {
+		return m.participants.Present()
+	}
+	return []AttendanceRecord{}
+}
+
+func (ms *MeetingStore) EndMeeting(id string, endTime time.Time) MeetingSummary {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.meeting(id, "").participants.Empty(endTime)
 }
 
-func (ms *MeetingStore) EndMeeting(id string) int {
+// How many meetings have someone in them right now
+func (ms *MeetingStore) Live() int {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	return ms.meetings[id].Participants.Empty()
+	live := 0
+	for _, m := range ms.meetings {
+		if len(m.participants.Present()) > 0 {
+			live++
+		}
+	}
+	return live
 }
 
-func (ms *MeetingStore) exists(meetingID string) bool {
+// Copies the live state of every meeting
+func (ms *MeetingStore) Snapshot() []MeetingSnapshot {
 	ms.mu.RLock()
 	defer ms.mu.RUnlock()
 
-	if _, exists := ms.meetings[meetingID]; exists {
-		return true
+	snapshot := make([]MeetingSnapshot, 0, len(ms.meetings))
+	for id, m := range ms.meetings {
+		snapshot = append(snapshot, MeetingSnapshot{
+			ID:           id,
+			Name:         m.name,
+			Participants: m.participants.snapshot(),
+		})
+	}
+	return snapshot
+}
+
+// Overwrites the state of each meeting in the snapshot, adding any that aren't known yet
+func (ms *MeetingStore) Restore(snapshot []MeetingSnapshot) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, restored := range snapshot {
+		m := ms.meeting(restored.ID, restored.Name)
+		m.name = restored.Name
+		m.participants.restore(restored.Participants)
+	}
+}
+
+// Finds a meeting, creating it with the given name if it isn't known yet. Callers must hold the write lock.
+func (ms *MeetingStore) meeting(id string, name string) *meeting {
+	m, exists := ms.meetings[id]
+	if !exists {
+		m = &meeting{name: name, participants: newParticipantList()}
+		ms.meetings[id] = m
 	}
-	return false
+	return m
 }
@@ -0,0 +1,164 @@
+package types
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testStart = time.Date(2024, time.March, 4, 15, 0, 0, 0, time.UTC)
+
+// Many participants joining and leaving the same meeting at once must each end up with every session they had
+func TestMeetingStoreConcurrentParticipants(t *testing.T) {
+	const (
+		participants = 64
+		rounds       = 50
+	)
+	store := NewMeetingStore()
+	store.NewMeeting("m1", "Standup")
+
+	var wg sync.WaitGroup
+	for p := range participants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id, name := fmt.Sprintf("p%d", p), fmt.Sprintf("Participant %d", p)
+			for r := range rounds {
+				joined := testStart.Add(time.Duration(2*r) * time.Second)
+				_, present := store.AddParticipant("m1", id, name, "", joined)
+				if !isPresent(present, id) {
+					t.Errorf("%s missing from those present right after joining", id)
+				}
+				_, present = store.RemoveParticipant("m1", id, name, joined.Add(time.Second))
+				if isPresent(present, id) {
+					t.Errorf("%s still present right after leaving", id)
+				}
+			}
+		}()
+	}
+	// Readers take the lock alongside the writers
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				store.GetPresent("m1")
+				store.Live()
+				store.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if present := store.GetPresent("m1"); len(present) != 0 {
+		t.Errorf("%d participants still present after everyone left", len(present))
+	}
+	if live := store.Live(); live != 0 {
+		t.Errorf("Live() = %d, want 0", live)
+	}
+
+	snapshot := store.Snapshot()
+	if len(snapshot) != 1 || len(snapshot[0].Participants) != participants {
+		t.Fatalf("snapshot has %d meetings, want 1 with %d participants", len(snapshot), participants)
+	}
+	for _, participant := range snapshot[0].Participants {
+		if len(participant.Sessions) != rounds {
+			t.Errorf("%s has %d sessions, want %d", participant.ID, len(participant.Sessions), rounds)
+		}
+		for _, session := range participant.Sessions {
+			if session.Leave.Sub(session.Join) != time.Second {
+				t.Errorf("%s has session %v-%v, want one second long", participant.ID, session.Join, session.Leave)
+			}
+		}
+	}
+
+	summary := store.EndMeeting("m1", testStart.Add(time.Hour))
+	if summary.UniqueParticipants != participants {
+		t.Errorf("UniqueParticipants = %d, want %d", summary.UniqueParticipants, participants)
+	}
+	if summary.Reconnects != participants*(rounds-1) {
+		t.Errorf("Reconnects = %d, want %d", summary.Reconnects, participants*(rounds-1))
+	}
+	if summary.PeakParticipants != participants {
+		t.Errorf("PeakParticipants = %d, want %d", summary.PeakParticipants, participants)
+	}
+	for _, record := range summary.Attendance {
+		if record.TimePresent != rounds*time.Second {
+			t.Errorf("%s was present for %s, want %s", record.ID, record.TimePresent, rounds*time.Second)
+		}
+	}
+}
+
+// Meetings ending while others are still busy must only summarize their own participants
+func TestMeetingStoreConcurrentEnds(t *testing.T) {
+	const (
+		meetings     = 16
+		participants = 8
+		occurrences  = 20
+	)
+	store := NewMeetingStore()
+
+	var wg sync.WaitGroup
+	for m := range meetings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			meetingID := fmt.Sprintf("m%d", m)
+			for o := range occurrences {
+				start := testStart.Add(time.Duration(o) * time.Hour)
+
+				// Everyone joins at once, half of them leave, and then the meeting ends with the rest still in it
+				var joins sync.WaitGroup
+				for p := range participants {
+					joins.Add(1)
+					go func() {
+						defer joins.Done()
+						id := fmt.Sprintf("%s-p%d", meetingID, p)
+						store.AddParticipant(meetingID, id, id, "", start)
+						if p%2 == 0 {
+							store.RemoveParticipant(meetingID, id, id, start.Add(10*time.Minute))
+						}
+					}()
+				}
+				joins.Wait()
+
+				summary := store.EndMeeting(meetingID, start.Add(30*time.Minute))
+				if summary.UniqueParticipants != participants || summary.Reconnects != 0 {
+					t.Errorf("%s occurrence %d: %d participants and %d reconnects, want %d and 0",
+						meetingID, o, summary.UniqueParticipants, summary.Reconnects, participants)
+				}
+				if summary.PeakParticipants != participants {
+					t.Errorf("%s occurrence %d: peak of %d, want %d",
+						meetingID, o, summary.PeakParticipants, participants)
+				}
+				var total time.Duration
+				for _, record := range summary.Attendance {
+					total += record.TimePresent
+				}
+				if want := participants / 2 * (10*time.Minute + 30*time.Minute); total != want {
+					t.Errorf("%s occurrence %d: total attendance %s, want %s", meetingID, o, total, want)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if live := store.Live(); live != 0 {
+		t.Errorf("Live() = %d after every meeting ended, want 0", live)
+	}
+	for _, meeting := range store.Snapshot() {
+		if len(meeting.Participants) != 0 {
+			t.Errorf("%s kept %d participants after ending", meeting.ID, len(meeting.Participants))
+		}
+	}
+}
+
+func isPresent(present []AttendanceRecord, id string) bool {
+	for _, record := range present {
+		if record.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -1,19 +1,30 @@
 package types
 
 import (
+	"slices"
+	"sort"
 	"strings"
-	"sync"
+	"time"
 )
 
 type Participant struct {
-	id      string
-	name    string
-	present bool
+	id       string
+	name     string
+	email    string
+	present  bool
+	sessions []Session // Every stretch of time this participant spent in the meeting, in order
 }
 
+// A single stretch of time a participant spent in a meeting. A zero Leave means they're still present.
+type Session struct {
+	Join  time.Time
+	Leave time.Time
+}
+
+// Everyone seen in a single meeting. Not safe for concurrent use on its own; the MeetingStore holding it
+// serializes access.
 type ParticipantList struct {
 	participants map[string]Participant // map[participantID]Participant
-	mu           sync.RWMutex
 }
 
 func newParticipantList() *ParticipantList {
@@ -22,37 +33,46 @@ func newParticipantList() *ParticipantList {
 	}
 }
 
-func (pl *ParticipantList) Add(participantID string, participantName string, present bool) {
-	if name, currentlyPresent := pl.present(participantID); name == participantName && currentlyPresent == present {
+func (pl *ParticipantList) Add(
+	participantID string,
+	participantName string,
+	participantEmail string,
+	present bool,
+	timestamp time.Time,
+) {
+	participant, exists := pl.participants[participantID]
+	if exists && participant.name == participantName && participant.present == present {
 		return
 	}
 
-	pl.mu.Lock()
-	defer pl.mu.Unlock()
-
-	pl.participants[participantID] = Participant{id: participantID, name: participantName, present: present}
+	participant.id = participantID
+	participant.name = participantName
+	if participantEmail != "" {
+		participant.email = participantEmail
+	}
+	if present && !participant.present {
+		participant.sessions = append(participant.sessions, Session{Join: timestamp})
+	}
+	participant.present = present
+	pl.participants[participantID] = participant
 }
 
-func (pl *ParticipantList) Remove(participantID string, participantName string) {
+func (pl *ParticipantList) Remove(participantID string, participantName string, timestamp time.Time) {
 	if _, exists := pl.present(participantID); !exists {
-		pl.Add(participantID, participantName, false)
+		pl.Add(participantID, participantName, "", false, timestamp)
 		return
 	}
 
-	pl.mu.Lock()
-	defer pl.mu.Unlock()
-
 	participant := pl.participants[participantID]
 	participant.present = false
+	if n := len(participant.sessions); n > 0 && participant.sessions[n-1].Leave.IsZero() {
+		participant.sessions[n-1].Leave = timestamp
+	}
 	pl.participants[participantID] = participant
 }
 
 func (pl *ParticipantList) Stringify() string {
 	builder := new(strings.Builder)
-
-	pl.mu.RLock()
-	defer pl.mu.RUnlock()
-
 	for _, participant := range pl.participants {
 		if participant.present {
 			builder.WriteString(participant.name + "\n")
@@ -65,22 +85,140 @@ func (pl *ParticipantList) Stringify() string {
 	return builder.String()
 }
 
-func (pl *ParticipantList) Empty() int {
-	pl.mu.Lock()
-	defer pl.mu.Unlock()
+// Lists everyone currently in the meeting along with their sessions so far
+func (pl *ParticipantList) Present() []AttendanceRecord {
+	present := []AttendanceRecord{}
+	for _, participant := range pl.participants {
+		if participant.present {
+			present = append(present, AttendanceRecord{
+				ID:       participant.id,
+				Name:     participant.name,
+				Email:    participant.email,
+				Sessions: slices.Clone(participant.sessions),
+			})
+		}
+	}
+	return present
+}
 
-	numParticipants := len(pl.participants)
+// Closes out any open sessions at the given end time, clears the list, and returns the attendance stats collected
+func (pl *ParticipantList) Empty(endTime time.Time) MeetingSummary {
+	summary := summarize(pl.participants, endTime)
 	clear(pl.participants)
-	return numParticipants
+	return summary
 }
 
-func (pl *ParticipantList) present(participantID string) (string, bool) {
-	pl.mu.RLock()
-	defer pl.mu.RUnlock()
+// Copies everyone seen in the meeting so far, present or not, with their sessions
+func (pl *ParticipantList) snapshot() []ParticipantSnapshot {
+	snapshot := make([]ParticipantSnapshot, 0, len(pl.participants))
+	for _, participant := range pl.participants {
+		snapshot = append(snapshot, ParticipantSnapshot{
+			ID:       participant.id,
+			Name:     participant.name,
+			Email:    participant.email,
+			Present:  participant.present,
+			Sessions: slices.Clone(participant.sessions),
+		})
+	}
+	return snapshot
+}
 
+// Replaces the list with the participants from a snapshot
+func (pl *ParticipantList) restore(snapshot []ParticipantSnapshot) {
+	clear(pl.participants)
+	for _, participant := range snapshot {
+		pl.participants[participant.ID] = Participant{
+			id:       participant.ID,
+			name:     participant.Name,
+			email:    participant.Email,
+			present:  participant.Present,
+			sessions: slices.Clone(participant.Sessions),
+		}
+	}
+}
+
+func (pl *ParticipantList) present(participantID string) (string, bool) {
 	participant, exists := pl.participants[participantID]
 	if !exists {
 		return "", false
 	}
 	return participant.name, participant.present
 }
+
+// Builds the attendance stats for a set of participants, treating any session still open as ending at endTime
+func summarize(participants map[string]Participant, endTime time.Time) MeetingSummary {
+	var (
+		summary   MeetingSummary
+		firstJoin time.Time
+		lastLeave time.Time
+		changes   []attendanceChange
+	)
+
+	for _, participant := range participants {
+		if len(participant.sessions) == 0 {
+			// Only ever seen leaving, so there's nothing to measure
+			continue
+		}
+
+		summary.UniqueParticipants++
+		summary.Reconnects += len(participant.sessions) - 1
+
+		record := AttendanceRecord{ID: participant.id, Name: participant.name, Email: participant.email}
+		for _, session := range participant.sessions {
+			leave := session.Leave
+			if leave.IsZero() {
+				leave = endTime
+			}
+			if !session.Join.IsZero() && !leave.IsZero() && leave.After(session.Join) {
+				record.TimePresent += leave.Sub(session.Join)
+			}
+			record.Sessions = append(record.Sessions, Session{Join: session.Join, Leave: leave})
+			changes = append(changes, attendanceChange{session.Join, 1}, attendanceChange{leave, -1})
+
+			if firstJoin.IsZero() || session.Join.Before(firstJoin) {
+				firstJoin = session.Join
+				summary.FirstPresent = participant.name
+			}
+			if !leave.Before(lastLeave) {
+				lastLeave = leave
+				summary.LastPresent = participant.name
+			}
+		}
+		summary.Attendance = append(summary.Attendance, record)
+	}
+
+	summary.PeakParticipants = peakConcurrency(changes)
+
+	// Longest attendees first; ties broken by name so the list is stable between edits
+	sort.Slice(summary.Attendance, func(i, j int) bool {
+		if summary.Attendance[i].TimePresent != summary.Attendance[j].TimePresent {
+			return summary.Attendance[i].TimePresent > summary.Attendance[j].TimePresent
+		}
+		return summary.Attendance[i].Name < summary.Attendance[j].Name
+	})
+
+	return summary
+}
+
+type attendanceChange struct {
+	at    time.Time
+	delta int
+}
+
+// Sweeps through every join & leave in time order to find the most participants present at once
+func peakConcurrency(changes []attendanceChange) int {
+	// Leaves sort before joins at the same instant so a reconnect isn't counted as two people
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].at.Equal(changes[j].at) {
+			return changes[i].delta < changes[j].delta
+		}
+		return changes[i].at.Before(changes[j].at)
+	})
+
+	current, peak := 0, 0
+	for _, change := range changes {
+		current += change.delta
+		peak = max(peak, current)
+	}
+	return peak
+}
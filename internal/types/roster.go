@@ -0,0 +1,153 @@
+package types
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// How long after a meeting starts someone on the roster can arrive before being counted as late
+const LATE_ARRIVAL_GRACE = 5 * time.Minute
+
+type Rosters struct {
+	rosters map[[3]string][]string // map[{guildID, meetingID, channelID}]expected attendees
+	mu      sync.RWMutex
+}
+
+func NewRosters() *Rosters {
+	return &Rosters{
+		rosters: make(map[[3]string][]string),
+	}
+}
+
+func (r *Rosters) Get(guildID string, meetingID string, channelID string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return slices.Clone(r.rosters[[3]string{guildID, meetingID, channelID}])
+}
+
+// Replaces the roster for a watch, removing it entirely if entries is empty
+func (r *Rosters) Set(guildID string, meetingID string, channelID string, entries []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := [3]string{guildID, meetingID, channelID}
+	if len(entries) == 0 {
+		delete(r.rosters, key)
+		return
+	}
+	r.rosters[key] = slices.Clone(entries)
+}
+
+// The result of comparing a roster against the people who actually attended
+type RosterReport struct {
+	Present []string // Roster entries matched to an attendee
+	Missing []string // Roster entries nobody matched
+	Late    []string // Roster entries whose first arrival was after the start plus LATE_ARRIVAL_GRACE
+}
+
+// Matches each roster entry against the attendees, by email if the entry looks like one or by name otherwise.
+// Late arrivals are only reported when start is known.
+func CheckRoster(roster []string, attendees []AttendanceRecord, start time.Time) RosterReport {
+	var report RosterReport
+
+	for _, entry := range roster {
+		var (
+			matched      bool
+			firstArrival time.Time
+		)
+		for _, attendee := range attendees {
+			if !rosterMatch(entry, attendee) {
+				continue
+			}
+			matched = true
+			if len(attendee.Sessions) > 0 &&
+				(firstArrival.IsZero() || attendee.Sessions[0].Join.Before(firstArrival)) {
+				firstArrival = attendee.Sessions[0].Join
+			}
+		}
+
+		if !matched {
+			report.Missing = append(report.Missing, entry)
+			continue
+		}
+		report.Present = append(report.Present, entry)
+		if !start.IsZero() && firstArrival.After(start.Add(LATE_ARRIVAL_GRACE)) {
+			report.Late = append(report.Late, entry)
+		}
+	}
+
+	return report
+}
+
+func rosterMatch(entry string, attendee AttendanceRecord) bool {
+	if strings.Contains(entry, "@") {
+		return attendee.Email != "" && strings.EqualFold(strings.TrimSpace(entry), attendee.Email)
+	}
+	return namesMatch(entry, attendee.Name)
+}
+
+// Loosely compares a roster name with a Zoom display name. Names match when they're equal after normalizing case
+// and punctuation, when every word of the roster name appears in the display name (e.g. "Jane Doe" vs
+// "Jane Doe (she/her)"), or when they're only a typo or two apart.
+func namesMatch(rosterName string, displayName string) bool {
+	expected := nameWords(rosterName)
+	actual := nameWords(displayName)
+	if len(expected) == 0 || len(actual) == 0 {
+		return false
+	}
+
+	if strings.Join(expected, " ") == strings.Join(actual, " ") {
+		return true
+	}
+
+	allFound := true
+	for _, word := range expected {
+		if !slices.Contains(actual, word) {
+			allFound = false
+			break
+		}
+	}
+	if allFound {
+		return true
+	}
+
+	// Allow roughly one typo per four letters, so short names like "Tom" & "Tim" still have to match exactly
+	a, b := strings.Join(expected, ""), strings.Join(actual, "")
+	allowedEdits := len([]rune(a)) / 4
+	return allowedEdits > 0 && editDistance(a, b) <= allowedEdits
+}
+
+// Lowercases a name and splits it into words, dropping punctuation
+func nameWords(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -1,12 +1,16 @@
 package types
 
+import "time"
+
 type MeetingData struct {
-	EventType       string
-	MeetingName     string
-	ParticipantName string
-	ParticipantID   string
-	StartTime       string
-	EndTime         string
+	EventType        string
+	MeetingName      string
+	ParticipantName  string
+	ParticipantID    string
+	ParticipantEmail string
+	StartTime        string
+	EndTime          string
+	Timestamp        time.Time // When the event happened according to Zoom
 
 	// When an update is Silent, the incoming data will not trigger a Discord update.
 	// This is used to keep HA servers' in-memory views of a meeting in sync without
@@ -15,19 +19,42 @@ type MeetingData struct {
 }
 
 type UpdateData struct {
-	EventType         string
-	MeetingName       string
-	Participants      string
-	TotalParticipants int
-	MeetingDuration   string
-	Flags             FeatureFlags
+	EventType       string
+	MeetingName     string
+	Participants    string
+	Present         []AttendanceRecord // Everyone currently in the meeting
+	Summary         MeetingSummary
+	MeetingDuration string
+	Flags           FeatureFlags
+	StatusMessageID string // The message another node now keeps up to date with the meeting's status
+}
+
+// Attendance stats collected over the course of a meeting, sent along with its end
+type MeetingSummary struct {
+	Start              time.Time          // When the meeting started according to Zoom
+	End                time.Time          // When the meeting ended according to Zoom
+	UniqueParticipants int                // Number of distinct people who joined
+	Reconnects         int                // Number of times someone rejoined after leaving
+	PeakParticipants   int                // Most participants present at the same time
+	FirstPresent       string             // Name of the first person to join
+	LastPresent        string             // Name of the last person to leave
+	Attendance         []AttendanceRecord // Per-person attendance, longest first
+}
+
+type AttendanceRecord struct {
+	ID          string
+	Name        string
+	Email       string // Only available when the participant is signed in to Zoom
+	TimePresent time.Duration
+	Sessions    []Session
 }
 
 type FeatureFlags struct {
 	Silent         bool   // Whether messages should be sent with the @silent flag
 	JoinLink       string // User-supplied link for others to join the meeting
-	Summaries      bool   // Whether meetings stats should be sent at the end of a meeting
+	SummaryLevel   string // How much detail to include in the stats sent at the end of a meeting
 	HistoryLevel   string // How many messages to send / delete as meetings start and end
+	TimelineChart  bool   // Whether an attendance timeline image should be attached to the summary
 	RestartCommand string // The command to restart this watch with the same flags
 }
 
@@ -42,11 +69,27 @@ const (
 	WATCH_CANCELED  = "canceled"
 	SYSTEM_SHUTDOWN = "shutdown"
 	UPDATE_FLAGS    = "update"
+	STATUS_MESSAGE  = "status"
 
 	// History level options -- MUST MATCH DATABASE SCHEMA
 	FULL_HISTORY    = "Full"    // No old meeting messages are removed
 	PARTIAL_HISTORY = "Partial" // Keep the old meeting message only if it's been buried by conversation
 	MINIMAL_HISTORY = "Minimal" // Do not keep any old meeting messages
 
+	// Summary level options -- MUST MATCH DATABASE SCHEMA
+	NO_SUMMARY       = "None"     // No stats are sent at the end of a meeting
+	BASIC_SUMMARY    = "Basic"    // Duration, unique attendees, and peak attendance
+	DETAILED_SUMMARY = "Detailed" // Basic stats plus reconnects and the first & last people present
+	FULL_SUMMARY     = "Full"     // Detailed stats plus the attendee list with each person's time present
+
+	// Watch management actions that can be limited to certain roles -- MUST MATCH DATABASE SCHEMA
+	CREATE_ACTION = "create"
+	MODIFY_ACTION = "modify"
+	CANCEL_ACTION = "cancel"
+
+	// Attendance export formats
+	EXPORT_CSV  = "csv"
+	EXPORT_JSON = "json"
+
 	ZOOM_TIME_FORMAT = "2006-01-02T15:04:05Z"
 )